@@ -0,0 +1,236 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package ast declares the types used to represent the syntax tree of a
+// mustache template.
+package ast
+
+import (
+	"path"
+	"strings"
+)
+
+// Position is a location in the source of a template. Column counts bytes,
+// as do the Column fields of the nodes, while Char and UTF16 count the
+// characters preceding the position on its line as Unicode code points and
+// as UTF-16 code units, for editors and source maps.
+type Position struct {
+	Offset int // byte offset, starting at 0
+	Line   int // line number, starting at 1
+	Column int // byte column, starting at 1
+	Char   int // character column, starting at 1
+	UTF16  int // UTF-16 column, starting at 1
+}
+
+// Range is the source of a node, from Start up to, but not including, End.
+type Range struct {
+	Start Position
+	End   Position
+}
+
+// Node represents a node in the ast tree. Only constructs implementing
+// the Node inteface in this package can be added as child nodes.
+type Node interface {
+	node()
+}
+
+// Tree is the representation of a single parsed template. LDelim and RDelim
+// hold the delimiters in effect at the start of the template.
+type Tree struct {
+	Name   string
+	LDelim string
+	RDelim string
+	Nodes  []Node
+}
+
+// Add appends a child node to the Tree.
+func (t *Tree) Add(node Node) {
+	t.Nodes = append(t.Nodes, node)
+}
+
+func (t *Tree) node() {}
+
+// Text node represents text exising between mustache tags.
+// When EndOfLine is true, the text string is guaranteed to end with
+// with \n or \r\n. TrimmedLeft and TrimmedRight hold the whitespace removed
+// from either end of the text by the trim markers of adjacent tags; it is
+// kept for printing, but is not rendered. Range covers the source of the text,
+// including the trimmed whitespace.
+type Text struct {
+	Text         string
+	EndOfLine    bool
+	TrimmedLeft  string
+	TrimmedRight string
+	Range        Range
+}
+
+func (t *Text) node() {}
+
+// Tag holds the source of a mustache tag, allowing a tree to be printed back
+// to the exact text it was parsed from. Indent and LineEnd are only set for
+// standalone tags, which the renderer removes from the output along with the
+// whitespace surrounding them. Range covers Raw.
+type Tag struct {
+	Raw        string // tag source, including delimiters
+	Standalone bool   // true when the tag stands alone on its line
+	Indent     string // whitespace preceding a standalone tag
+	LineEnd    string // whitespace and line ending following a standalone tag
+	TrimLeft   bool   // the tag trims the whitespace preceding it
+	TrimRight  bool   // the tag trims the whitespace following it
+	Range      Range  // source range of the tag
+}
+
+// Variable represents a mustache variable tag. Fallbacks, given as
+// {{nickname ?? name ?? "Guest"}} or {{name | default:"Guest"}}, are used in
+// order when the key is missing from the context or falsy. In Handlebars mode,
+// a variable with Args, such as {{formatDate created "short"}}, calls the
+// helper function named by Key.
+type Variable struct {
+	Tag
+	Key       []string
+	Fallbacks []Fallback
+	Args      []Argument
+	Unescaped bool
+	Line      int
+	Column    int
+}
+
+func (v *Variable) node() {}
+
+// Fallback is a fallback of a variable: a key, or a string when Key is nil.
+type Fallback struct {
+	Key    []string
+	String string
+}
+
+// Argument is an argument of a helper call: a key, or a string, int64,
+// float64 or bool literal held by Value when Key is nil.
+type Argument struct {
+	Key   []string
+	Value interface{}
+}
+
+// Section a mustache section tag. The embedded Tag describes the opening tag,
+// and Close describes the closing tag. In Handlebars mode, Block holds the
+// built-in block helper of sections such as {{#if cond}}, which is one of if,
+// unless, each and with, and Nodes may hold an Else node. Block helpers are
+// unrelated to the section helpers of mustache.Template.RegisterHelper, and
+// to the functions called by Variable nodes with Args.
+type Section struct {
+	Tag
+	Close    Tag
+	Key      []string
+	Block    string
+	Inverted bool
+	LDelim   string
+	RDelim   string
+	Text     string
+	Nodes    []Node
+	Line     int
+	Column   int
+}
+
+// Add appends a child node to the Section.
+func (s *Section) Add(node Node) {
+	s.Nodes = append(s.Nodes, node)
+}
+
+func (s *Section) node() {}
+
+// Branches returns the nodes of the section preceding its else tag, and the
+// nodes following it, which are nil when the section has no else tag.
+func (s *Section) Branches() (nodes, inverse []Node) {
+	for i, n := range s.Nodes {
+		if _, ok := n.(*Else); ok {
+			return s.Nodes[:i], s.Nodes[i+1:]
+		}
+	}
+	return s.Nodes, nil
+}
+
+// Else represents the else tag of a section in Handlebars mode. The nodes of
+// the section following it are rendered when the nodes preceding it are not.
+type Else struct {
+	Tag
+	Line   int
+	Column int
+}
+
+func (e *Else) node() {}
+
+// Partial represents a mustache partial tag. The Indent of a standalone partial
+// is applied to each line of the rendered partial.
+type Partial struct {
+	Tag
+	Key    string
+	Line   int
+	Column int
+}
+
+func (p *Partial) node() {}
+
+// Resolve returns the name of the template included by the partial, from the
+// template named from. Keys starting with ./ or ../ are relative to the
+// directory of from, where names are slash separated paths; other keys are
+// the name of the template.
+func (p *Partial) Resolve(from string) string {
+	if strings.HasPrefix(p.Key, "./") || strings.HasPrefix(p.Key, "../") {
+		return path.Join(path.Dir(from), p.Key)
+	}
+	return p.Key
+}
+
+// Comment represents a mustache comment tag. Text holds the comment with
+// surrounding whitespace removed.
+type Comment struct {
+	Tag
+	Text   string
+	Line   int
+	Column int
+}
+
+func (c *Comment) node() {}
+
+// SetDelims represents a mustache set delimiter tag. LDelim and RDelim hold
+// the delimiters in effect after the tag.
+type SetDelims struct {
+	Tag
+	LDelim string
+	RDelim string
+	Line   int
+	Column int
+}
+
+func (d *SetDelims) node() {}
+
+// NodeRange returns the source range of a node. The range of a section spans
+// from its opening tag to the end of its closing tag, and the range of a tree
+// spans its nodes. An empty tree has a zero range.
+func NodeRange(node Node) Range {
+	switch n := node.(type) {
+	case *Tree:
+		if len(n.Nodes) == 0 {
+			return Range{}
+		}
+		return Range{
+			Start: NodeRange(n.Nodes[0]).Start,
+			End:   NodeRange(n.Nodes[len(n.Nodes)-1]).End,
+		}
+	case *Text:
+		return n.Range
+	case *Section:
+		return Range{Start: n.Range.Start, End: n.Close.Range.End}
+	case *Variable:
+		return n.Range
+	case *Partial:
+		return n.Range
+	case *Comment:
+		return n.Range
+	case *SetDelims:
+		return n.Range
+	case *Else:
+		return n.Range
+	}
+	return Range{}
+}
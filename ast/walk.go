@@ -0,0 +1,85 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package ast
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children
+// of node with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses a tree in depth-first order: It starts by calling
+// v.Visit(node); node must not be nil. If the visitor w returned by
+// v.Visit(node) is not nil, Walk is invoked recursively with visitor
+// w for each of the non-nil children of node, followed by a call of
+// w.Visit(nil).
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Tree:
+		walkList(v, n.Nodes)
+	case *Section:
+		walkList(v, n.Nodes)
+	}
+
+	v.Visit(nil)
+}
+
+func walkList(v Visitor, nodes []Node) {
+	for _, node := range nodes {
+		if node != nil {
+			Walk(v, node)
+		}
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a tree in depth-first order: It starts by calling
+// f(node); node must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the non-nil children of node, followed by a
+// call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// Rewrite traverses a tree in depth-first order, replacing each node with
+// the result of f(node). The children of a node are rewritten before the node
+// itself. When f returns nil for a child node, the child is removed from its
+// parent. Trees and sections are modified in place; Rewrite returns the result
+// of f for the root node.
+func Rewrite(node Node, f func(Node) Node) Node {
+	switch n := node.(type) {
+	case *Tree:
+		n.Nodes = rewriteList(n.Nodes, f)
+	case *Section:
+		n.Nodes = rewriteList(n.Nodes, f)
+	}
+	return f(node)
+}
+
+func rewriteList(nodes []Node, f func(Node) Node) []Node {
+	out := nodes[:0]
+	for _, node := range nodes {
+		if node == nil {
+			continue
+		}
+		if node = Rewrite(node, f); node != nil {
+			out = append(out, node)
+		}
+	}
+	return out
+}
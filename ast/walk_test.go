@@ -0,0 +1,111 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package ast_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eriklott/mustache/ast"
+)
+
+func testTree() *ast.Tree {
+	return &ast.Tree{
+		Name: "main",
+		Nodes: []ast.Node{
+			&ast.Text{Text: "a"},
+			&ast.Section{
+				Key: []string{"b"},
+				Nodes: []ast.Node{
+					&ast.Variable{Key: []string{"c"}},
+					&ast.Partial{Key: "d"},
+				},
+			},
+			&ast.Variable{Key: []string{"e", "f"}},
+		},
+	}
+}
+
+func describe(node ast.Node) string {
+	switch n := node.(type) {
+	case *ast.Tree:
+		return "tree"
+	case *ast.Text:
+		return "text:" + n.Text
+	case *ast.Variable:
+		return "variable:" + strings.Join(n.Key, ".")
+	case *ast.Section:
+		return "section:" + strings.Join(n.Key, ".")
+	case *ast.Partial:
+		return "partial:" + n.Key
+	case nil:
+		return "nil"
+	default:
+		return "unknown"
+	}
+}
+
+func TestInspect(t *testing.T) {
+	var got []string
+	ast.Inspect(testTree(), func(node ast.Node) bool {
+		got = append(got, describe(node))
+		_, isSection := node.(*ast.Section)
+		return !isSection
+	})
+
+	want := []string{"tree", "text:a", "nil", "section:b", "variable:e.f", "nil", "nil"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected nodes, got:%v, want:%v", got, want)
+	}
+}
+
+type recorder struct {
+	nodes *[]string
+}
+
+func (r recorder) Visit(node ast.Node) ast.Visitor {
+	*r.nodes = append(*r.nodes, describe(node))
+	return r
+}
+
+func TestWalk(t *testing.T) {
+	var got []string
+	ast.Walk(recorder{&got}, testTree())
+
+	want := []string{"tree", "text:a", "nil", "section:b", "variable:c", "nil", "partial:d", "nil", "nil", "variable:e.f", "nil", "nil"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected nodes, got:%v, want:%v", got, want)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	tree := ast.Rewrite(testTree(), func(node ast.Node) ast.Node {
+		switch n := node.(type) {
+		case *ast.Partial:
+			n.Key = "partials/" + n.Key
+		case *ast.Text:
+			return nil
+		}
+		return node
+	})
+
+	want := &ast.Tree{
+		Name: "main",
+		Nodes: []ast.Node{
+			&ast.Section{
+				Key: []string{"b"},
+				Nodes: []ast.Node{
+					&ast.Variable{Key: []string{"c"}},
+					&ast.Partial{Key: "partials/d"},
+				},
+			},
+			&ast.Variable{Key: []string{"e", "f"}},
+		},
+	}
+	if !reflect.DeepEqual(tree, want) {
+		t.Errorf("unexpected tree, got:%v, want:%v", tree, want)
+	}
+}
@@ -0,0 +1,351 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package mustache
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/eriklott/mustache/ast"
+)
+
+// CheckError reports a tag that cannot be resolved against a data type.
+type CheckError struct {
+	Name   string // name of the template containing the tag
+	Line   int
+	Column int
+	Key    string // the unresolved key or partial name
+	Msg    string
+}
+
+func (e *CheckError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.Name, e.Line, e.Column, e.Msg)
+}
+
+// CheckErrors is the list of problems reported by Check.
+type CheckErrors []*CheckError
+
+func (e CheckErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i := range e {
+		msgs[i] = e[i].Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Check statically verifies that every key used by the named template and the
+// partials it includes can be resolved when the template is rendered with data
+// of the given type. Keys are resolved with the same rules as Render: each
+// section pushes its value onto the context stack, slices and arrays push
+// their element type, and lookups search the stack from the innermost
+// section outwards. Values of interface type, and keys of maps with string
+// keys, cannot be known before rendering and are assumed to resolve. If any
+// key or partial cannot be resolved, Check returns a CheckErrors listing every
+// problem.
+func (t *Template) Check(name string, dataType reflect.Type) error {
+	tree, ok := t.treeMap[name]
+	if !ok {
+		return fmt.Errorf("template not found: %s", name)
+	}
+	c := &checker{
+		template: t,
+		name:     name,
+		seen:     make(map[string]bool),
+		reported: make(map[CheckError]bool),
+	}
+	c.nodes(tree.Name, tree.Nodes, []reflect.Type{dataType})
+	if len(c.errs) > 0 {
+		return c.errs
+	}
+	return nil
+}
+
+// checker contains the state of a static check. A nil reflect.Type on the
+// type stack represents a value whose type is only known at render time.
+type checker struct {
+	template *Template
+	name     string          // the name of the template being checked
+	seen     map[string]bool // partials checked, by name and stack
+	reported map[CheckError]bool
+	errs     CheckErrors
+	each     int // depth of each blocks, whose bodies resolve @index and so on
+}
+
+func (c *checker) error(name string, ln, col int, key, msg string) {
+	e := CheckError{Name: name, Line: ln, Column: col, Key: key, Msg: msg}
+	if c.reported[e] {
+		return
+	}
+	c.reported[e] = true
+	c.errs = append(c.errs, &e)
+}
+
+func (c *checker) nodes(treeName string, nodes []ast.Node, stack []reflect.Type) {
+	for _, node := range nodes {
+		c.node(treeName, node, stack)
+	}
+}
+
+func (c *checker) node(treeName string, node ast.Node, stack []reflect.Type) {
+	switch n := node.(type) {
+	case *ast.Variable:
+		if n.Args != nil {
+			c.call(treeName, n, stack)
+			return
+		}
+		if !c.variable(n, stack) {
+			key := fallbackKeys(n)
+			c.error(treeName, n.Line, n.Column, key, "cannot find value "+key+" in context")
+		}
+
+	case *ast.Section:
+		typ, ok := c.lookup(n.Key, stack)
+		if !ok {
+			key := strings.Join(n.Key, ".")
+			c.error(treeName, n.Line, n.Column, key, "cannot find value "+key+" in context")
+			typ = nil
+		}
+		nodes, inverse := n.Branches()
+		defer c.nodes(treeName, inverse, stack)
+		// helpers, if and unless render the section against the current
+		// stack.
+		if n.Inverted || typ == helperType || n.Block == "if" || n.Block == "unless" {
+			c.nodes(treeName, nodes, stack)
+			return
+		}
+		typ, skip := truthyType(typ)
+		if skip {
+			return
+		}
+		if typ != nil {
+			switch typ.Kind() {
+			case reflect.Slice, reflect.Array:
+				if n.Block != "with" {
+					typ = typ.Elem()
+				}
+			case reflect.Map:
+				if n.Block == "each" {
+					typ = typ.Elem()
+				}
+			}
+		}
+		if n.Block == "each" {
+			c.each++
+			defer func() { c.each-- }()
+		}
+		c.nodes(treeName, nodes, pushType(stack, typ))
+
+	case *ast.Partial:
+		name := n.Resolve(c.name)
+		tree, ok := c.template.treeMap[name]
+		if !ok {
+			c.error(treeName, n.Line, n.Column, n.Key, "partial not found: "+n.Key)
+			return
+		}
+		sig := name + stackSignature(stack)
+		if c.seen[sig] {
+			return
+		}
+		c.seen[sig] = true
+		from := c.name
+		c.name = name
+		c.nodes(tree.Name, tree.Nodes, stack)
+		c.name = from
+	}
+}
+
+// variable reports whether a variable can be resolved: its key, or one of
+// its fallbacks, is found, or it has a string fallback.
+func (c *checker) variable(n *ast.Variable, stack []reflect.Type) bool {
+	if _, ok := c.lookup(n.Key, stack); ok {
+		return true
+	}
+	for _, fb := range n.Fallbacks {
+		if fb.Key == nil {
+			return true
+		}
+		if _, ok := c.lookup(fb.Key, stack); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// call checks a helper call: its function is registered, and its arguments
+// can be resolved.
+func (c *checker) call(treeName string, n *ast.Variable, stack []reflect.Type) {
+	if _, ok := c.template.funcs[n.Key[0]]; !ok {
+		c.error(treeName, n.Line, n.Column, n.Key[0], "function not registered: "+n.Key[0])
+	}
+	for _, arg := range n.Args {
+		if arg.Key == nil {
+			continue
+		}
+		if _, ok := c.lookup(arg.Key, stack); !ok {
+			key := strings.Join(arg.Key, ".")
+			c.error(treeName, n.Line, n.Column, key, "cannot find value "+key+" in context")
+		}
+	}
+}
+
+// lookup resolves the type of a dotted key against a stack of types, falling
+// back to the helpers of the template as rendering does. Within each blocks,
+// @index, @key, @first and @last resolve to types known at render time.
+func (c *checker) lookup(key []string, stack []reflect.Type) (reflect.Type, bool) {
+	if typ, ok := lookupKeysType(key, stack); ok {
+		return typ, true
+	}
+	if c.each > 0 && len(key) == 1 && isEachData(key[0]) {
+		return nil, true
+	}
+	if len(key) == 1 && c.template.helpers[key[0]] != nil {
+		return helperType, true
+	}
+	return nil, false
+}
+
+// isEachData reports whether a key is one of the data of each blocks.
+func isEachData(key string) bool {
+	switch key {
+	case "@index", "@key", "@first", "@last":
+		return true
+	}
+	return false
+}
+
+// pushType returns a copy of stack with typ pushed onto it. Copying keeps
+// the stacks of sibling sections independent.
+func pushType(stack []reflect.Type, typ reflect.Type) []reflect.Type {
+	s := make([]reflect.Type, len(stack)+1)
+	copy(s, stack)
+	s[len(stack)] = typ
+	return s
+}
+
+// stackSignature identifies the distinct types on a stack, innermost first.
+// Recursive partials push the same types repeatedly, so the signature
+// eventually repeats and checking terminates.
+func stackSignature(stack []reflect.Type) string {
+	var b strings.Builder
+	seen := make(map[reflect.Type]bool)
+	for i := len(stack) - 1; i >= 0; i-- {
+		if seen[stack[i]] {
+			continue
+		}
+		seen[stack[i]] = true
+		b.WriteString("|")
+		if stack[i] == nil {
+			b.WriteString("?")
+		} else {
+			b.WriteString(stack[i].String())
+		}
+	}
+	return b.String()
+}
+
+// lookupKeysType resolves the type of a dotted key against a stack of types,
+// mirroring lookupKeysStack.
+func lookupKeysType(key []string, stack []reflect.Type) (reflect.Type, bool) {
+	var typ reflect.Type
+	var ok bool
+	for i := range key {
+		if i == 0 {
+			typ, ok = lookupKeyStackType(key[i], stack)
+		} else {
+			typ, ok = lookupKeyType(key[i], typ)
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+	return typ, ok
+}
+
+// lookupKeyStackType resolves the type of a key in the first context on the
+// stack that can contain it, mirroring lookupKeyStack.
+func lookupKeyStackType(key string, stack []reflect.Type) (reflect.Type, bool) {
+	for i := len(stack) - 1; i >= 0; i-- {
+		if typ, ok := lookupKeyType(key, stack[i]); ok {
+			return typ, true
+		}
+	}
+	return nil, false
+}
+
+// lookupKeyType resolves the type of a key in a context of type typ,
+// mirroring lookupKeyContext. A nil type resolves every key to a nil type.
+func lookupKeyType(key string, typ reflect.Type) (reflect.Type, bool) {
+	if key == "." || typ == nil {
+		return typ, true
+	}
+
+	// check context for method by name
+	if m, ok := typ.MethodByName(key); ok {
+		if typ.Kind() == reflect.Interface {
+			return m.Type, true
+		}
+		return methodValueType(m.Type), true
+	}
+
+	// check for fields and keys on concrete types.
+	switch typ.Kind() {
+	case reflect.Ptr:
+		return lookupKeyType(key, typ.Elem())
+	case reflect.Interface:
+		return nil, true
+	case reflect.Map:
+		if typ.Key().Kind() != reflect.String {
+			return nil, false
+		}
+		return typ.Elem(), true
+	case reflect.Struct:
+		f, ok := typ.FieldByName(key)
+		if !ok {
+			return nil, false
+		}
+		return f.Type, true
+	default:
+		return nil, false
+	}
+}
+
+// methodValueType returns the type of a method value: the method's function
+// type without its receiver.
+func methodValueType(fn reflect.Type) reflect.Type {
+	in := make([]reflect.Type, fn.NumIn()-1)
+	for i := range in {
+		in[i] = fn.In(i + 1)
+	}
+	out := make([]reflect.Type, fn.NumOut())
+	for i := range out {
+		out[i] = fn.Out(i)
+	}
+	return reflect.FuncOf(in, out, fn.IsVariadic())
+}
+
+// truthyType returns the type of the value a section pushes onto the
+// context stack, mirroring toTruthyValue. skip is true when the section
+// body is never rendered against the context: lambdas receive the section
+// text unrendered, and other functions are always falsy.
+func truthyType(typ reflect.Type) (t reflect.Type, skip bool) {
+	if typ == nil {
+		return nil, false
+	}
+	switch typ.Kind() {
+	case reflect.Func:
+		isArity0 := typ.NumIn() == 0 && typ.NumOut() == 1
+		if isArity0 {
+			return truthyType(typ.Out(0))
+		}
+		return typ, true
+	case reflect.Ptr:
+		return truthyType(typ.Elem())
+	case reflect.Interface:
+		return nil, false
+	default:
+		return typ, false
+	}
+}
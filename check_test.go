@@ -0,0 +1,155 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package mustache_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/eriklott/mustache"
+)
+
+type checkUser struct {
+	Name    string
+	Email   string
+	Friends []*checkUser
+	Meta    map[string]int
+	Extra   interface{}
+}
+
+func (u checkUser) Greeting() string { return "Hello " + u.Name }
+
+func (u *checkUser) Upper(text string) string { return text }
+
+type checkData struct {
+	User  *checkUser
+	Users []checkUser
+	Title string
+}
+
+func TestTemplate_Check(t *testing.T) {
+	tt := []struct {
+		name       string
+		text       string
+		partials   map[string]string
+		data       reflect.Type
+		handlebars bool
+		errs       []string
+	}{
+		{
+			name: "Fields",
+			text: "{{Title}} {{User.Name}} {{#User}}{{Email}}{{/User}}",
+			data: reflect.TypeOf(checkData{}),
+		},
+		{
+			name: "Missing",
+			text: "{{Title}}\n{{user.emial}} {{User.Emial}}",
+			data: reflect.TypeOf(checkData{}),
+			errs: []string{
+				"main:2:1: cannot find value user.emial in context",
+				"main:2:16: cannot find value User.Emial in context",
+			},
+		},
+		{
+			name: "Slices",
+			text: "{{#Users}}{{Name}}{{#Friends}}{{Name}} {{Title}} {{Nope}}{{/Friends}}{{/Users}}",
+			data: reflect.TypeOf(&checkData{}),
+			errs: []string{"main:1:50: cannot find value Nope in context"},
+		},
+		{
+			// sections dereference pointers, so pointer methods are not found.
+			name: "Methods",
+			text: "{{#User}}{{Greeting}}{{#Upper}}{{anything}}{{/Upper}}{{/User}}{{#Users}}{{Upper}}{{/Users}}",
+			data: reflect.TypeOf(checkData{}),
+			errs: []string{
+				"main:1:22: cannot find value Upper in context",
+				"main:1:73: cannot find value Upper in context",
+			},
+		},
+		{
+			name: "Dynamic",
+			text: "{{#User}}{{Meta.anything}}{{Extra.anything.at.all}}{{#Extra}}{{whatever}}{{/Extra}}{{/User}}",
+			data: reflect.TypeOf(checkData{}),
+		},
+		{
+			name: "Inverted",
+			text: "{{^User}}{{Title}}{{Missing}}{{/User}}",
+			data: reflect.TypeOf(checkData{}),
+			errs: []string{"main:1:19: cannot find value Missing in context"},
+		},
+		{
+			name:     "Partials",
+			text:     "{{#User}}{{>user}}{{/User}}{{>missing}}",
+			partials: map[string]string{"user": "{{Name}}{{#Friends}}{{>user}}{{/Friends}}{{Title}}{{Age}}"},
+			data:     reflect.TypeOf(checkData{}),
+			errs: []string{
+				"user:1:51: cannot find value Age in context",
+				"main:1:28: partial not found: missing",
+			},
+		},
+		{
+			name: "Fallbacks",
+			text: "{{Nope ?? Title}}{{Nope ?? \"x\"}}{{Nope ?? User.Nope}}",
+			data: reflect.TypeOf(checkData{}),
+			errs: []string{"main:1:33: cannot find value Nope ?? User.Nope in context"},
+		},
+		{
+			name: "Helpers",
+			text: "{{#upper}}{{Title}}{{Nope}}{{/upper}}{{upper}}{{upper.x}}",
+			data: reflect.TypeOf(checkData{}),
+			errs: []string{
+				"main:1:20: cannot find value Nope in context",
+				"main:1:47: cannot find value upper.x in context",
+			},
+		},
+		{
+			name:       "Handlebars",
+			text:       "{{#if User}}{{Title}}{{Name}}{{/if}}{{#with User}}{{Name}}{{else}}{{Title}}{{Email}}{{/with}}{{#each Users}}{{@index}}{{Email}}{{else}}{{@key}}{{/each}}{{#each User.Meta}}{{.}}{{/each}}{{join Title User.Nope}}{{nope 1}}",
+			data:       reflect.TypeOf(checkData{}),
+			handlebars: true,
+			errs: []string{
+				"main:1:22: cannot find value Name in context",
+				"main:1:76: cannot find value Email in context",
+				"main:1:136: cannot find value @key in context",
+				"main:1:186: cannot find value User.Nope in context",
+				"main:1:210: function not registered: nope",
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl := mustache.NewTemplate()
+			tmpl.RegisterHelper("upper", func(s string) string { return s })
+			tmpl.RegisterFunc("join", func(a ...string) string { return "" })
+			tmpl.ParseOptions.Handlebars = tc.handlebars
+			err := tmpl.Parse("main", tc.text)
+			if err != nil {
+				t.Fatalf("failed to parse template: %v", err)
+			}
+			for key, partial := range tc.partials {
+				err := tmpl.Parse(key, partial)
+				if err != nil {
+					t.Fatalf("failed to parse partial: %v", err)
+				}
+			}
+
+			err = tmpl.Check("main", tc.data)
+			var got []string
+			var errs mustache.CheckErrors
+			if errors.As(err, &errs) {
+				for _, e := range errs {
+					got = append(got, e.Error())
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.errs) {
+				t.Errorf("unexpected errors, got:%q, want:%q", got, tc.errs)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/eriklott/mustache"
+)
+
+var docCommand = &command{
+	name:  "doc",
+	short: "generate a catalogue of templates",
+	run:   runDoc,
+}
+
+func runDoc(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("doc", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	syntax := syntaxFlags(flags)
+	format := flags.String("format", "markdown", "output `format`: markdown or html")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: mustache doc [flags] path ...")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Doc prints a catalogue of templates, listing the description, parameters")
+		fmt.Fprintln(stderr, "and partials documented by the doc comments at the top of each template,")
+		fmt.Fprintln(stderr, "along with the keys it references and the partials it includes. Directories")
+		fmt.Fprintln(stderr, "are processed recursively, and each "+templateExt+" file within them is")
+		fmt.Fprintln(stderr, "available as a partial named by its path relative to the directory, without")
+		fmt.Fprintln(stderr, "extension.")
+		fmt.Fprintln(stderr)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() == 0 || (*format != "markdown" && *format != "html") {
+		flags.Usage()
+		return exitError
+	}
+
+	tmpl, err := loadTemplates(flags.Args(), *syntax)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	entries, err := docEntries(tmpl)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	if *format == "html" {
+		err = htmlDoc.Execute(stdout, entries)
+	} else {
+		err = markdownDoc.Execute(stdout, entries)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "mustache doc: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// docEntry is the catalogue entry of a template.
+type docEntry struct {
+	*mustache.Doc
+	Keys     []string // keys referenced by the template and its partials
+	Includes []string // templates included by partial tags
+}
+
+// docEntries returns the catalogue entries of the templates, sorted by name.
+func docEntries(tmpl *mustache.Template) ([]docEntry, error) {
+	g := tmpl.PartialGraph()
+	var entries []docEntry
+	for _, name := range g.Nodes {
+		doc, err := tmpl.Doc(name)
+		if err != nil {
+			return nil, err
+		}
+		refs, err := tmpl.References(name)
+		if err != nil {
+			return nil, err
+		}
+		e := docEntry{Doc: doc}
+		seen := make(map[string]bool)
+		for _, ref := range refs {
+			key := strings.Join(ref.Key, ".")
+			if !seen[key] {
+				seen[key] = true
+				e.Keys = append(e.Keys, key)
+			}
+		}
+		seen = make(map[string]bool)
+		for _, edge := range g.Edges {
+			if edge.From == name && !seen[edge.To] {
+				seen[edge.To] = true
+				e.Includes = append(e.Includes, edge.To)
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+var markdownDoc = template.Must(template.New("markdown").Parse(`# Templates
+{{range .}}
+## {{.Name}}
+{{if .Description}}
+{{.Description}}
+{{end}}{{if .Params}}
+### Parameters
+
+| Name | Type | Description |
+| --- | --- | --- |
+{{range .Params}}| {{.Name}} | {{.Type}} | {{.Description}} |
+{{end}}{{end}}{{if .Partials}}
+### Documented partials
+
+{{range .Partials}}- {{.Name}}{{if .Description}}: {{.Description}}{{end}}
+{{end}}{{end}}{{if .Keys}}
+### Keys
+
+{{range .Keys}}- {{.}}
+{{end}}{{end}}{{if .Includes}}
+### Includes
+
+{{range .Includes}}- [{{.}}](#{{.}})
+{{end}}{{end}}{{end}}`))
+
+var htmlDoc = htmltemplate.Must(htmltemplate.New("html").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Templates</title>
+</head>
+<body>
+<h1>Templates</h1>
+{{range .}}
+<section id="{{.Name}}">
+<h2>{{.Name}}</h2>
+{{if .Description}}<p>{{.Description}}</p>
+{{end}}{{if .Params}}<h3>Parameters</h3>
+<table>
+<tr><th>Name</th><th>Type</th><th>Description</th></tr>
+{{range .Params}}<tr><td>{{.Name}}</td><td>{{.Type}}</td><td>{{.Description}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Partials}}<h3>Documented partials</h3>
+<ul>
+{{range .Partials}}<li>{{.Name}}{{if .Description}}: {{.Description}}{{end}}</li>
+{{end}}</ul>
+{{end}}{{if .Keys}}<h3>Keys</h3>
+<ul>
+{{range .Keys}}<li><code>{{.}}</code></li>
+{{end}}</ul>
+{{end}}{{if .Includes}}<h3>Includes</h3>
+<ul>
+{{range .Includes}}<li><a href="#{{.}}">{{.}}</a></li>
+{{end}}</ul>
+{{end}}</section>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,74 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDoc(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mustache-doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"welcome.mustache": "{{! Welcome email.\n@param user User - the <recipient>\n@partial footer }}\nHi {{user.name}}{{>footer}}{{>footer}}\n",
+		"footer.mustache":  "{{#links}}{{url}}{{/links}}",
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stdout, stderr strings.Builder
+	code := run([]string{"doc", dir}, nil, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+	}
+	want := "# Templates\n\n" +
+		"## footer\n\n### Keys\n\n- links\n- url\n\n" +
+		"## welcome\n\nWelcome email.\n\n" +
+		"### Parameters\n\n| Name | Type | Description |\n| --- | --- | --- |\n| user | User | the <recipient> |\n\n" +
+		"### Documented partials\n\n- footer\n\n" +
+		"### Keys\n\n- user.name\n- links\n- url\n\n" +
+		"### Includes\n\n- [footer](#footer)\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("unexpected output, got:%q, want:%q", got, want)
+	}
+
+	stdout.Reset()
+	code = run([]string{"doc", "-format", "html", dir}, nil, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+	}
+	for _, s := range []string{
+		`<section id="welcome">`,
+		"<td>the &lt;recipient&gt;</td>",
+		`<li><a href="#footer">footer</a></li>`,
+	} {
+		if !strings.Contains(stdout.String(), s) {
+			t.Errorf("output does not contain %q:\n%s", s, stdout.String())
+		}
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "bad.mustache"), []byte("{{! @param }}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stderr.Reset()
+	code = run([]string{"doc", dir}, nil, &stdout, &stderr)
+	if code != exitError {
+		t.Fatalf("unexpected exit code %d", code)
+	}
+	if got, want := stderr.String(), filepath.Join(dir, "bad.mustache")+":1:1: missing name in @param\n"; got != want {
+		t.Errorf("unexpected error, got:%q, want:%q", got, want)
+	}
+}
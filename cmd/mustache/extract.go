@@ -0,0 +1,111 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/eriklott/mustache/i18n"
+	"github.com/eriklott/mustache/parse"
+)
+
+var extractCommand = &command{
+	name:  "extract",
+	short: "extract translatable messages from templates",
+	run:   runExtract,
+}
+
+func runExtract(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("extract", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	syntax := syntaxFlags(flags)
+	format := flags.String("format", "", "output `format`: json or po (default the format of the -update catalog, or json)")
+	locale := flags.String("locale", "", "`locale` of the catalog (default the locale of the -update catalog, or en)")
+	update := flags.String("update", "", "keep the translations of the catalog `file`")
+	write := flags.Bool("w", false, "write the catalog to the -update file instead of the standard output")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: mustache extract [flags] path ...")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Extract prints a catalog of the messages referenced in templates by")
+		fmt.Fprintln(stderr, "sections of the "+i18n.Tag+" lambda, for translators. Plural messages have a form")
+		fmt.Fprintln(stderr, "for each plural category of the locale. Directories are processed")
+		fmt.Fprintln(stderr, "recursively, for each "+templateExt+" file within them.")
+		fmt.Fprintln(stderr)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() == 0 || (*format != "" && *format != "json" && *format != "po") || (*write && *update == "") {
+		flags.Usage()
+		return exitError
+	}
+
+	var prev *i18n.Catalog
+	if *update != "" {
+		var err error
+		if prev, err = i18n.ReadFile(*update); err != nil {
+			fmt.Fprintf(stderr, "mustache extract: %v\n", err)
+			return exitError
+		}
+		if *format == "" {
+			*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*update)), ".")
+		}
+		if *locale == "" {
+			*locale = prev.Locale
+		}
+	}
+	if *format == "" {
+		*format = "json"
+	}
+	if *locale == "" {
+		*locale = "en"
+	}
+
+	var refs []i18n.Ref
+	err := walkTemplates(flags.Args(), func(path, name string) error {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		tree, err := parse.Parse(path, string(src), parse.DefaultLeftDelim, parse.DefaultRightDelim, *syntax)
+		if err != nil {
+			return err
+		}
+		r, err := i18n.Extract(tree)
+		refs = append(refs, r...)
+		return err
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "mustache extract: %v\n", err)
+		return exitError
+	}
+
+	var buf bytes.Buffer
+	c := i18n.Update(prev, *locale, refs)
+	if *format == "po" {
+		err = c.WritePO(&buf)
+	} else {
+		err = c.WriteJSON(&buf)
+	}
+	if err == nil {
+		if *write {
+			err = ioutil.WriteFile(*update, buf.Bytes(), 0644)
+		} else {
+			_, err = stdout.Write(buf.Bytes())
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "mustache extract: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
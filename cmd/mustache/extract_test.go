@@ -0,0 +1,85 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mustache-extract")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"templates/page.mustache":  "{{#t}}greeting.hello{{/t}}\n{{#t}}inbox.unread count{{/t}}\n",
+		"templates/other.mustache": "{{#t}}greeting.hello{{/t}}",
+		"fr.po":                    "msgid \"\"\nmsgstr \"Language: fr\\n\"\n\nmsgid \"greeting.hello\"\nmsgstr \"Bonjour\"\n\nmsgid \"old\"\nmsgstr \"Vieux\"\n",
+		"broken/page.mustache":     "{{#t}}a {{b}}{{/t}}",
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	page := filepath.Join(dir, "templates", "page.mustache")
+	other := filepath.Join(dir, "templates", "other.mustache")
+
+	var stdout, stderr strings.Builder
+	code := run([]string{"extract", filepath.Join(dir, "templates")}, nil, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+	}
+	want := "{\n\t\"greeting.hello\": \"\",\n\t\"inbox.unread\": {\n\t\t\"one\": \"\",\n\t\t\"other\": \"\"\n\t}\n}\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("unexpected output, got:%q, want:%q", got, want)
+	}
+
+	po := filepath.Join(dir, "fr.po")
+	stdout.Reset()
+	code = run([]string{"extract", "-update", po, "-w", page, other}, nil, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("unexpected output: %q", stdout.String())
+	}
+	b, err := ioutil.ReadFile(po)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "msgid \"\"\nmsgstr \"\"\n\"Language: fr\\n\"\n\"MIME-Version: 1.0\\n\"\n\"Content-Type: text/plain; charset=UTF-8\\n\"\n\"Content-Transfer-Encoding: 8bit\\n\"\n\n" +
+		"#: " + page + ":1\n#: " + other + ":1\nmsgid \"greeting.hello\"\nmsgstr \"Bonjour\"\n\n" +
+		"#: " + page + ":2\nmsgid \"inbox.unread\"\nmsgid_plural \"inbox.unread\"\nmsgstr[0] \"\"\nmsgstr[1] \"\"\nmsgstr[2] \"\"\n"
+	if got := string(b); got != want {
+		t.Errorf("unexpected catalog, got:%q, want:%q", got, want)
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	code = run([]string{"extract", filepath.Join(dir, "broken")}, nil, &stdout, &stderr)
+	if code != exitError {
+		t.Errorf("unexpected exit code %d", code)
+	}
+	want = "mustache extract: " + filepath.Join(dir, "broken", "page.mustache") + ":1:1: message reference contains tags\n"
+	if got := stderr.String(); got != want {
+		t.Errorf("unexpected error, got:%q, want:%q", got, want)
+	}
+
+	stderr.Reset()
+	if code := run([]string{"extract", "-w", page}, nil, &stdout, &stderr); code != exitError {
+		t.Errorf("unexpected exit code %d", code)
+	}
+}
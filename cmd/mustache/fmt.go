@@ -0,0 +1,148 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/eriklott/mustache/internal/diff"
+	"github.com/eriklott/mustache/parse"
+	"github.com/eriklott/mustache/printer"
+)
+
+var fmtCommand = &command{
+	name:  "fmt",
+	short: "format templates",
+	run:   runFmt,
+}
+
+// fmtIndent is the indentation per section depth of standalone tags.
+const fmtIndent = "  "
+
+// fmtOptions holds the flags of the fmt command.
+type fmtOptions struct {
+	list   bool
+	diff   bool
+	write  bool
+	syntax parse.Options
+}
+
+func runFmt(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var opts fmtOptions
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	syntax := syntaxFlags(flags)
+	flags.BoolVar(&opts.list, "l", false, "list files whose formatting differs from mustache fmt's")
+	flags.BoolVar(&opts.diff, "d", false, "display diffs instead of rewriting files")
+	flags.BoolVar(&opts.write, "w", false, "write result to (source) file instead of stdout")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: mustache fmt [flags] [path ...]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Fmt formats mustache templates. Directories are processed recursively,")
+		fmt.Fprintln(stderr, "formatting each "+templateExt+" file. With no path, it formats standard input.")
+		fmt.Fprintln(stderr, "Without -trim, a tag with a ~ next to its delimiters, such as {{~name~}}, is")
+		fmt.Fprintln(stderr, "ambiguous, and is reported as an error rather than rewritten.")
+		fmt.Fprintln(stderr)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	opts.syntax = *syntax
+
+	if flags.NArg() == 0 {
+		if opts.write {
+			fmt.Fprintln(stderr, "mustache fmt: cannot use -w with standard input")
+			return exitError
+		}
+		src, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "mustache fmt: %v\n", err)
+			return exitError
+		}
+		err = fmtSource("<standard input>", src, stdout, opts)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	code := exitOK
+	err := walkTemplates(flags.Args(), func(path, _ string) error {
+		err := fmtFile(path, stdout, opts)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			code = exitError
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "mustache fmt: %v\n", err)
+		code = exitError
+	}
+	return code
+}
+
+// fmtFile formats a template file.
+func fmtFile(path string, stdout io.Writer, opts fmtOptions) error {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return fmtSource(path, src, stdout, opts)
+}
+
+// fmtSource formats the template source read from path, and reports the
+// result as directed by opts.
+func fmtSource(path string, src []byte, stdout io.Writer, opts fmtOptions) error {
+	res, err := formatTemplate(path, src, opts.syntax)
+	if err != nil {
+		return err
+	}
+	changed := !bytes.Equal(src, res)
+
+	if opts.list && changed {
+		fmt.Fprintln(stdout, path)
+	}
+	if opts.write && changed {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(path, res, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+	}
+	if opts.diff && changed {
+		io.WriteString(stdout, diff.Unified(path+".orig", path, string(src), string(res)))
+	}
+	if !opts.list && !opts.write && !opts.diff {
+		_, err = stdout.Write(res)
+		return err
+	}
+	return nil
+}
+
+// formatTemplate returns the canonical formatting of a template source.
+func formatTemplate(name string, src []byte, opts parse.Options) ([]byte, error) {
+	tree, err := parse.Parse(name, string(src), parse.DefaultLeftDelim, parse.DefaultRightDelim, opts)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	cfg := printer.Config{Mode: printer.Canonical, Indent: fmtIndent, TrimMarkers: opts.TrimMarkers}
+	err = cfg.Fprint(&b, tree)
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
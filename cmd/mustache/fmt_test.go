@@ -0,0 +1,151 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFmt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mustache-fmt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"a.mustache":     "{{ name }}\n{{#items}}\n{{ . }}\n{{/items}}\n",
+		"b.mustache":     "{{name}}\n",
+		"sub/c.mustache": "{{! note}}\n",
+		"d.txt":          "{{ ignored }}",
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stdout, stderr strings.Builder
+	code := run([]string{"fmt", "-l", dir}, nil, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+	}
+	want := filepath.Join(dir, "a.mustache") + "\n" + filepath.Join(dir, "sub", "c.mustache") + "\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("unexpected -l output, got:%q, want:%q", got, want)
+	}
+
+	stdout.Reset()
+	code = run([]string{"fmt", "-d", filepath.Join(dir, "b.mustache"), filepath.Join(dir, "sub")}, nil, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+	}
+	if got := stdout.String(); !strings.Contains(got, "-{{! note}}\n+{{! note }}\n") {
+		t.Errorf("unexpected -d output: %q", got)
+	}
+
+	code = run([]string{"fmt", "-w", dir}, nil, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "a.mustache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "{{name}}\n{{#items}}\n{{.}}\n{{/items}}\n"; got != want {
+		t.Errorf("unexpected -w result, got:%q, want:%q", got, want)
+	}
+	b, err = ioutil.ReadFile(filepath.Join(dir, "d.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); got != files["d.txt"] {
+		t.Errorf("non-template file was rewritten: %q", got)
+	}
+}
+
+func TestFmt_Stdin(t *testing.T) {
+	var stdout, stderr strings.Builder
+	code := run([]string{"fmt"}, strings.NewReader("{{# a }}\n{{ b }}\n{{/ a }}\n"), &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+	}
+	if got, want := stdout.String(), "{{#a}}\n{{b}}\n{{/a}}\n"; got != want {
+		t.Errorf("unexpected output, got:%q, want:%q", got, want)
+	}
+}
+
+func TestFmt_ParseError(t *testing.T) {
+	var stdout, stderr strings.Builder
+	code := run([]string{"fmt"}, strings.NewReader("{{#a}}"), &stdout, &stderr)
+	if code != exitError {
+		t.Fatalf("unexpected exit code %d", code)
+	}
+	if got, want := stderr.String(), "<standard input>:1:1: unclosed section tag: a\n"; got != want {
+		t.Errorf("unexpected error, got:%q, want:%q", got, want)
+	}
+}
+
+func TestFmt_TrimMarkers(t *testing.T) {
+	tt := []struct {
+		name string
+		args []string
+		src  string
+		code int
+		want string
+		err  string
+	}{
+		{"RoundTrip", []string{"fmt", "-trim"}, "a  {{~x~}}  b\n", exitOK, "a  {{~x~}}  b\n", ""},
+		{"Canonical", []string{"fmt", "-trim"}, "{{# s ~}}\n  {{~ x }}\n{{/s}}\n", exitOK, "{{#s~}}\n  {{~x}}\n{{/s}}\n", ""},
+		{"Ambiguous", []string{"fmt"}, "a  {{~x~}}  b\n", exitError, "", "<standard input>:1:4: ambiguous tag {{~x~}}: ~ next to a delimiter is a trim marker when trim markers are enabled\n"},
+		{"Separated", []string{"fmt"}, "{{ ~x~ }}\n", exitOK, "{{ ~x~ }}\n", ""},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr strings.Builder
+			code := run(tc.args, strings.NewReader(tc.src), &stdout, &stderr)
+			if code != tc.code {
+				t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+			}
+			if got := stdout.String(); got != tc.want {
+				t.Errorf("unexpected output, got:%q, want:%q", got, tc.want)
+			}
+			if got := stderr.String(); got != tc.err {
+				t.Errorf("unexpected error, got:%q, want:%q", got, tc.err)
+			}
+		})
+	}
+}
+
+func TestFmt_Handlebars(t *testing.T) {
+	var stdout, stderr strings.Builder
+	src := "{{# if ok }}\n{{ name }}\n{{ else }}\n{{#each items}}\n{{ this.id }}\n{{/each}}\n{{/ if }}\n"
+	code := run([]string{"fmt", "-handlebars"}, strings.NewReader(src), &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+	}
+	want := "{{#if ok}}\n{{name}}\n{{else}}\n  {{#each items}}\n{{this.id}}\n  {{/each}}\n{{/if}}\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("unexpected output, got:%q, want:%q", got, want)
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	code = run([]string{"fmt"}, strings.NewReader(src), &stdout, &stderr)
+	if code != exitError {
+		t.Fatalf("unexpected exit code %d", code)
+	}
+	if got, want := stderr.String(), "<standard input>:1:1: invalid key: if ok\n"; got != want {
+		t.Errorf("unexpected error, got:%q, want:%q", got, want)
+	}
+}
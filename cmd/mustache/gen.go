@@ -0,0 +1,110 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/eriklott/mustache/codegen"
+)
+
+var genCommand = &command{
+	name:  "gen",
+	short: "generate Go code rendering a template",
+	run:   runGen,
+}
+
+func runGen(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("gen", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	syntax := syntaxFlags(flags)
+	typeExpr := flags.String("type", "", "Go `type` of the data, such as *WelcomeData")
+	funcName := flags.String("func", "", "`name` of the generated function")
+	name := flags.String("template", "", "`name` of the template to render; defaults to the name of the only template file")
+	output := flags.String("o", "", "output `file`; defaults to <template>_mustache.go")
+	strict := flags.Bool("strict", false, "fail on keys and partials that cannot be resolved")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: mustache gen -type type -func name [flags] path ...")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Gen generates a Go function rendering a template to an io.Writer without")
+		fmt.Fprintln(stderr, "reflection. The type is resolved in the Go package of the output file,")
+		fmt.Fprintln(stderr, "which the function is added to. Directories are processed recursively, and")
+		fmt.Fprintln(stderr, "each "+templateExt+" file within them is available as a partial named by its")
+		fmt.Fprintln(stderr, "path relative to the directory, without extension. Gen is typically run by")
+		fmt.Fprintln(stderr, "a go:generate directive:")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "\t//go:generate mustache gen -type *WelcomeData -func RenderWelcome welcome.mustache")
+		fmt.Fprintln(stderr)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() == 0 || *typeExpr == "" || *funcName == "" {
+		flags.Usage()
+		return exitError
+	}
+
+	if *name == "" {
+		if flags.NArg() != 1 || !isFile(flags.Arg(0)) {
+			fmt.Fprintln(stderr, "mustache gen: -template is required unless a single template file is given")
+			return exitError
+		}
+		*name = templateName(filepath.Base(flags.Arg(0)))
+	}
+	if *output == "" {
+		*output = strings.Replace(*name, "/", "_", -1) + "_mustache.go"
+	}
+
+	tmpl, err := loadTemplates(flags.Args(), *syntax)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	tmpl.ContextErrorsEnabled = *strict
+
+	pkg, err := codegen.LoadPackage(filepath.Dir(*output), *output)
+	if err != nil {
+		fmt.Fprintf(stderr, "mustache gen: %v\n", err)
+		return exitError
+	}
+	tv, err := types.Eval(token.NewFileSet(), pkg, token.NoPos, *typeExpr)
+	if err != nil {
+		fmt.Fprintf(stderr, "mustache gen: %v\n", err)
+		return exitError
+	}
+	if !tv.IsType() {
+		fmt.Fprintf(stderr, "mustache gen: %s is not a type\n", *typeExpr)
+		return exitError
+	}
+
+	var buf bytes.Buffer
+	err = codegen.Generate(&buf, tmpl, pkg, codegen.Func{Name: *funcName, Template: *name, Type: tv.Type})
+	if err != nil {
+		fmt.Fprintf(stderr, "mustache gen: %v\n", err)
+		return exitError
+	}
+	if err := ioutil.WriteFile(*output, buf.Bytes(), 0666); err != nil {
+		fmt.Fprintf(stderr, "mustache gen: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// isFile reports whether path names an existing file that is not a
+// directory.
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
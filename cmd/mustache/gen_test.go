@@ -0,0 +1,56 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mustache-gen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"data.go":           "package welcome\n\ntype Data struct {\n\tName string\n}\n",
+		"welcome.mustache":  "Hello {{Name}}{{Missing}}!\n",
+		"stale_mustache.go": "package welcome\n\nvar broken = undefined\n",
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stdout, stderr strings.Builder
+	out := filepath.Join(dir, "stale_mustache.go")
+	code := run([]string{"gen", "-type", "*Data", "-func", "RenderWelcome", "-o", out, filepath.Join(dir, "welcome.mustache")}, nil, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+	}
+	src, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "func RenderWelcome(w io.Writer, data *Data) error {"; !strings.Contains(string(src), want) {
+		t.Errorf("generated code does not contain %q:\n%s", want, src)
+	}
+
+	stderr.Reset()
+	code = run([]string{"gen", "-type", "*Data", "-func", "RenderWelcome", "-o", out, "-strict", filepath.Join(dir, "welcome.mustache")}, nil, &stdout, &stderr)
+	if code != exitError {
+		t.Fatalf("unexpected exit code %d", code)
+	}
+	want := "mustache gen: " + filepath.Join(dir, "welcome.mustache") + ":1:15: cannot find value Missing in context\n"
+	if got := stderr.String(); got != want {
+		t.Errorf("unexpected error, got:%q, want:%q", got, want)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/eriklott/mustache"
+)
+
+var graphCommand = &command{
+	name:  "graph",
+	short: "print the partial graph of templates",
+	run:   runGraph,
+}
+
+func runGraph(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("graph", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	syntax := syntaxFlags(flags)
+	format := flags.String("format", "dot", "output `format`: dot or json")
+	validate := flags.Bool("validate", false, "report missing and recursive partials instead of printing the graph")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: mustache graph [flags] path ...")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Graph prints the graph of partial tags between templates. Directories are")
+		fmt.Fprintln(stderr, "processed recursively, and each "+templateExt+" file within them is available")
+		fmt.Fprintln(stderr, "as a partial named by its path relative to the directory, without extension.")
+		fmt.Fprintln(stderr)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() == 0 || (*format != "dot" && *format != "json") {
+		flags.Usage()
+		return exitError
+	}
+
+	tmpl, err := loadTemplates(flags.Args(), *syntax)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	if *validate {
+		err := tmpl.Validate()
+		var errs mustache.PartialErrors
+		if errors.As(err, &errs) {
+			for _, e := range errs {
+				fmt.Fprintln(stdout, e)
+			}
+			return exitFail
+		}
+		return exitOK
+	}
+
+	g := tmpl.PartialGraph()
+	if *format == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "\t")
+		err = enc.Encode(g)
+	} else {
+		err = g.WriteDOT(stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "mustache graph: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
@@ -0,0 +1,52 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGraph(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mustache-graph")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"page.mustache":   "{{>header}}{{>footer}}",
+		"header.mustache": "{{>page}}",
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stdout, stderr strings.Builder
+	code := run([]string{"graph", dir}, nil, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+	}
+	want := "digraph partials {\n\t\"header\";\n\t\"page\";\n\t\"header\" -> \"page\";\n\t\"page\" -> \"header\";\n\t\"page\" -> \"footer\" [style=dashed];\n}\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("unexpected output, got:%q, want:%q", got, want)
+	}
+
+	stdout.Reset()
+	code = run([]string{"graph", "-validate", dir}, nil, &stdout, &stderr)
+	if code != exitFail {
+		t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+	}
+	want = filepath.Join(dir, "page.mustache") + ":1:12: partial not found: footer\n" +
+		filepath.Join(dir, "page.mustache") + ":1:1: recursive partial: header > page > header\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("unexpected output, got:%q, want:%q", got, want)
+	}
+}
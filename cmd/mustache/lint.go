@@ -0,0 +1,96 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/eriklott/mustache/lint"
+	"github.com/eriklott/mustache/parse"
+)
+
+var lintCommand = &command{
+	name:  "lint",
+	short: "report suspicious constructs in templates",
+	run:   runLint,
+}
+
+// lintConfigFile is the configuration file used when the -config flag is not set.
+const lintConfigFile = ".mustachelint.json"
+
+func runLint(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	syntax := syntaxFlags(flags)
+	configPath := flags.String("config", "", "read rule configuration from `file` (default "+lintConfigFile+" if present)")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: mustache lint [flags] path ...")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Lint reports suspicious constructs in mustache templates. Directories are")
+		fmt.Fprintln(stderr, "processed recursively, and each "+templateExt+" file within them is available")
+		fmt.Fprintln(stderr, "as a partial named by its path relative to the directory, without extension.")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Rules:")
+		for _, rule := range lint.Rules {
+			fmt.Fprintln(stderr, "\t"+rule)
+		}
+		fmt.Fprintln(stderr)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitError
+	}
+
+	var config lint.Config
+	path := *configPath
+	if path == "" {
+		if _, err := os.Stat(lintConfigFile); err == nil {
+			path = lintConfigFile
+		}
+	}
+	if path != "" {
+		var err error
+		config, err = lint.LoadConfig(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "mustache lint: %v\n", err)
+			return exitError
+		}
+	}
+
+	code := exitOK
+	linter := lint.New(config)
+	err := walkTemplates(flags.Args(), func(path, name string) error {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		tree, err := parse.Parse(path, string(src), parse.DefaultLeftDelim, parse.DefaultRightDelim, *syntax)
+		if err != nil {
+			fmt.Fprintln(stdout, err)
+			code = exitFail
+			return nil
+		}
+		linter.Add(name, tree)
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "mustache lint: %v\n", err)
+		return exitError
+	}
+
+	for _, d := range linter.Lint() {
+		fmt.Fprintln(stdout, d)
+		code = exitFail
+	}
+	return code
+}
@@ -0,0 +1,71 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mustache-lint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"page.mustache":   "{{>header}}\n{{{body}}}\n{{>footer}}\n",
+		"header.mustache": "{{title}}\n",
+		"unused.mustache": "",
+		"broken.mustache": "{{#a}}",
+		"lint.json":       `{"partials": ["header", "unused"], "disable": ["unknown-partial"]}`,
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stdout, stderr strings.Builder
+	code := run([]string{"lint", "-config", filepath.Join(dir, "lint.json"), dir}, nil, &stdout, &stderr)
+	if code != exitFail {
+		t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+	}
+	want := filepath.Join(dir, "broken.mustache") + ":1:1: unclosed section tag: a\n" +
+		filepath.Join(dir, "page.mustache") + ":2:1: body is rendered without escaping (triple-stache)\n" +
+		filepath.Join(dir, "unused.mustache") + ":1:1: partial unused is never used (unused-partial)\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("unexpected output, got:%q, want:%q", got, want)
+	}
+}
+
+func TestLint_Handlebars(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mustache-lint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "page.mustache")
+	if err := ioutil.WriteFile(path, []byte("{{#if ok}}{{{body}}}{{else}}none{{/if}}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr strings.Builder
+	code := run([]string{"lint", "-handlebars", path}, nil, &stdout, &stderr)
+	if code != exitFail {
+		t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+	}
+	if got, want := stdout.String(), path+":1:11: body is rendered without escaping (triple-stache)\n"; got != want {
+		t.Errorf("unexpected output, got:%q, want:%q", got, want)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/eriklott/mustache/internal/lsp"
+)
+
+var lspCommand = &command{
+	name:  "lsp",
+	short: "run the language server",
+	run:   runLSP,
+}
+
+func runLSP(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	syntax := syntaxFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: mustache lsp [flags]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Lsp runs a Language Server Protocol server for mustache templates, speaking")
+		fmt.Fprintln(stderr, "the protocol over standard input and output. The "+templateExt+" files found")
+		fmt.Fprintln(stderr, "under the root folder of the client are available as partials, named by")
+		fmt.Fprintln(stderr, "their path relative to the root folder, without extension. The client may")
+		fmt.Fprintln(stderr, "override the syntax flags with the initialization options trimMarkers and")
+		fmt.Fprintln(stderr, "handlebars.")
+		fmt.Fprintln(stderr)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return exitError
+	}
+
+	if err := lsp.Serve(stdin, stdout, stderr, *syntax); err != nil {
+		fmt.Fprintf(stderr, "mustache lsp: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
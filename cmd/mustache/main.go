@@ -0,0 +1,195 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Command mustache provides tools for working with mustache templates.
+//
+// Usage:
+//
+//	mustache <command> [arguments]
+//
+// The commands are:
+//
+//	fmt     format templates
+//	lint    report suspicious constructs in templates
+//	graph   print the partial graph of templates
+//	gen     generate Go code rendering a template
+//	doc     generate a catalogue of templates
+//	extract extract translatable messages from templates
+//	lsp     run the language server
+//	render  render a template
+//
+// Use "mustache <command> -h" for more information about a command.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/eriklott/mustache"
+	"github.com/eriklott/mustache/parse"
+)
+
+// Exit codes
+const (
+	exitOK    = 0 // the command succeeded
+	exitFail  = 1 // the command ran, but reported problems
+	exitError = 2 // the command could not run
+)
+
+// command is a mustache subcommand.
+type command struct {
+	name  string
+	short string
+	run   func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
+}
+
+var commands = []*command{
+	fmtCommand,
+	lintCommand,
+	graphCommand,
+	genCommand,
+	docCommand,
+	extractCommand,
+	lspCommand,
+	renderCommand,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command named by the first argument and returns the
+// exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return exitError
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:], stdin, stdout, stderr)
+		}
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "-help" {
+		usage(stdout)
+		return exitOK
+	}
+	fmt.Fprintf(stderr, "mustache: unknown command %q\n", args[0])
+	usage(stderr)
+	return exitError
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage:")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "\tmustache <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "The commands are:")
+	fmt.Fprintln(w)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "\t%-8s%s\n", cmd.name, cmd.short)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Use "mustache <command> -h" for more information about a command.`)
+}
+
+// templateExt is the file extension of mustache templates found when walking
+// directories.
+const templateExt = ".mustache"
+
+// isTemplateFile reports whether a file found while walking a directory is
+// a mustache template.
+func isTemplateFile(info os.FileInfo) bool {
+	name := info.Name()
+	return info.Mode().IsRegular() && !strings.HasPrefix(name, ".") && filepath.Ext(name) == templateExt
+}
+
+// walkTemplates calls fn for each path. Directories are walked, and fn is
+// called for each template file within them. The name passed to fn is the
+// name used to include the template as a partial: the path of the file
+// relative to the walked directory, without its extension. Walking stops at
+// the first error returned by fn.
+func walkTemplates(paths []string, fn func(path, name string) error) error {
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			if err := fn(root, templateName(filepath.Base(root))); err != nil {
+				return err
+			}
+			continue
+		}
+		err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !isTemplateFile(info) {
+				return nil
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			return fn(path, templateName(rel))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// templateName returns the template name of a relative file path.
+func templateName(rel string) string {
+	return filepath.ToSlash(strings.TrimSuffix(rel, filepath.Ext(rel)))
+}
+
+// syntaxFlags defines the flags selecting the template syntax, shared by the
+// commands that parse templates, and returns the parse options they set.
+func syntaxFlags(flags *flag.FlagSet) *parse.Options {
+	opts := new(parse.Options)
+	flags.BoolVar(&opts.TrimMarkers, "trim", false, "enable trim markers, as in {{~name~}}")
+	flags.BoolVar(&opts.Handlebars, "handlebars", false, "enable Handlebars compatibility, as in {{#if ok}}...{{else}}...{{/if}}")
+	return opts
+}
+
+// loadTemplates parses the templates found at paths into a template set,
+// naming each as described by walkTemplates.
+func loadTemplates(paths []string, opts parse.Options) (*mustache.Template, error) {
+	tmpl := mustache.NewTemplate()
+	tmpl.ParseOptions = opts
+	err := addTemplates(tmpl, paths)
+	return tmpl, err
+}
+
+// addTemplates parses the templates found at paths into tmpl, naming each as
+// described by walkTemplates. Templates are parsed with the delimiters and
+// parse options of tmpl.
+func addTemplates(tmpl *mustache.Template, paths []string) error {
+	return walkTemplates(paths, func(path, name string) error {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return addTemplate(tmpl, path, name, src)
+	})
+}
+
+// addTemplate parses a template source into tmpl. Errors are positioned by
+// path, rather than by the template name.
+func addTemplate(tmpl *mustache.Template, path, name string, src []byte) error {
+	ldelim, rdelim := tmpl.Delims()
+	tree, err := parse.Parse(path, string(src), ldelim, rdelim, tmpl.ParseOptions)
+	if err != nil {
+		return err
+	}
+	return tmpl.AddTree(name, tree)
+}
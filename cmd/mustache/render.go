@@ -0,0 +1,189 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/eriklott/mustache"
+	"github.com/eriklott/mustache/data"
+)
+
+var renderCommand = &command{
+	name:  "render",
+	short: "render a template",
+	run:   runRender,
+}
+
+// escapeFuncs are the escape functions selected by the -escape flag. A nil
+// function selects the default HTML escaping.
+var escapeFuncs = map[string]func(string) string{
+	"html": nil,
+	"none": func(s string) string { return s },
+	"json": escapeJSON,
+}
+
+func runRender(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("render", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	syntax := syntaxFlags(flags)
+	var sources []dataSource
+	flags.Var(dataFlag{"data", &sources}, "data", "data `file` in JSON, YAML or TOML format, by extension")
+	flags.Var(dataFlag{"set", &sources}, "set", "set the value of a dotted `key=value`")
+	flags.Var(dataFlag{"env", &sources}, "env", "load data from the environment variables starting with `prefix`")
+	partials := flags.String("partials", "", "`directory` of partials")
+	delims := flags.String("delims", "", "custom `delimiters`, separated by a space, such as \"<% %>\"")
+	strict := flags.Bool("strict", false, "fail on keys and partials that cannot be resolved")
+	escape := flags.String("escape", "html", "escaping `mode` of variable tags: html, json or none")
+	output := flags.String("o", "", "output `file`; defaults to standard output")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: mustache render [flags] [template]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Render renders a template file, or standard input when no file is given.")
+		fmt.Fprintln(stderr, "Each "+templateExt+" file within the partials directory is available as a partial")
+		fmt.Fprintln(stderr, "named by its path relative to the directory, without extension. The exit")
+		fmt.Fprintln(stderr, "status is 1 if a template cannot be parsed or rendered, and 2 for other errors.")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "The -data, -set and -env flags may be repeated. Their data is merged in the")
+		fmt.Fprintln(stderr, "order given, with later values replacing earlier ones and objects merged")
+		fmt.Fprintln(stderr, "deeply. Environment variables are named by their keys in upper case, with")
+		fmt.Fprintln(stderr, "nested keys separated by a double underscore: with -env APP_, the variable")
+		fmt.Fprintln(stderr, "APP_USER__NAME sets user.name.")
+		fmt.Fprintln(stderr)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	escapeFunc, ok := escapeFuncs[*escape]
+	if flags.NArg() > 1 || !ok {
+		flags.Usage()
+		return exitError
+	}
+
+	tmpl := mustache.NewTemplate()
+	tmpl.ParseOptions = *syntax
+	tmpl.ContextErrorsEnabled = *strict
+	tmpl.Escape = escapeFunc
+	if *delims != "" {
+		parts := strings.Fields(*delims)
+		if len(parts) != 2 || strings.Contains(*delims, "=") {
+			fmt.Fprintf(stderr, "mustache render: invalid delimiters %q\n", *delims)
+			return exitError
+		}
+		tmpl.LeftDelim, tmpl.RightDelim = parts[0], parts[1]
+	}
+
+	// read the inputs. Errors reading files could not run the command, while
+	// errors in templates are reported as problems.
+	path := "<standard input>"
+	var src []byte
+	var err error
+	if flags.NArg() == 1 {
+		path = flags.Arg(0)
+		src, err = ioutil.ReadFile(path)
+	} else {
+		src, err = ioutil.ReadAll(stdin)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "mustache render: %v\n", err)
+		return exitError
+	}
+	var ctx interface{}
+	if len(sources) > 0 {
+		ctx, err = loadData(sources, os.Environ())
+		if err != nil {
+			fmt.Fprintf(stderr, "mustache render: %v\n", err)
+			return exitError
+		}
+	}
+	if *partials != "" {
+		if _, err := os.Stat(*partials); err != nil {
+			fmt.Fprintf(stderr, "mustache render: %v\n", err)
+			return exitError
+		}
+		if err := addTemplates(tmpl, []string{*partials}); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitFail
+		}
+	}
+
+	// the template is named by its path, which cannot be the name of a
+	// partial.
+	if err := addTemplate(tmpl, path, path, src); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitFail
+	}
+	out, err := tmpl.Render(path, ctx)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitFail
+	}
+
+	if *output != "" {
+		err = ioutil.WriteFile(*output, []byte(out), 0644)
+	} else {
+		_, err = io.WriteString(stdout, out)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "mustache render: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// dataSource is a source of data given by a -data, -set or -env flag.
+type dataSource struct {
+	flag  string
+	value string
+}
+
+// dataFlag is a flag adding data sources. The flags share a list of sources,
+// so that their data is merged in command line order.
+type dataFlag struct {
+	name    string
+	sources *[]dataSource
+}
+
+func (f dataFlag) String() string { return "" }
+
+func (f dataFlag) Set(s string) error {
+	*f.sources = append(*f.sources, dataSource{flag: f.name, value: s})
+	return nil
+}
+
+// loadData loads and merges the data of sources, in order.
+func loadData(sources []dataSource, environ []string) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	for _, src := range sources {
+		var v map[string]interface{}
+		var err error
+		switch src.flag {
+		case "data":
+			v, err = data.ReadFile(src.value)
+		case "set":
+			v, err = data.Assignment(src.value)
+		case "env":
+			v = data.Env(environ, src.value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		m = data.Merge(m, v)
+	}
+	return m, nil
+}
+
+// escapeJSON escapes s for inclusion in a JSON string.
+func escapeJSON(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b[1 : len(b)-1])
+}
@@ -0,0 +1,200 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mustache-render")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"page.mustache":                      "<h1>{{title}}</h1>\n{{>footer}}",
+		"delims.mustache":                    "<% title %>|<%> footer %>",
+		"partials/footer.mustache":           "{{#links}}<a>{{url}}</a>{{/links}}\n",
+		"partials/emails/signature.mustache": "-- {{>../footer}}",
+		"nested.mustache":                    "{{>emails/signature}}",
+		"data.json":                          `{"title": "A & B", "links": [{"url": "x"}, {"url": "y"}]}`,
+		"bad.json":                           `{"title": `,
+		"data.yaml":                          "title: YAML\nuser:\n  name: Ann\n  role: user\n",
+		"data.toml":                          "[user]\nrole = \"admin\"\n",
+		"data.txt":                           "title",
+		"handlebars/links.mustache":          "{{#each links}}<a>{{url}}</a>{{else}}none{{/each}}",
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	tt := []struct {
+		name   string
+		args   []string
+		stdin  string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{
+			name:   "File",
+			args:   []string{"-data", path("data.json"), "-partials", path("partials"), path("page.mustache")},
+			stdout: "<h1>A &amp; B</h1>\n<a>x</a><a>y</a>\n",
+		},
+		{
+			name:   "NestedPartials",
+			args:   []string{"-data", path("data.json"), "-partials", path("partials"), path("nested.mustache")},
+			stdout: "-- <a>x</a><a>y</a>\n",
+		},
+		{
+			name:   "Stdin",
+			args:   []string{"-data", path("data.json")},
+			stdin:  "{{title}}",
+			stdout: "A &amp; B",
+		},
+		{
+			name:   "Escape",
+			args:   []string{"-data", path("data.json"), "-escape", "none"},
+			stdin:  "{{title}}",
+			stdout: "A & B",
+		},
+		{
+			name:   "Trim",
+			args:   []string{"-trim", "-data", path("data.json")},
+			stdin:  "<p>\n  {{~title~}}\n</p>",
+			stdout: "<p>A &amp; B</p>",
+		},
+		{
+			name:   "Handlebars",
+			args:   []string{"-handlebars", "-data", path("data.json"), "-partials", path("handlebars")},
+			stdin:  "{{#if title}}{{title}}: {{else}}untitled{{/if}}{{>links}}",
+			stdout: "A &amp; B: <a>x</a><a>y</a>",
+		},
+		{
+			name:   "HandlebarsDisabled",
+			stdin:  "{{#if title}}{{title}}{{else}}untitled{{/if}}",
+			code:   exitFail,
+			stderr: "<standard input>:1:1: invalid key: if title\n",
+		},
+		{
+			name:   "EscapeJSON",
+			args:   []string{"-escape", "json"},
+			stdin:  `{"a": "{{.}}"}`,
+			stdout: `{"a": ""}`,
+		},
+		{
+			name:   "Delims",
+			args:   []string{"-data", path("data.json"), "-delims", "<% %>", "-partials", path("partials"), path("delims.mustache")},
+			stdout: "A &amp; B|{{#links}}<a>{{url}}</a>{{/links}}\n",
+		},
+		{
+			name:   "Strict",
+			args:   []string{"-strict"},
+			stdin:  "ab\n{{missing}}",
+			code:   exitFail,
+			stderr: "<standard input>:2:1: cannot find value missing in context\n",
+		},
+		{
+			name:   "ParseError",
+			stdin:  "{{#a}}",
+			code:   exitFail,
+			stderr: "<standard input>:1:1: unclosed section tag: a\n",
+		},
+		{
+			name:   "BadData",
+			args:   []string{"-data", path("bad.json")},
+			stdin:  "{{title}}",
+			code:   exitError,
+			stderr: "mustache render: " + path("bad.json") + ":1:10: unexpected end of JSON input\n",
+		},
+		{
+			name:   "UnknownData",
+			args:   []string{"-data", path("data.txt")},
+			code:   exitError,
+			stderr: "mustache render: " + path("data.txt") + ": unknown data file extension\n",
+		},
+		{
+			name:   "Merge",
+			args:   []string{"-data", path("data.json"), "-data", path("data.yaml"), "-data", path("data.toml"), "-set", "user.name=Bo"},
+			stdin:  "{{title}} {{#user}}{{name}} {{role}}{{/user}} {{#links}}{{url}}{{/links}}",
+			stdout: "YAML Bo admin xy",
+		},
+		{
+			name:   "Order",
+			args:   []string{"-set", "title=set", "-data", path("data.yaml")},
+			stdin:  "{{title}}",
+			stdout: "YAML",
+		},
+		{
+			name:   "Env",
+			args:   []string{"-data", path("data.yaml"), "-env", "MUSTACHE_TEST_"},
+			stdin:  "{{title}} {{user.name}}",
+			stdout: "env Ann",
+		},
+		{
+			name:   "BadSet",
+			args:   []string{"-set", "title"},
+			code:   exitError,
+			stderr: "mustache render: invalid assignment \"title\": missing =\n",
+		},
+		{
+			name:   "BadDelims",
+			args:   []string{"-delims", "<%"},
+			code:   exitError,
+			stderr: "mustache render: invalid delimiters \"<%\"\n",
+		},
+		{
+			name:   "DelimsEquals",
+			args:   []string{"-delims", "<= =>"},
+			code:   exitError,
+			stderr: "mustache render: invalid delimiters \"<= =>\"\n",
+		},
+	}
+
+	os.Setenv("MUSTACHE_TEST_TITLE", "env")
+	defer os.Unsetenv("MUSTACHE_TEST_TITLE")
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr strings.Builder
+			code := run(append([]string{"render"}, tc.args...), strings.NewReader(tc.stdin), &stdout, &stderr)
+			if code != tc.code {
+				t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+			}
+			if got := stdout.String(); got != tc.stdout {
+				t.Errorf("unexpected output, got:%q, want:%q", got, tc.stdout)
+			}
+			if got := stderr.String(); got != tc.stderr {
+				t.Errorf("unexpected error, got:%q, want:%q", got, tc.stderr)
+			}
+		})
+	}
+
+	out := path("out.html")
+	var stdout, stderr strings.Builder
+	code := run([]string{"render", "-data", path("data.json"), "-o", out}, strings.NewReader("{{title}}"), &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+	}
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "A &amp; B"; got != want {
+		t.Errorf("unexpected output file, got:%q, want:%q", got, want)
+	}
+}
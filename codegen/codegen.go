@@ -0,0 +1,689 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package codegen generates Go code that renders mustache templates without
+// reflection.
+//
+// Each generated function renders one template, with the partials it
+// includes inlined, against data of a Go type known when the code is
+// generated:
+//
+//	func RenderWelcome(w io.Writer, data *WelcomeData) error
+//
+// Keys are resolved against the type with the same rules as Template.Render,
+// and the output is identical to the interpreter's, with these restrictions:
+//
+//   - values of interface type cannot be looked up or rendered;
+//   - functions taking the section text (lambdas) cannot be used as sections;
+//   - helpers registered with the template cannot be used;
+//   - variables cannot have fallbacks;
+//   - the block helpers, else tags and helper calls of Handlebars mode
+//     cannot be used;
+//   - strings returned by functions cannot contain tags;
+//   - partials cannot include themselves, directly or indirectly.
+//
+// Functions returning tags fail when rendering with rt.ErrLambdaTags, and
+// templates breaking the other restrictions are reported when generating
+// code. When the template has ContextErrorsEnabled, keys and
+// partials that can never be resolved are also reported when generating.
+package codegen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/token"
+	"go/types"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/eriklott/mustache"
+	"github.com/eriklott/mustache/ast"
+)
+
+// rtPath is the import path of the runtime support package.
+const rtPath = "github.com/eriklott/mustache/rt"
+
+// Func describes a generated render function.
+type Func struct {
+	Name     string     // name of the function
+	Template string     // name of the template to render
+	Type     types.Type // type of the data parameter
+}
+
+// Generate writes a Go source file declaring the functions in package pkg.
+// The templates and the partials they include are read from tmpl. Generated
+// functions HTML escape values; a custom Escape function of tmpl cannot be
+// generated, and is reported as an error.
+func Generate(w io.Writer, tmpl *mustache.Template, pkg *types.Package, funcs ...Func) error {
+	if tmpl.Escape != nil {
+		return errors.New("custom escape functions are not supported")
+	}
+	g := &generator{
+		tmpl:    tmpl,
+		pkg:     pkg,
+		imports: map[string]string{"io": "io", rtPath: "rt"},
+	}
+	for _, f := range funcs {
+		if err := g.function(f); err != nil {
+			return err
+		}
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by mustache gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&src, "package %s\n\n", pkg.Name())
+	paths := make([]string, 0, len(g.imports))
+	for p := range g.imports {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	// standard library packages are imported first, as a separate group.
+	sort.SliceStable(paths, func(i, j int) bool {
+		return isStd(paths[i]) && !isStd(paths[j])
+	})
+	src.WriteString("import (\n")
+	for i, p := range paths {
+		if i > 0 && isStd(paths[i-1]) && !isStd(p) {
+			src.WriteString("\n")
+		}
+		if name := g.imports[p]; name != path.Base(p) {
+			fmt.Fprintf(&src, "%s %s\n", name, strconv.Quote(p))
+		} else {
+			fmt.Fprintf(&src, "%s\n", strconv.Quote(p))
+		}
+	}
+	src.WriteString(")\n")
+	src.Write(g.buf.Bytes())
+
+	b, err := format.Source(src.Bytes())
+	if err != nil {
+		return fmt.Errorf("codegen: invalid generated code: %v", err)
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// isStd reports whether the import path names a standard library package.
+func isStd(path string) bool {
+	return !strings.Contains(strings.SplitN(path, "/", 2)[0], ".")
+}
+
+// generator contains the state of code generation.
+type generator struct {
+	tmpl     *mustache.Template
+	pkg      *types.Package
+	imports  map[string]string // import names by path
+	buf      bytes.Buffer      // the generated functions
+	nvar     int               // the number of variables declared
+	partials []string          // the partials being inlined, outermost first
+
+	// position of the tag being generated, for errors
+	treeName string
+	line     int
+	column   int
+}
+
+// value is a value on the context stack of generated code.
+type value struct {
+	expr   string     // expression evaluating to the value
+	typ    types.Type // type of the value
+	ptr    string     // when set, a pointer to the value, for use in selectors
+	method bool       // the value is a method value, which is never nil
+}
+
+// selector returns an expression selecting name from v.
+func (v value) selector(name string) string {
+	if v.ptr != "" {
+		return v.ptr + "." + name
+	}
+	return v.expr + "." + name
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+	g.buf.WriteByte('\n')
+}
+
+// newVar returns a new, unique variable name.
+func (g *generator) newVar(prefix string) string {
+	g.nvar++
+	return prefix + strconv.Itoa(g.nvar)
+}
+
+// capture returns the code generated by fn, without adding it to the output.
+func (g *generator) capture(fn func() error) (string, error) {
+	buf := g.buf
+	g.buf = bytes.Buffer{}
+	err := fn()
+	code := g.buf.String()
+	g.buf = buf
+	return code, err
+}
+
+// uses reports whether code refers to the variable name.
+func uses(code, name string) bool {
+	return regexp.MustCompile(`\b` + name + `\b`).MatchString(code)
+}
+
+// errorf returns an error at the position of the tag being generated.
+func (g *generator) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d:%d: %s", g.treeName, g.line, g.column, fmt.Sprintf(format, args...))
+}
+
+// qualifier qualifies the names of types declared outside the generated
+// package, importing their packages.
+func (g *generator) qualifier(pkg *types.Package) string {
+	if pkg == g.pkg {
+		return ""
+	}
+	g.imports[pkg.Path()] = pkg.Name()
+	return pkg.Name()
+}
+
+// ifElse generates an if statement choosing between the code generated by
+// then and els. cond and notCond are the condition and its negation. Empty
+// branches are omitted.
+func (g *generator) ifElse(cond, notCond string, then, els func() error) error {
+	t, err := g.capture(then)
+	if err != nil {
+		return err
+	}
+	e, err := g.capture(els)
+	if err != nil {
+		return err
+	}
+	switch {
+	case t == e:
+		// the condition has no side effects, and can be omitted.
+		g.buf.WriteString(t)
+	case e == "":
+		g.printf("if %s {\n%s}", cond, t)
+	case t == "":
+		g.printf("if %s {\n%s}", notCond, e)
+	default:
+		g.printf("if %s {\n%s} else {\n%s}", cond, t, e)
+	}
+	return nil
+}
+
+func (g *generator) function(f Func) error {
+	tree := g.tmpl.Tree(f.Template)
+	if tree == nil {
+		return fmt.Errorf("template not found: %s", f.Template)
+	}
+	g.printf("\n// %s renders the mustache template %s.", f.Name, strconv.Quote(f.Template))
+	g.printf("func %s(w io.Writer, data %s) error {", f.Name, types.TypeString(f.Type, g.qualifier))
+	g.printf("out := rt.NewWriter(w)")
+	g.partials = []string{f.Template}
+	stack := []value{{expr: "data", typ: f.Type}}
+	if err := g.nodes(tree.Name, tree.Nodes, stack); err != nil {
+		return err
+	}
+	g.printf("return out.Err()")
+	g.printf("}")
+	return nil
+}
+
+func (g *generator) nodes(treeName string, nodes []ast.Node, stack []value) error {
+	// restore the position of the enclosing tag for its remaining errors.
+	name, ln, col := g.treeName, g.line, g.column
+	defer func() {
+		g.treeName, g.line, g.column = name, ln, col
+	}()
+	for _, node := range nodes {
+		if err := g.node(treeName, node, stack); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *generator) node(treeName string, node ast.Node, stack []value) error {
+	switch n := node.(type) {
+	case *ast.Text:
+		if n.Text == "" {
+			return nil
+		}
+		if n.EndOfLine {
+			g.printf("out.WriteLine(%s)", strconv.Quote(n.Text))
+		} else {
+			g.printf("out.WriteString(%s)", strconv.Quote(n.Text))
+		}
+
+	case *ast.Variable:
+		g.treeName, g.line, g.column = treeName, n.Line, n.Column
+		if len(n.Fallbacks) > 0 {
+			return g.errorf("variable %s has fallbacks, which are not supported by generated code", strings.Join(n.Key, "."))
+		}
+		if n.Args != nil {
+			return g.errorf("helper call %s is not supported by generated code", n.Key[0])
+		}
+		s := g.newVar("s")
+		code, err := g.capture(func() error {
+			return g.lookup(n.Key, stack, func(v value) error {
+				return g.str(s, v)
+			}, func() error {
+				return g.missing("cannot find value %s in context", strings.Join(n.Key, "."))
+			})
+		})
+		if err != nil {
+			return err
+		}
+		write := "out.WriteEscaped"
+		if n.Unescaped {
+			write = "out.WriteString"
+		}
+		// a value that is never found, or found by a single assignment, is
+		// written directly.
+		if code == "" {
+			g.printf("%s(\"\")", write)
+			return nil
+		}
+		if assign := s + " = "; strings.HasPrefix(code, assign) && strings.Count(code, "\n") == 1 {
+			g.printf("%s(%s)", write, strings.TrimSuffix(code[len(assign):], "\n"))
+			return nil
+		}
+		g.printf("var %s string", s)
+		g.buf.WriteString(code)
+		g.printf("%s(%s)", write, s)
+
+	case *ast.Section:
+		g.treeName, g.line, g.column = treeName, n.Line, n.Column
+		if n.Block != "" {
+			return g.errorf("block helper %s is not supported by generated code", n.Block)
+		}
+		if _, inverse := n.Branches(); inverse != nil {
+			return g.errorf("section %s has an else tag, which is not supported by generated code", strings.Join(n.Key, "."))
+		}
+		truthy := func(v value) error {
+			if n.Inverted {
+				return nil
+			}
+			switch u := v.typ.Underlying().(type) {
+			case *types.Slice:
+				return g.loop(treeName, n.Nodes, stack, v, u.Elem())
+			case *types.Array:
+				return g.loop(treeName, n.Nodes, stack, v, u.Elem())
+			case *types.Signature:
+				return g.errorf("section %s is a lambda, which is not supported by generated code", strings.Join(n.Key, "."))
+			}
+			return g.nodes(treeName, n.Nodes, append(stack[:len(stack):len(stack)], v))
+		}
+		falsy := func() error {
+			if n.Inverted {
+				return g.nodes(treeName, n.Nodes, stack)
+			}
+			return nil
+		}
+		return g.lookup(n.Key, stack, func(v value) error {
+			return g.truthy(v, truthy, falsy)
+		}, func() error {
+			if g.tmpl.ContextErrorsEnabled {
+				return g.missing("cannot find value %s in context", strings.Join(n.Key, "."))
+			}
+			return falsy()
+		})
+
+	case *ast.Partial:
+		g.treeName, g.line, g.column = treeName, n.Line, n.Column
+		key := n.Resolve(g.partials[len(g.partials)-1])
+		tree := g.tmpl.Tree(key)
+		if tree == nil {
+			if g.tmpl.ContextErrorsEnabled {
+				return g.errorf("partial not found: %s", n.Key)
+			}
+			return nil
+		}
+		for _, name := range g.partials {
+			if name == key {
+				return g.errorf("recursive partial %s is not supported by generated code", n.Key)
+			}
+		}
+		g.partials = append(g.partials, key)
+		prev := g.newVar("indent")
+		g.printf("%s := out.PushIndent(%s)", prev, strconv.Quote(n.Indent))
+		if err := g.nodes(tree.Name, tree.Nodes, stack); err != nil {
+			return err
+		}
+		g.printf("out.PopIndent(%s)", prev)
+		g.partials = g.partials[:len(g.partials)-1]
+	}
+	return nil
+}
+
+// loop generates a loop rendering nodes once for each element of the slice
+// or array v.
+func (g *generator) loop(treeName string, nodes []ast.Node, stack []value, v value, elem types.Type) error {
+	e := g.newVar("v")
+	body, err := g.capture(func() error {
+		return g.nodes(treeName, nodes, append(stack[:len(stack):len(stack)], value{expr: e, typ: elem}))
+	})
+	if err != nil {
+		return err
+	}
+	if uses(body, e) {
+		g.printf("for _, %s := range %s {\n%s}", e, v.expr, body)
+	} else {
+		g.printf("for range %s {\n%s}", v.expr, body)
+	}
+	return nil
+}
+
+// missing generates the handling of a key that is not found. When context
+// errors are enabled, rendering fails with an error.
+func (g *generator) missing(format string, args ...interface{}) error {
+	if !g.tmpl.ContextErrorsEnabled {
+		return nil
+	}
+	g.imports["errors"] = "errors"
+	msg := fmt.Sprintf("%s:%d:%d: %s", g.treeName, g.line, g.column, fmt.Sprintf(format, args...))
+	g.printf("return errors.New(%s)", strconv.Quote(msg))
+	return nil
+}
+
+// lookup generates the lookup of a dotted key in the context stack, mirroring
+// lookupKeysStack. found generates the code using each value the key may
+// resolve to, and missing the code run when it does not resolve. When context
+// errors are enabled, a key that can never resolve is reported now.
+func (g *generator) lookup(key []string, stack []value, found func(v value) error, missing func() error) error {
+	if g.tmpl.ContextErrorsEnabled {
+		var resolves bool
+		_, err := g.capture(func() error {
+			return g.resolve(key, stack, func(v value) error {
+				resolves = true
+				return nil
+			}, func() error { return nil })
+		})
+		if err != nil {
+			return err
+		}
+		if !resolves {
+			return g.errorf("cannot find value %s in context", strings.Join(key, "."))
+		}
+	}
+	return g.resolve(key, stack, found, missing)
+}
+
+// resolve generates the lookup of a dotted key in the context stack.
+func (g *generator) resolve(key []string, stack []value, found func(v value) error, missing func() error) error {
+	var rest func(key []string, v value) error
+	rest = func(key []string, v value) error {
+		if len(key) == 0 {
+			return found(v)
+		}
+		return g.lookupKey(key[0], v, func(v value) error {
+			return rest(key[1:], v)
+		}, missing)
+	}
+	var frame func(i int) error
+	frame = func(i int) error {
+		if i < 0 {
+			if len(key) == 1 && g.tmpl.Helper(key[0]) != nil {
+				return g.errorf("helper %s is not supported by generated code", key[0])
+			}
+			return missing()
+		}
+		return g.lookupKey(key[0], stack[i], func(v value) error {
+			return rest(key[1:], v)
+		}, func() error {
+			return frame(i - 1)
+		})
+	}
+	return frame(len(stack) - 1)
+}
+
+// lookupKey generates the lookup of a key in a context, mirroring
+// lookupKeyContext.
+func (g *generator) lookupKey(key string, v value, found func(v value) error, notFound func() error) error {
+	if key == "." {
+		return found(v)
+	}
+
+	// check context for method by name
+	if token.IsExported(key) {
+		if sel := types.NewMethodSet(v.typ).Lookup(nil, key); sel != nil {
+			return found(value{expr: v.selector(key), typ: sel.Type(), method: true})
+		}
+	}
+
+	// check for fields and keys on concrete types.
+	switch u := v.typ.Underlying().(type) {
+	case *types.Pointer:
+		return g.indirect(v, func(v value) error {
+			return g.lookupKey(key, v, found, notFound)
+		}, func() error {
+			return notFound()
+		})
+	case *types.Interface:
+		return g.errorf("cannot look up %s in interface type %s, which is not supported by generated code", key, types.TypeString(v.typ, g.qualifier))
+	case *types.Map:
+		if !types.Identical(u.Key(), types.Typ[types.String]) {
+			return g.errorf("cannot look up %s in map type %s", key, types.TypeString(v.typ, g.qualifier))
+		}
+		e, ok := g.newVar("v"), g.newVar("ok")
+		then, err := g.capture(func() error {
+			return found(value{expr: e, typ: u.Elem()})
+		})
+		if err != nil {
+			return err
+		}
+		els, err := g.capture(func() error {
+			return notFound()
+		})
+		if err != nil {
+			return err
+		}
+		if !uses(then, e) {
+			e = "_"
+		}
+		if els == "" {
+			g.printf("if %s, %s := %s[%s]; %s {\n%s}", e, ok, v.expr, strconv.Quote(key), ok, then)
+		} else {
+			g.printf("if %s, %s := %s[%s]; %s {\n%s} else {\n%s}", e, ok, v.expr, strconv.Quote(key), ok, then, els)
+		}
+		return nil
+	case *types.Struct:
+		obj, _, _ := types.LookupFieldOrMethod(v.typ, false, g.pkg, key)
+		if f, ok := obj.(*types.Var); ok && f.IsField() {
+			return found(value{expr: v.selector(key), typ: f.Type()})
+		}
+		return notFound()
+	default:
+		return notFound()
+	}
+}
+
+// indirect generates the dereference of a pointer, mirroring indirect. elem
+// generates the code using the value pointed to, and isNil the code run when
+// any pointer is nil.
+func (g *generator) indirect(v value, elem func(v value) error, isNil func() error) error {
+	p, ok := v.typ.Underlying().(*types.Pointer)
+	if !ok {
+		if _, ok := v.typ.Underlying().(*types.Interface); ok {
+			return g.errorf("cannot use interface type %s, which is not supported by generated code", types.TypeString(v.typ, g.qualifier))
+		}
+		return elem(v)
+	}
+	return g.ifElse(v.expr+" != nil", v.expr+" == nil", func() error {
+		e := value{expr: "(*" + v.expr + ")", typ: p.Elem()}
+		if _, named := v.typ.(*types.Named); !named {
+			e.ptr = v.expr
+		}
+		return g.indirect(e, elem, isNil)
+	}, isNil)
+}
+
+// call generates the call of an arity 0 function value, and passes its result
+// to fn. isNil generates the code run when the function is nil.
+func (g *generator) call(v value, sig *types.Signature, fn func(v value) error, isNil func() error) error {
+	callResult := func() error {
+		r := value{expr: g.newVar("v"), typ: sig.Results().At(0).Type()}
+		code, err := g.capture(func() error {
+			return fn(r)
+		})
+		if err != nil {
+			return err
+		}
+		if uses(code, r.expr) {
+			g.printf("%s := %s()", r.expr, v.expr)
+		} else {
+			g.printf("%s()", v.expr)
+		}
+		g.buf.WriteString(code)
+		return nil
+	}
+	if v.method {
+		return callResult()
+	}
+	return g.ifElse(v.expr+" != nil", v.expr+" == nil", callResult, isNil)
+}
+
+// isArity0 reports whether sig is the signature of a function rendered by
+// calling it.
+func isArity0(sig *types.Signature) bool {
+	return sig.Params().Len() == 0 && sig.Results().Len() == 1
+}
+
+// isLambda reports whether sig is the signature of a function passed the
+// text of a section.
+func isLambda(sig *types.Signature) bool {
+	return sig.Params().Len() == 1 && isString(sig.Params().At(0).Type()) &&
+		sig.Results().Len() == 1 && isString(sig.Results().At(0).Type())
+}
+
+// isString reports whether typ is of string kind.
+func isString(typ types.Type) bool {
+	b, ok := typ.Underlying().(*types.Basic)
+	return ok && b.Info()&types.IsString != 0
+}
+
+// convert returns expr converted to the basic type named kind, unless it
+// already is of that type.
+func convert(v value, kind types.BasicKind) string {
+	if types.Identical(v.typ, types.Typ[kind]) {
+		return v.expr
+	}
+	return types.Typ[kind].Name() + "(" + v.expr + ")"
+}
+
+// str generates the assignment of the string form of v to the variable s,
+// mirroring toString.
+func (g *generator) str(s string, v value) error {
+	switch u := v.typ.Underlying().(type) {
+	case *types.Basic:
+		info := u.Info()
+		switch {
+		case info&types.IsString != 0:
+			g.printf("%s = %s", s, convert(v, types.String))
+		case info&types.IsBoolean != 0:
+			g.printf("%s = rt.Bool(%s)", s, convert(v, types.Bool))
+		case info&types.IsUnsigned != 0:
+			g.printf("%s = rt.Uint(%s)", s, convert(v, types.Uint64))
+		case info&types.IsInteger != 0:
+			g.printf("%s = rt.Int(%s)", s, convert(v, types.Int64))
+		case info&types.IsFloat != 0:
+			g.printf("%s = rt.Float(%s)", s, convert(v, types.Float64))
+		case info&types.IsComplex != 0:
+			g.printf("%s = rt.Complex(%s)", s, convert(v, types.Complex128))
+		default:
+			g.printf("%s = rt.Any(%s)", s, v.expr)
+		}
+		return nil
+	case *types.Signature:
+		if !isArity0(u) {
+			return nil
+		}
+		return g.call(v, u, func(r value) error {
+			if !isString(r.typ) {
+				return g.str(s, r)
+			}
+			l := g.newVar("v")
+			g.printf("%s, err := rt.Lambda(%s)", l, convert(r, types.String))
+			g.printf("if err != nil {\nreturn err\n}")
+			g.printf("%s = %s", s, l)
+			return nil
+		}, func() error { return nil })
+	case *types.Pointer, *types.Interface:
+		return g.indirect(v, func(v value) error {
+			return g.str(s, v)
+		}, func() error { return nil })
+	case *types.Chan:
+		return nil
+	default:
+		g.printf("%s = rt.Any(%s)", s, v.expr)
+		return nil
+	}
+}
+
+// truthy generates a test of whether v is truthy, mirroring toTruthyValue.
+// then generates the code run with the truthy value, and els the code run
+// when it is falsy.
+func (g *generator) truthy(v value, then func(v value) error, els func() error) error {
+	thenV := func() error { return then(v) }
+	switch u := v.typ.Underlying().(type) {
+	case *types.Basic:
+		info := u.Info()
+		switch {
+		case info&types.IsBoolean != 0:
+			return g.ifElse(v.expr, "!"+v.expr, thenV, els)
+		case info&types.IsInteger != 0:
+			return g.ifElse(v.expr+" != 0", v.expr+" == 0", thenV, els)
+		case info&types.IsFloat != 0:
+			t := "rt.TruthyFloat(" + convert(v, types.Float64) + ")"
+			return g.ifElse(t, "!"+t, thenV, els)
+		case info&types.IsComplex != 0:
+			t := "rt.TruthyComplex(" + convert(v, types.Complex128) + ")"
+			return g.ifElse(t, "!"+t, thenV, els)
+		case info&types.IsString != 0:
+			return g.ifElse("len("+v.expr+") != 0", "len("+v.expr+") == 0", thenV, els)
+		}
+		return els()
+	case *types.Slice, *types.Array:
+		// the elements of an empty list are never rendered, so the test can
+		// be omitted when there is nothing to render for a falsy value.
+		code, err := g.capture(els)
+		if err != nil {
+			return err
+		}
+		if code == "" {
+			return then(v)
+		}
+		return g.ifElse("len("+v.expr+") != 0", "len("+v.expr+") == 0", thenV, els)
+	case *types.Map:
+		return g.ifElse(v.expr+" != nil", v.expr+" == nil", thenV, els)
+	case *types.Struct:
+		return then(v)
+	case *types.Signature:
+		if isLambda(u) {
+			if v.method {
+				return then(v)
+			}
+			return g.ifElse(v.expr+" != nil", v.expr+" == nil", thenV, els)
+		}
+		if !isArity0(u) {
+			return els()
+		}
+		return g.call(v, u, func(r value) error {
+			if !isString(r.typ) {
+				return g.truthy(r, then, els)
+			}
+			l := g.newVar("v")
+			g.printf("%s, err := rt.SectionLambda(%s)", l, convert(r, types.String))
+			g.printf("if err != nil {\nreturn err\n}")
+			return g.truthy(value{expr: l, typ: types.Typ[types.String]}, then, els)
+		}, els)
+	case *types.Pointer, *types.Interface:
+		return g.indirect(v, func(v value) error {
+			return g.truthy(v, then, els)
+		}, els)
+	default:
+		return els()
+	}
+}
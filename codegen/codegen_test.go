@@ -0,0 +1,170 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package codegen_test
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/eriklott/mustache"
+	"github.com/eriklott/mustache/codegen"
+	"github.com/eriklott/mustache/parse"
+)
+
+// TestGenerate_Golden checks that the generated code in internal/gentest is
+// up to date. Its output is tested against the interpreter in that package.
+func TestGenerate_Golden(t *testing.T) {
+	const dir = "internal/gentest"
+	tt := []struct {
+		file     string
+		typ      string
+		fn       string
+		template string
+		strict   bool
+	}{
+		{"page_mustache.go", "*Page", "RenderPage", "page", false},
+		{"strict_mustache.go", "*Page", "RenderStrict", "strict", true},
+		{"labels_mustache.go", "Labels", "RenderLabels", "labels", false},
+	}
+
+	var files []string
+	for _, tc := range tt {
+		files = append(files, tc.file)
+	}
+	pkg, err := codegen.LoadPackage(dir, files...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.mustache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := mustache.NewTemplate()
+	for _, path := range paths {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := filepath.Base(path)
+		tree, err := parse.Parse(name, string(src), parse.DefaultLeftDelim, parse.DefaultRightDelim, parse.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl.AddTree(strings.TrimSuffix(name, ".mustache"), tree)
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.file, func(t *testing.T) {
+			tv, err := types.Eval(token.NewFileSet(), pkg, token.NoPos, tc.typ)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tmpl.ContextErrorsEnabled = tc.strict
+			var buf bytes.Buffer
+			err = codegen.Generate(&buf, tmpl, pkg, codegen.Func{Name: tc.fn, Template: tc.template, Type: tv.Type})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := ioutil.ReadFile(filepath.Join(dir, tc.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != string(want) {
+				t.Errorf("%s is out of date; run go generate ./codegen/...", tc.file)
+			}
+		})
+	}
+}
+
+// checkSource type checks a package from source.
+func checkSource(t *testing.T, src string) *types.Package {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "data.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := new(types.Config).Check("data", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkg
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	pkg := checkSource(t, `package data
+
+type Data struct {
+	Any    interface{}
+	Lambda func(string) string
+	Keyed  map[int]string
+}
+`)
+	typ := types.NewPointer(pkg.Scope().Lookup("Data").Type())
+
+	tt := []struct {
+		name     string
+		text     string
+		partials map[string]string
+		strict   bool
+		want     string
+	}{
+		{"InterfaceLookup", "{{Any.Name}}", nil, false, "main:1:1: cannot look up Name in interface type interface{}, which is not supported by generated code"},
+		{"InterfaceValue", "x\n{{Any}}", nil, false, "main:2:1: cannot use interface type interface{}, which is not supported by generated code"},
+		{"MapKey", "{{Keyed.a}}", nil, false, "main:1:1: cannot look up a in map type map[int]string"},
+		{"LambdaSection", "{{#Lambda}}x{{/Lambda}}", nil, false, "main:1:1: section Lambda is a lambda, which is not supported by generated code"},
+		{"Fallbacks", "{{Keyed ?? \"x\"}}", nil, false, "main:1:1: variable Keyed has fallbacks, which are not supported by generated code"},
+		{"Helper", "{{#upper}}x{{/upper}}", nil, false, "main:1:1: helper upper is not supported by generated code"},
+		{"BlockHelper", "{{#if Any}}x{{/if}}", nil, false, "main:1:1: block helper if is not supported by generated code"},
+		{"Else", "x\n{{#Keyed}}x{{else}}y{{/Keyed}}", nil, false, "main:2:1: section Keyed has an else tag, which is not supported by generated code"},
+		{"HelperCall", "{{upper Any}}", nil, false, "main:1:1: helper call upper is not supported by generated code"},
+		{"RecursivePartial", "{{>a}}", map[string]string{"a": "{{>b}}", "b": "  {{>a}}"}, false, "b:1:3: recursive partial a is not supported by generated code"},
+		{"StrictKey", "{{^Lambda}}{{/Lambda}}{{Nope}}", nil, true, "main:1:23: cannot find value Nope in context"},
+		{"StrictPartial", "{{>nope}}", nil, true, "main:1:1: partial not found: nope"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl := mustache.NewTemplate()
+			tmpl.ContextErrorsEnabled = tc.strict
+			tmpl.RegisterHelper("upper", strings.ToUpper)
+			tmpl.ParseOptions.Handlebars = true
+			if err := tmpl.Parse("main", tc.text); err != nil {
+				t.Fatal(err)
+			}
+			for name, text := range tc.partials {
+				if err := tmpl.Parse(name, text); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := codegen.Generate(ioutil.Discard, tmpl, pkg, codegen.Func{Name: "Render", Template: "main", Type: typ})
+			var got string
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tc.want {
+				t.Errorf("unexpected error, got:%s, want:%s", got, tc.want)
+			}
+		})
+	}
+
+	tmpl := mustache.NewTemplate()
+	tmpl.Escape = strings.ToUpper
+	if err := tmpl.Parse("main", "{{Any}}"); err != nil {
+		t.Fatal(err)
+	}
+	err := codegen.Generate(ioutil.Discard, tmpl, pkg, codegen.Func{Name: "Render", Template: "main", Type: typ})
+	if got, want := fmt.Sprint(err), "custom escape functions are not supported"; got != want {
+		t.Errorf("unexpected error, got:%s, want:%s", got, want)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package gentest holds code generated from the templates in its directory,
+// which is tested against the output of the interpreter.
+package gentest
+
+//go:generate go run ../../../cmd/mustache gen -type *Page -func RenderPage -template page -o page_mustache.go .
+//go:generate go run ../../../cmd/mustache gen -type *Page -func RenderStrict -template strict -strict -o strict_mustache.go .
+//go:generate go run ../../../cmd/mustache gen -type Labels -func RenderLabels -template labels -o labels_mustache.go .
+
+// Page is the data of the page template.
+type Page struct {
+	Title   string
+	Count   int
+	Ratio   float64
+	Score   uint8
+	Visible bool
+	Author  *Person
+	Nobody  *Person
+	People  []Person
+	Admins  []*Person
+	Labels  Labels
+	Tags    []string
+	Raw     string
+	Format  func() string
+	Missing func() string
+	Items   func() []string
+}
+
+// Total returns the number of people.
+func (p *Page) Total() int {
+	return len(p.People)
+}
+
+// Person is a person on the page.
+type Person struct {
+	Name    string
+	Age     int
+	Friends []Person
+}
+
+// Greeting returns a greeting for the person.
+func (p Person) Greeting() string {
+	return "Hello, " + p.Name
+}
+
+// Pointer is only visible on pointers to people.
+func (p *Person) Pointer() string {
+	if p == nil {
+		return "nil pointer"
+	}
+	return "pointer to " + p.Name
+}
+
+// Labels is the data of the labels template.
+type Labels map[string]string
@@ -0,0 +1,116 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package gentest
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/eriklott/mustache"
+	"github.com/eriklott/mustache/parse"
+	"github.com/eriklott/mustache/rt"
+)
+
+// loadTemplates parses the templates of the package directory the same way
+// as the mustache gen command.
+func loadTemplates(t *testing.T, strict bool) *mustache.Template {
+	paths, err := filepath.Glob("*.mustache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := mustache.NewTemplate()
+	tmpl.ContextErrorsEnabled = strict
+	for _, path := range paths {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree, err := parse.Parse(path, string(src), parse.DefaultLeftDelim, parse.DefaultRightDelim, parse.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tmpl.AddTree(strings.TrimSuffix(path, ".mustache"), tree); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tmpl
+}
+
+func testPage() *Page {
+	alice := Person{Name: "Alice", Age: 30}
+	bob := Person{Name: "Bob & Co", Age: 40, Friends: []Person{alice, {Name: "Carol"}}}
+	return &Page{
+		Title:   "Welcome <home>",
+		Count:   -3,
+		Ratio:   0.25,
+		Score:   200,
+		Visible: true,
+		Author:  &bob,
+		People:  []Person{alice, bob},
+		Admins:  []*Person{&alice, nil, &bob},
+		Labels:  Labels{"color": "red", "size": "L"},
+		Tags:    []string{"a", "<b>"},
+		Raw:     "<i>raw</i>",
+		Format:  func() string { return "<b>{{! ignored }}formatted</b>" },
+		Items:   func() []string { return []string{"x", "y"} },
+	}
+}
+
+func TestGenerated(t *testing.T) {
+	tt := []struct {
+		name     string
+		template string
+		strict   bool
+		data     interface{}
+		render   func(w io.Writer) error
+	}{
+		{"Page", "page", false, testPage(), func(w io.Writer) error { return RenderPage(w, testPage()) }},
+		{"EmptyPage", "page", false, &Page{}, func(w io.Writer) error { return RenderPage(w, &Page{}) }},
+		{"Strict", "strict", true, testPage(), func(w io.Writer) error { return RenderStrict(w, testPage()) }},
+		{"StrictMissing", "strict", true, &Page{Author: &Person{Name: "Dan"}}, func(w io.Writer) error {
+			return RenderStrict(w, &Page{Author: &Person{Name: "Dan"}})
+		}},
+		{"StrictNil", "strict", true, (*Page)(nil), func(w io.Writer) error { return RenderStrict(w, nil) }},
+		{"Labels", "labels", false, Labels{"color": "<red>", "size": "L", "shape": ""}, func(w io.Writer) error {
+			return RenderLabels(w, Labels{"color": "<red>", "size": "L", "shape": ""})
+		}},
+		{"NilLabels", "labels", false, Labels(nil), func(w io.Writer) error { return RenderLabels(w, nil) }},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			want, err := loadTemplates(t, tc.strict).Render(tc.template, tc.data)
+			var wantErr string
+			if err != nil {
+				wantErr = err.Error()
+			}
+
+			var buf bytes.Buffer
+			err = tc.render(&buf)
+			var gotErr string
+			if err != nil {
+				gotErr = err.Error()
+			}
+			if gotErr != wantErr {
+				t.Errorf("unexpected error, got:%s, want:%s", gotErr, wantErr)
+			}
+			if got := buf.String(); got != want {
+				t.Errorf("unexpected output, got:%q, want:%q", got, want)
+			}
+		})
+	}
+}
+
+func TestGenerated_LambdaTags(t *testing.T) {
+	page := &Page{Format: func() string { return "{{Title}}" }}
+	err := RenderPage(ioutil.Discard, page)
+	if err != rt.ErrLambdaTags {
+		t.Errorf("unexpected error, got:%v, want:%v", err, rt.ErrLambdaTags)
+	}
+}
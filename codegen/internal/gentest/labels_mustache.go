@@ -0,0 +1,34 @@
+// Code generated by mustache gen. DO NOT EDIT.
+
+package gentest
+
+import (
+	"io"
+
+	"github.com/eriklott/mustache/rt"
+)
+
+// RenderLabels renders the mustache template "labels".
+func RenderLabels(w io.Writer, data Labels) error {
+	out := rt.NewWriter(w)
+	var s1 string
+	if v2, ok3 := data["color"]; ok3 {
+		s1 = v2
+	}
+	out.WriteEscaped(s1)
+	if v4, ok5 := data["size"]; ok5 {
+		if len(v4) != 0 {
+			out.WriteString(", size ")
+			out.WriteEscaped(v4)
+		}
+	}
+	if v7, ok8 := data["shape"]; ok8 {
+		if len(v7) == 0 {
+			out.WriteString(", no shape")
+		}
+	} else {
+		out.WriteString(", no shape")
+	}
+	out.WriteLine("\n")
+	return out.Err()
+}
@@ -0,0 +1,291 @@
+// Code generated by mustache gen. DO NOT EDIT.
+
+package gentest
+
+import (
+	"io"
+
+	"github.com/eriklott/mustache/rt"
+)
+
+// RenderPage renders the mustache template "page".
+func RenderPage(w io.Writer, data *Page) error {
+	out := rt.NewWriter(w)
+	out.WriteString("<h1>")
+	var s1 string
+	if data != nil {
+		s1 = data.Title
+	}
+	out.WriteEscaped(s1)
+	out.WriteLine("</h1>\n")
+	out.WriteString("Count: ")
+	var s2 string
+	if data != nil {
+		s2 = rt.Int(int64(data.Count))
+	}
+	out.WriteEscaped(s2)
+	out.WriteString(" Ratio: ")
+	var s3 string
+	if data != nil {
+		s3 = rt.Float(data.Ratio)
+	}
+	out.WriteEscaped(s3)
+	out.WriteString(" Score: ")
+	var s4 string
+	if data != nil {
+		s4 = rt.Uint(uint64(data.Score))
+	}
+	out.WriteEscaped(s4)
+	out.WriteString(" Total: ")
+	var s5 string
+	v6 := data.Total()
+	s5 = rt.Int(int64(v6))
+	out.WriteEscaped(s5)
+	out.WriteLine("\n")
+	if data != nil {
+		if data.Visible {
+			out.WriteString("visible")
+		}
+	}
+	if data != nil {
+		if !data.Visible {
+			out.WriteString("hidden")
+		}
+	} else {
+		out.WriteString("hidden")
+	}
+	out.WriteLine("\n")
+	if data != nil {
+		if data.Author != nil {
+			out.WriteString("  By ")
+			out.WriteEscaped(data.Author.Name)
+			out.WriteString(" (")
+			out.WriteEscaped(rt.Int(int64(data.Author.Age)))
+			out.WriteString("). ")
+			var s9 string
+			v10 := data.Author.Greeting()
+			v11, err := rt.Lambda(v10)
+			if err != nil {
+				return err
+			}
+			s9 = v11
+			out.WriteEscaped(s9)
+			out.WriteString(" from ")
+			var s12 string
+			if data != nil {
+				s12 = data.Title
+			}
+			out.WriteEscaped(s12)
+			out.WriteLine(".\n")
+		}
+	}
+	if data != nil {
+		if data.Nobody != nil {
+			out.WriteString("never")
+		}
+	}
+	if data != nil {
+		if data.Nobody == nil {
+			out.WriteString("no one")
+		}
+	} else {
+		out.WriteString("no one")
+	}
+	out.WriteLine("\n")
+	var s13 string
+	if data != nil {
+		if data.Author != nil {
+			s13 = data.Author.Name
+		}
+	}
+	out.WriteEscaped(s13)
+	out.WriteString(", ")
+	var s14 string
+	if data != nil {
+		v15 := data.Author.Pointer()
+		v16, err := rt.Lambda(v15)
+		if err != nil {
+			return err
+		}
+		s14 = v16
+	}
+	out.WriteEscaped(s14)
+	out.WriteString(", ")
+	var s17 string
+	if data != nil {
+		if data.Nobody != nil {
+			s17 = data.Nobody.Name
+		}
+	}
+	out.WriteEscaped(s17)
+	out.WriteLine(".\n")
+	if data != nil {
+		for _, v18 := range data.People {
+			indent19 := out.PushIndent("  ")
+			out.WriteString("- ")
+			out.WriteEscaped(v18.Name)
+			out.WriteLine("\n")
+			for _, v21 := range v18.Friends {
+				indent22 := out.PushIndent("  ")
+				out.WriteString("* ")
+				out.WriteEscaped(v21.Name)
+				out.WriteString(" of ")
+				var s24 string
+				if data != nil {
+					s24 = data.Title
+				}
+				out.WriteEscaped(s24)
+				out.WriteLine("\n")
+				out.PopIndent(indent22)
+			}
+			out.PopIndent(indent19)
+		}
+	}
+	if data != nil {
+		for _, v25 := range data.Admins {
+			var s26 string
+			if v25 != nil {
+				s26 = v25.Name
+			}
+			out.WriteEscaped(s26)
+			out.WriteString(";")
+		}
+	}
+	out.WriteLine("\n")
+	if data != nil {
+		if data.Labels != nil {
+			var s27 string
+			if v28, ok29 := data.Labels["color"]; ok29 {
+				s27 = v28
+			}
+			out.WriteEscaped(s27)
+			out.WriteString(" ")
+			var s30 string
+			if v31, ok32 := data.Labels["size"]; ok32 {
+				s30 = v31
+			}
+			out.WriteEscaped(s30)
+		}
+	}
+	out.WriteString(" ")
+	var s33 string
+	if data != nil {
+		if v34, ok35 := data.Labels["color"]; ok35 {
+			s33 = v34
+		}
+	}
+	out.WriteEscaped(s33)
+	out.WriteLine("\n")
+	var s36 string
+	if data != nil {
+		s36 = data.Raw
+	}
+	out.WriteString(s36)
+	out.WriteString(" ")
+	var s37 string
+	if data != nil {
+		s37 = data.Raw
+	}
+	out.WriteEscaped(s37)
+	out.WriteString(" ")
+	var s38 string
+	if data != nil {
+		s38 = data.Raw
+	}
+	out.WriteString(s38)
+	out.WriteLine("\n")
+	var s39 string
+	if data != nil {
+		if data.Format != nil {
+			v40 := data.Format()
+			v41, err := rt.Lambda(v40)
+			if err != nil {
+				return err
+			}
+			s39 = v41
+		}
+	}
+	out.WriteEscaped(s39)
+	out.WriteString(" ")
+	if data != nil {
+		if data.Format != nil {
+			v42 := data.Format()
+			v43, err := rt.SectionLambda(v42)
+			if err != nil {
+				return err
+			}
+			if len(v43) != 0 {
+				out.WriteString("<")
+				out.WriteEscaped(v43)
+				out.WriteString(">")
+			}
+		}
+	}
+	out.WriteString(" [")
+	var s45 string
+	if data != nil {
+		if data.Missing != nil {
+			v46 := data.Missing()
+			v47, err := rt.Lambda(v46)
+			if err != nil {
+				return err
+			}
+			s45 = v47
+		}
+	}
+	out.WriteEscaped(s45)
+	out.WriteString("]")
+	if data != nil {
+		if data.Missing != nil {
+			v48 := data.Missing()
+			v49, err := rt.SectionLambda(v48)
+			if err != nil {
+				return err
+			}
+			if len(v49) == 0 {
+				out.WriteString("no func")
+			}
+		} else {
+			out.WriteString("no func")
+		}
+	} else {
+		out.WriteString("no func")
+	}
+	out.WriteLine("\n")
+	if data != nil {
+		for _, v50 := range data.Tags {
+			out.WriteString("[")
+			out.WriteEscaped(v50)
+			out.WriteString("]")
+		}
+	}
+	if data != nil {
+		if len(data.Tags) == 0 {
+			out.WriteString("no tags")
+		}
+	} else {
+		out.WriteString("no tags")
+	}
+	out.WriteLine("\n")
+	if data != nil {
+		if data.Items != nil {
+			v52 := data.Items()
+			for _, v53 := range v52 {
+				out.WriteString("(")
+				out.WriteEscaped(v53)
+				out.WriteString(")")
+			}
+		}
+	}
+	out.WriteLine("\n")
+	out.WriteEscaped("")
+	out.WriteString("unknown")
+	out.WriteLine("\n")
+	var s56 string
+	if data != nil {
+		s56 = data.Title
+	}
+	out.WriteEscaped(s56)
+	out.WriteLine("\n")
+	return out.Err()
+}
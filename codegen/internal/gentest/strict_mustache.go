@@ -0,0 +1,36 @@
+// Code generated by mustache gen. DO NOT EDIT.
+
+package gentest
+
+import (
+	"errors"
+	"io"
+
+	"github.com/eriklott/mustache/rt"
+)
+
+// RenderStrict renders the mustache template "strict".
+func RenderStrict(w io.Writer, data *Page) error {
+	out := rt.NewWriter(w)
+	if data != nil {
+		if data.Author != nil {
+			out.WriteEscaped(data.Author.Name)
+		}
+	} else {
+		return errors.New("strict.mustache:1:1: cannot find value Author in context")
+	}
+	out.WriteLine("\n")
+	var s2 string
+	if data != nil {
+		if data.Nobody != nil {
+			s2 = data.Nobody.Name
+		} else {
+			return errors.New("strict.mustache:2:1: cannot find value Nobody.Name in context")
+		}
+	} else {
+		return errors.New("strict.mustache:2:1: cannot find value Nobody.Name in context")
+	}
+	out.WriteEscaped(s2)
+	out.WriteLine("\n")
+	return out.Err()
+}
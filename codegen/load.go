@@ -0,0 +1,50 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package codegen
+
+import (
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+)
+
+// LoadPackage parses and type checks the Go package in dir, for use with
+// Generate. Test files, files excluded by build constraints, and the files
+// named by exclude, such as previously generated code, are ignored. Imported
+// packages are type checked from source.
+func LoadPackage(dir string, exclude ...string) (*types.Package, error) {
+	bpkg, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	excluded := make(map[string]bool)
+	for _, name := range exclude {
+		excluded[filepath.Base(name)] = true
+	}
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range bpkg.GoFiles {
+		if excluded[name] {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	path := bpkg.ImportPath
+	if path == "" || path == "." {
+		path = bpkg.Name
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	return conf.Check(path, fset, files, nil)
+}
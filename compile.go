@@ -0,0 +1,385 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package mustache
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/eriklott/mustache/ast"
+	"github.com/eriklott/mustache/parse"
+)
+
+// program is a compiled template. Executing a program renders the same
+// output as walking the tree it was compiled from.
+type program func(r *renderer) error
+
+// Compile compiles every template added so far into a program of closures,
+// which Render executes instead of walking the template's tree. Compiled
+// programs resolve keys through a cache of field indexes and methods for each
+// type they encounter, and avoid re-splitting and re-boxing keys on every
+// render. Templates added after Compile is called are walked until Compile
+// is called again. Compile must not be called concurrently with Render.
+func (t *Template) Compile() error {
+	progMap := make(map[string]program, len(t.treeMap))
+	for name, tree := range t.treeMap {
+		progMap[name] = compileNodes(tree.Name, tree.Nodes)
+	}
+	t.progMap = progMap
+	return nil
+}
+
+// compileNodes compiles a list of nodes into a program that executes each
+// node in order.
+func compileNodes(treeName string, nodes []ast.Node) program {
+	progs := make([]program, 0, len(nodes))
+	for _, node := range nodes {
+		if prog := compileNode(treeName, node); prog != nil {
+			progs = append(progs, prog)
+		}
+	}
+	switch len(progs) {
+	case 0:
+		return func(r *renderer) error { return nil }
+	case 1:
+		return progs[0]
+	}
+	return func(r *renderer) error {
+		for _, prog := range progs {
+			if err := prog(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// compileNode compiles a single node. Nodes that render nothing compile to
+// a nil program.
+func compileNode(treeName string, node ast.Node) program {
+	switch t := node.(type) {
+	case *ast.Tree:
+		return compileNodes(treeName, t.Nodes)
+
+	case *ast.Text:
+		if t.Text == "" {
+			return nil
+		}
+		text, endOfLine := t.Text, t.EndOfLine
+		return func(r *renderer) error {
+			r.write(text, true)
+			if endOfLine {
+				r.indentNext = true
+			}
+			return nil
+		}
+
+	case *ast.Variable:
+		key := internKey(t.Key)
+		ln, col, unescaped := t.Line, t.Column, t.Unescaped
+		if t.Args != nil {
+			call := t
+			return func(r *renderer) error {
+				v, err := r.call(treeName, call)
+				if err != nil {
+					return err
+				}
+				return r.writeValue(v, unescaped)
+			}
+		}
+		if len(t.Fallbacks) > 0 {
+			return compileFallbacks(treeName, t, key)
+		}
+		return func(r *renderer) error {
+			v, err := r.lookupInterned(treeName, ln, col, key)
+			if err != nil {
+				return err
+			}
+			return r.writeValue(v, unescaped)
+		}
+
+	case *ast.Section:
+		key := internKey(t.Key)
+		nodes, inverseNodes := t.Branches()
+		body := compileNodes(treeName, nodes)
+		inverse := compileNodes(treeName, inverseNodes)
+		sec := t
+		return func(r *renderer) error {
+			v, err := r.lookupInterned(treeName, sec.Line, sec.Column, key)
+			if err != nil {
+				return err
+			}
+			v, err = r.toTruthyValue(v)
+			if err != nil {
+				return err
+			}
+			if sec.Block != "" {
+				return r.block(sec, v, body, inverse)
+			}
+			isTruthy := v.IsValid()
+			if !sec.Inverted && isTruthy {
+				switch v.Kind() {
+				case reflect.Slice, reflect.Array:
+					for i := 0; i < v.Len(); i++ {
+						r.push(v.Index(i))
+						err := body(r)
+						if err != nil {
+							return err
+						}
+						r.pop()
+					}
+				case reflect.Func:
+					if l, ok := asLambda(v); ok {
+						s, err := r.callLambda(treeName, sec, l)
+						if err != nil {
+							return err
+						}
+						r.write(s, true)
+						break
+					}
+					if h, ok := asHelper(v); ok {
+						err := r.applyHelper(h, func() error {
+							return body(r)
+						})
+						if err != nil {
+							return err
+						}
+						break
+					}
+					s := v.Call([]reflect.Value{reflect.ValueOf(sec.Text)})[0].String()
+					tree, err := parse.Parse("lambda", s, sec.LDelim, sec.RDelim, r.template.ParseOptions)
+					if err != nil {
+						return nil
+					}
+					return r.walk(treeName, tree)
+				default:
+					r.push(v)
+					err := body(r)
+					if err != nil {
+						return err
+					}
+					r.pop()
+				}
+			} else if sec.Inverted && !isTruthy {
+				return body(r)
+			} else {
+				return inverse(r)
+			}
+			return nil
+		}
+
+	case *ast.Partial:
+		p := t
+		return func(r *renderer) error {
+			name := p.Resolve(r.name)
+			prog, isCompiled := r.template.progMap[name]
+			tree, ok := r.template.treeMap[name]
+			if !ok {
+				if r.template.ContextErrorsEnabled {
+					return fmt.Errorf("%s:%d:%d: partial not found: %s", treeName, p.Line, p.Column, p.Key)
+				}
+				return nil
+			}
+
+			origIndent := len(r.indent)
+			r.indent = append(r.indent, p.Indent...)
+
+			r.indentNext = true
+
+			r.depth++
+			if r.depth >= maxPartialDepth {
+				return fmt.Errorf("exceeded maximum partial depth: %d", maxPartialDepth)
+			}
+
+			from := r.name
+			r.name = name
+			var err error
+			if isCompiled {
+				err = prog(r)
+			} else {
+				err = r.walk(tree.Name, tree)
+			}
+			if err != nil {
+				return err
+			}
+			r.name = from
+
+			r.depth--
+
+			r.indent = r.indent[:origIndent]
+			return nil
+		}
+	}
+	return nil
+}
+
+// internedKey is a dotted key prepared for repeated lookups.
+type internedKey struct {
+	parts  []string
+	values []reflect.Value // each part as a reflect.Value, for map lookups
+	dotted string
+}
+
+// internKey prepares a split key for repeated lookups.
+func internKey(key []string) *internedKey {
+	k := &internedKey{
+		parts:  key,
+		values: make([]reflect.Value, len(key)),
+		dotted: strings.Join(key, "."),
+	}
+	for i := range key {
+		k.values[i] = reflect.ValueOf(key[i])
+	}
+	return k
+}
+
+// lookupInterned looks up an interned key in the context stack. If a value was
+// not found, the reflect.Value zero type is returned.
+func (r *renderer) lookupInterned(name string, ln, col int, key *internedKey) (reflect.Value, error) {
+	v := r.findInterned(key)
+	if !v.IsValid() && r.template.ContextErrorsEnabled {
+		return v, fmt.Errorf("%s:%d:%d: cannot find value %s in context", name, ln, col, key.dotted)
+	}
+	return v, nil
+}
+
+// findInterned looks up an interned key in the context stack, then in the
+// helpers of the template. If a value was not found, the reflect.Value zero
+// type is returned.
+func (r *renderer) findInterned(key *internedKey) reflect.Value {
+	var v reflect.Value
+	for i := range key.parts {
+		if i == 0 {
+			for j := len(r.stack) - 1; j >= 0; j-- {
+				v = lookupCachedContext(key.parts[0], key.values[0], r.stack[j])
+				if v.IsValid() {
+					break
+				}
+			}
+			continue
+		}
+		v = lookupCachedContext(key.parts[i], key.values[i], v)
+		if !v.IsValid() {
+			break
+		}
+	}
+	if !v.IsValid() {
+		v = r.lookupHelper(key.parts)
+	}
+	return v
+}
+
+// compileFallbacks compiles a variable with fallbacks, mirroring
+// lookupVariable. The interned keys of string fallbacks are nil.
+func compileFallbacks(treeName string, t *ast.Variable, key *internedKey) program {
+	keys := make([]*internedKey, len(t.Fallbacks))
+	strs := make([]reflect.Value, len(t.Fallbacks))
+	for i, fb := range t.Fallbacks {
+		if fb.Key == nil {
+			strs[i] = reflect.ValueOf(fb.String)
+		} else {
+			keys[i] = internKey(fb.Key)
+		}
+	}
+	ln, col, unescaped, dotted := t.Line, t.Column, t.Unescaped, fallbackKeys(t)
+	return func(r *renderer) error {
+		v := r.findInterned(key)
+		found := v.IsValid()
+		for i := range keys {
+			truthy, err := r.toTruthyValue(v)
+			if err != nil {
+				return err
+			}
+			if truthy.IsValid() {
+				v = truthy
+				break
+			}
+			if keys[i] == nil {
+				v, found = strs[i], true
+				break
+			}
+			v = r.findInterned(keys[i])
+			found = found || v.IsValid()
+		}
+		if !found && r.template.ContextErrorsEnabled {
+			return fmt.Errorf("%s:%d:%d: cannot find value %s in context", treeName, ln, col, dotted)
+		}
+		return r.writeValue(v, unescaped)
+	}
+}
+
+// accessorKey identifies a key looked up on a type.
+type accessorKey struct {
+	typ reflect.Type
+	key string
+}
+
+// accessor records how a key resolves on a type: as the method with the
+// given index, as the field with the given index, or, when both are absent,
+// not at all.
+type accessor struct {
+	method int   // method index, or -1
+	field  []int // field index, or nil
+}
+
+// accessorCache caches accessors by accessorKey.
+var accessorCache sync.Map
+
+// accessorFor returns the cached accessor of a key on a type.
+func accessorFor(typ reflect.Type, key string) accessor {
+	ak := accessorKey{typ, key}
+	if a, ok := accessorCache.Load(ak); ok {
+		return a.(accessor)
+	}
+	a := accessor{method: -1}
+	if m, ok := typ.MethodByName(key); ok {
+		a.method = m.Index
+	} else if typ.Kind() == reflect.Struct {
+		if f, ok := typ.FieldByName(key); ok {
+			a.field = f.Index
+		}
+	}
+	accessorCache.Store(ak, a)
+	return a
+}
+
+// lookupCachedContext returns a value by key from the context, resolving
+// methods and fields through the accessor cache. It is equivalent to
+// lookupKeyContext. keyValue is key as a reflect.Value.
+func lookupCachedContext(key string, keyValue reflect.Value, ctx reflect.Value) reflect.Value {
+	if key == "." {
+		return ctx
+	}
+	if !ctx.IsValid() {
+		return reflect.Value{}
+	}
+
+	// types without methods skip the cache, except structs, whose fields
+	// are cached.
+	typ := ctx.Type()
+	var a accessor
+	if typ.NumMethod() > 0 || typ.Kind() == reflect.Struct {
+		a = accessorFor(typ, key)
+		if a.method >= 0 {
+			return ctx.Method(a.method)
+		}
+	}
+
+	switch ctx.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return lookupCachedContext(key, keyValue, indirect(ctx))
+	case reflect.Map:
+		return ctx.MapIndex(keyValue)
+	case reflect.Struct:
+		if a.field == nil {
+			return reflect.Value{}
+		}
+		return ctx.FieldByIndex(a.field)
+	default:
+		return reflect.Value{}
+	}
+}
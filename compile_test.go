@@ -0,0 +1,233 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package mustache_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/eriklott/mustache"
+)
+
+type compilePerson struct {
+	Name    string
+	Age     int
+	Friends []*compilePerson
+	Tags    map[string]string
+}
+
+func (p compilePerson) Greeting() string { return "Hi {{Name}}" }
+
+func (p *compilePerson) Shout(text string) string { return strings.ToUpper(text) }
+
+func TestTemplate_Compile(t *testing.T) {
+	bob := &compilePerson{Name: "Bob", Age: 40, Tags: map[string]string{"role": "<admin>"}}
+	alice := &compilePerson{Name: "Alice", Age: 30, Friends: []*compilePerson{bob}}
+
+	tt := []struct {
+		name       string
+		text       string
+		partials   map[string]string
+		data       interface{}
+		errEnabled bool
+	}{
+		{"Fields", "{{Name}} {{Age}} {{Friends.0}} {{#Friends}}{{Name}}:{{Tags.role}}{{{Tags.role}}}{{/Friends}}", nil, alice, false},
+		{"Methods", "{{Greeting}} {{#Friends}}{{Greeting}}{{/Friends}}", nil, alice, false},
+		{"PointerMethods", "{{#Shout}}{{Name}}{{/Shout}}|{{#Friends}}{{#Shout}}x{{/Shout}}{{/Friends}}", nil, alice, false},
+		{"Maps", "{{a.b.c}}{{#a}}{{b.c}}{{d}}{{/a}}{{^e}}no e{{/e}}", nil, map[string]interface{}{"a": map[string]interface{}{"b": map[string]int{"c": 1}}, "d": "D"}, false},
+		{"Partials", "{{#Friends}}\n  {{>p}}\n{{/Friends}}{{>missing}}", map[string]string{"p": "{{Name}}\n{{Age}}\n"}, alice, false},
+		{"ContextMiss", "{{Name}} {{Nope}}", nil, alice, true},
+		{"PartialMiss", "{{>missing}}", nil, alice, true},
+		{"Lambda", "{{#lambda}}x{{/lambda}}{{value}}", nil, map[string]interface{}{"lambda": func(s string) string { return s + "{{value}}" }, "value": func() string { return "{{=| |=}}|v|" }, "v": 1}, false},
+		{"Stack", "{{#a}}{{#b}}{{c}}{{/b}}{{/a}}", nil, map[string]interface{}{"a": map[string]int{"x": 1}, "b": []int{1, 2}, "c": "C"}, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			render := func(compile bool) (string, string) {
+				tmpl := mustache.NewTemplate()
+				tmpl.ContextErrorsEnabled = tc.errEnabled
+				err := tmpl.Parse("main", tc.text)
+				if err != nil {
+					t.Fatalf("failed to parse template: %v", err)
+				}
+				for key, partial := range tc.partials {
+					err := tmpl.Parse(key, partial)
+					if err != nil {
+						t.Fatalf("failed to parse partial: %v", err)
+					}
+				}
+				if compile {
+					if err := tmpl.Compile(); err != nil {
+						t.Fatalf("failed to compile: %v", err)
+					}
+				}
+				s, err := tmpl.Render("main", tc.data)
+				var errStr string
+				if err != nil {
+					errStr = err.Error()
+				}
+				return s, errStr
+			}
+
+			want, wantErr := render(false)
+			got, gotErr := render(true)
+			if gotErr != wantErr {
+				t.Errorf("unexpected error, got:%s, want:%s", gotErr, wantErr)
+			}
+			if got != want {
+				t.Errorf("unexpected response, got:%q, want:%q", got, want)
+			}
+		})
+	}
+}
+
+func TestTemplate_CompileStale(t *testing.T) {
+	tmpl := mustache.NewTemplate()
+	if err := tmpl.Parse("main", "a{{>p}}"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpl.Parse("p", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpl.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpl.Parse("p", "c"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := tmpl.Render("main", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "ac"; got != want {
+		t.Errorf("unexpected response, got:%s, want:%s", got, want)
+	}
+}
+
+// benchFriend and benchPerson are the data of benchStructTemplate.
+type benchFriend struct {
+	Name     string
+	Age      int
+	Distance int
+}
+
+type benchPerson struct {
+	Name    string
+	Age     int
+	Married bool
+	Friends []benchFriend
+}
+
+func (p *benchPerson) FriendCount() int { return len(p.Friends) }
+
+const benchStructTemplate = `{{Name}} ({{Age}}) has {{FriendCount}} friends.
+{{#Married}}
+Married.
+{{/Married}}
+{{^Married}}
+Not married.
+{{/Married}}
+{{#Friends}}
+  Name: {{Name}}
+  Age: {{Age}}
+  Distance: {{Distance}} kilometers
+{{/Friends}}
+`
+
+// benchmarkSetup returns the benchmark template and data. When structData is
+// true, the data is a struct, otherwise it is testdata/data.json decoded
+// into maps.
+func benchmarkSetup(b *testing.B, structData bool) (string, interface{}) {
+	if structData {
+		data := &benchPerson{Name: "John Doe", Age: 20}
+		for i := 0; i < 10; i++ {
+			data.Friends = append(data.Friends, benchFriend{Name: "Peter Parker", Age: 17, Distance: i})
+		}
+		return benchStructTemplate, data
+	}
+
+	tmplBytes, err := ioutil.ReadFile("testdata/template.mustache")
+	if err != nil {
+		b.Fatal(err)
+	}
+	dataBytes, err := ioutil.ReadFile("testdata/data.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	var data interface{}
+	if err := json.Unmarshal(dataBytes, &data); err != nil {
+		b.Fatal(err)
+	}
+	return string(tmplBytes), data
+}
+
+func benchmarkRender(b *testing.B, structData, compile, appendRender bool) {
+	text, data := benchmarkSetup(b, structData)
+	tmpl := mustache.NewTemplate()
+	tmpl.ContextErrorsEnabled = structData
+	if err := tmpl.Parse("main", text); err != nil {
+		b.Fatalf("failed to parse template: %v", err)
+	}
+	if compile {
+		if err := tmpl.Compile(); err != nil {
+			b.Fatalf("failed to compile template: %v", err)
+		}
+	}
+
+	var buf []byte
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		var err error
+		if appendRender {
+			buf, err = tmpl.AppendRender(buf[:0], "main", data)
+		} else {
+			_, err = tmpl.Render("main", data)
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRender_Map(b *testing.B)            { benchmarkRender(b, false, false, false) }
+func BenchmarkRender_MapCompiled(b *testing.B)    { benchmarkRender(b, false, true, false) }
+func BenchmarkRender_Struct(b *testing.B)         { benchmarkRender(b, true, false, false) }
+func BenchmarkRender_StructCompiled(b *testing.B) { benchmarkRender(b, true, true, false) }
+
+func BenchmarkAppendRender_Map(b *testing.B)            { benchmarkRender(b, false, false, true) }
+func BenchmarkAppendRender_MapCompiled(b *testing.B)    { benchmarkRender(b, false, true, true) }
+func BenchmarkAppendRender_Struct(b *testing.B)         { benchmarkRender(b, true, false, true) }
+func BenchmarkAppendRender_StructCompiled(b *testing.B) { benchmarkRender(b, true, true, true) }
+
+func BenchmarkRender_Lambda(b *testing.B) {
+	tmpl := mustache.NewTemplate()
+	if err := tmpl.Parse("main", "{{#items}}{{name}}: {{#bold}}{{name}}{{/bold}} {{upper}}\n{{/items}}"); err != nil {
+		b.Fatal(err)
+	}
+	items := make([]map[string]interface{}, 10)
+	for i := range items {
+		items[i] = map[string]interface{}{
+			"name":  "item",
+			"bold":  func(text string) string { return "<b>" + text + "</b>" },
+			"upper": func() string { return "{{name}}!" },
+		}
+	}
+	data := map[string]interface{}{"items": items}
+
+	var buf []byte
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		var err error
+		buf, err = tmpl.AppendRender(buf[:0], "main", data)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package data loads template data from JSON, YAML and TOML files, key=value
+// assignments and environment variables, and merges data from several
+// sources.
+//
+// Data is represented by the types produced by encoding/json, except that
+// YAML and TOML integers are decoded as int64: objects are
+// map[string]interface{}, arrays are []interface{}, and scalars are string,
+// bool, int64, float64 or nil.
+//
+// The YAML decoder supports the subset of YAML used by configuration and data
+// files: block and flow mappings and sequences, plain, quoted, literal and
+// folded scalars, and comments. Anchors, aliases, tags and multiple documents
+// are reported as syntax errors. The TOML decoder supports TOML v1.0, except
+// that dates and times are decoded as strings.
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Format is the format of a data file.
+type Format int
+
+// Data formats
+const (
+	JSON Format = iota
+	YAML
+	TOML
+)
+
+func (f Format) String() string {
+	switch f {
+	case JSON:
+		return "JSON"
+	case YAML:
+		return "YAML"
+	case TOML:
+		return "TOML"
+	default:
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+}
+
+// FormatOf returns the format of a file by its extension: .json, .yaml or
+// .yml, or .toml.
+func FormatOf(path string) (Format, bool) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return JSON, true
+	case ".yaml", ".yml":
+		return YAML, true
+	case ".toml":
+		return TOML, true
+	}
+	return 0, false
+}
+
+// SyntaxError is an error in the syntax of a data file.
+type SyntaxError struct {
+	Name   string // name of the file
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.Name, e.Line, e.Column, e.Msg)
+}
+
+// Decode decodes the data of a file in the given format. The top level value
+// must be an object. Syntax errors are reported as a *SyntaxError positioned
+// in the named file.
+func Decode(name string, b []byte, format Format) (map[string]interface{}, error) {
+	var v interface{}
+	var err error
+	switch format {
+	case JSON:
+		v, err = decodeJSON(name, b)
+	case YAML:
+		v, err = decodeYAML(name, b)
+	case TOML:
+		v, err = decodeTOML(name, b)
+	default:
+		return nil, fmt.Errorf("%s: unknown format %v", name, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return map[string]interface{}{}, nil
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: top level value is not an object", name)
+	}
+	return m, nil
+}
+
+// ReadFile reads and decodes a data file, in the format given by its
+// extension.
+func ReadFile(path string) (map[string]interface{}, error) {
+	format, ok := FormatOf(path)
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown data file extension", path)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(path, b, format)
+}
+
+// decodeJSON decodes a JSON document, positioning syntax errors by line and
+// column.
+func decodeJSON(name string, b []byte) (interface{}, error) {
+	var v interface{}
+	err := json.Unmarshal(b, &v)
+	if e, ok := err.(*json.SyntaxError); ok {
+		// the offset follows the byte that caused the error.
+		offset := int(e.Offset)
+		if offset > 0 {
+			offset--
+		}
+		ln, col := lineColumn(b, offset)
+		return nil, &SyntaxError{Name: name, Line: ln, Column: col, Msg: e.Error()}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return v, nil
+}
+
+// lineColumn returns the line and byte column of an offset.
+func lineColumn(b []byte, offset int) (int, int) {
+	if offset > len(b) {
+		offset = len(b)
+	}
+	ln := bytes.Count(b[:offset], []byte("\n")) + 1
+	col := offset - bytes.LastIndexByte(b[:offset], '\n')
+	return ln, col
+}
+
+// Merge merges src into dst, and returns dst. Objects are merged deeply:
+// a key of src replaces the same key of dst, unless both values are objects,
+// in which case they are merged. Arrays are replaced rather than merged.
+// Objects of src are copied, and are not modified by later merges.
+func Merge(dst, src map[string]interface{}) map[string]interface{} {
+	if dst == nil {
+		dst = make(map[string]interface{}, len(src))
+	}
+	for k, v := range src {
+		sm, ok := v.(map[string]interface{})
+		if !ok {
+			dst[k] = v
+			continue
+		}
+		dm, ok := dst[k].(map[string]interface{})
+		if !ok {
+			dm = nil
+		}
+		dst[k] = Merge(dm, sm)
+	}
+	return dst
+}
+
+// Set sets a dotted key of m to a value, creating the objects of the key.
+// An object replaces a non-object value on the way to the key.
+func Set(m map[string]interface{}, key string, value interface{}) {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := m[part].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			m[part] = next
+		}
+		m = next
+	}
+	m[parts[len(parts)-1]] = value
+}
+
+// Assignment parses an assignment of the form key=value, where key is a
+// dotted key, and returns the data it assigns. The value is a string.
+func Assignment(s string) (map[string]interface{}, error) {
+	i := strings.IndexByte(s, '=')
+	if i < 0 {
+		return nil, fmt.Errorf("invalid assignment %q: missing =", s)
+	}
+	key := s[:i]
+	if key == "" || strings.HasPrefix(key, ".") || strings.HasSuffix(key, ".") || strings.Contains(key, "..") {
+		return nil, fmt.Errorf("invalid assignment %q: invalid key", s)
+	}
+	m := make(map[string]interface{})
+	Set(m, key, s[i+1:])
+	return m, nil
+}
+
+// Env returns the data of the environment variables starting with prefix,
+// given as key=value strings like those of os.Environ. The prefix is removed
+// from the name of each variable, and the remainder is converted to lower
+// case, with double underscores separating the keys of nested objects: with
+// the prefix APP_, APP_USER__NAME=Ann sets the key user.name. Values are
+// strings.
+func Env(environ []string, prefix string) map[string]interface{} {
+	m := make(map[string]interface{})
+	for _, kv := range environ {
+		i := strings.IndexByte(kv, '=')
+		if i < 0 || !strings.HasPrefix(kv[:i], prefix) {
+			continue
+		}
+		name := strings.ToLower(kv[len(prefix):i])
+		if name == "" {
+			continue
+		}
+		keys := strings.Split(name, "__")
+		valid := true
+		for _, k := range keys {
+			valid = valid && k != ""
+		}
+		if valid {
+			Set(m, strings.Join(keys, "."), kv[i+1:])
+		}
+	}
+	return m
+}
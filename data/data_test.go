@@ -0,0 +1,200 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package data_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/eriklott/mustache/data"
+)
+
+func TestDecode(t *testing.T) {
+	tt := []struct {
+		name   string
+		text   string
+		format data.Format
+		want   map[string]interface{}
+		err    string
+	}{
+		{
+			name:   "JSON",
+			text:   `{"a": {"b": [1, "x"]}}`,
+			format: data.JSON,
+			want:   map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{1.0, "x"}}},
+		},
+		{
+			name:   "Empty",
+			text:   "# nothing\n",
+			format: data.YAML,
+			want:   map[string]interface{}{},
+		},
+		{
+			name:   "JSONSyntax",
+			text:   "{\n  \"a\": ,\n}",
+			format: data.JSON,
+			err:    "data.json:2:8: invalid character ',' looking for beginning of value",
+		},
+		{
+			name:   "NotObject",
+			text:   "[1, 2]",
+			format: data.JSON,
+			err:    "data.json: top level value is not an object",
+		},
+		{
+			name:   "YAMLNotObject",
+			text:   "- a",
+			format: data.YAML,
+			err:    "data.json: top level value is not an object",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := data.Decode("data.json", []byte(tc.text), tc.format)
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("unexpected error, got:%v, want:%s", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("unexpected data, got:%#v, want:%#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mustache-data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"a.json": `{"name": "json"}`,
+		"a.yml":  "name: yaml",
+		"a.yaml": "name: yaml",
+		"a.toml": `name = "toml"`,
+		"a.txt":  "name",
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, want := range map[string]string{"a.json": "json", "a.yml": "yaml", "a.yaml": "yaml", "a.toml": "toml"} {
+		m, err := data.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := m["name"]; got != want {
+			t.Errorf("unexpected name in %s, got:%v, want:%s", name, got, want)
+		}
+	}
+	path := filepath.Join(dir, "a.txt")
+	if _, err := data.ReadFile(path); err == nil || err.Error() != path+": unknown data file extension" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	dst := map[string]interface{}{
+		"title": "a",
+		"user":  map[string]interface{}{"name": "Ann", "age": 3.0},
+		"tags":  []interface{}{"x", "y"},
+		"site":  "s",
+	}
+	src := map[string]interface{}{
+		"title": "b",
+		"user":  map[string]interface{}{"age": 4.0, "admin": true},
+		"tags":  []interface{}{"z"},
+		"site":  map[string]interface{}{"url": "u"},
+	}
+	want := map[string]interface{}{
+		"title": "b",
+		"user":  map[string]interface{}{"name": "Ann", "age": 4.0, "admin": true},
+		"tags":  []interface{}{"z"},
+		"site":  map[string]interface{}{"url": "u"},
+	}
+	got := data.Merge(dst, src)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected merge, got:%#v, want:%#v", got, want)
+	}
+
+	// merging into the result must not modify the objects of src.
+	data.Merge(got, map[string]interface{}{"site": map[string]interface{}{"name": "n"}})
+	if s := src["site"].(map[string]interface{}); len(s) != 1 {
+		t.Errorf("unexpected change to merged source: %#v", s)
+	}
+	if got := data.Merge(nil, src); !reflect.DeepEqual(got, src) {
+		t.Errorf("unexpected merge into nil, got:%#v, want:%#v", got, src)
+	}
+}
+
+func TestAssignment(t *testing.T) {
+	tt := []struct {
+		text string
+		want map[string]interface{}
+		err  string
+	}{
+		{text: "a=b", want: map[string]interface{}{"a": "b"}},
+		{text: "a.b.c=x=y", want: map[string]interface{}{"a": map[string]interface{}{"b": map[string]interface{}{"c": "x=y"}}}},
+		{text: "a=", want: map[string]interface{}{"a": ""}},
+		{text: "a", err: `invalid assignment "a": missing =`},
+		{text: "=b", err: `invalid assignment "=b": invalid key`},
+		{text: "a..b=c", err: `invalid assignment "a..b=c": invalid key`},
+	}
+	for _, tc := range tt {
+		got, err := data.Assignment(tc.text)
+		if tc.err != "" {
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("unexpected error for %q, got:%v, want:%s", tc.text, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("unexpected assignment for %q, got:%#v, want:%#v", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestEnv(t *testing.T) {
+	environ := []string{
+		"APP_TITLE=Home",
+		"APP_USER__NAME=Ann",
+		"APP_USER__ROLE=admin",
+		"APP_=empty",
+		"APP_BAD____KEY=x",
+		"HOME=/root",
+	}
+	want := map[string]interface{}{
+		"title": "Home",
+		"user":  map[string]interface{}{"name": "Ann", "role": "admin"},
+	}
+	if got := data.Env(environ, "APP_"); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected env data, got:%#v, want:%#v", got, want)
+	}
+}
+
+func TestSet(t *testing.T) {
+	m := map[string]interface{}{"a": "x"}
+	data.Set(m, "a.b", 1)
+	data.Set(m, "a.c", 2)
+	want := map[string]interface{}{"a": map[string]interface{}{"b": 1, "c": 2}}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("unexpected data, got:%#v, want:%#v", m, want)
+	}
+}
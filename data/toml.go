@@ -0,0 +1,604 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package data
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// tomlTable is a table of a TOML document while it is being decoded. Values
+// are tables, arrays of tables, or the values of the document.
+type tomlTable struct {
+	m        map[string]interface{}
+	explicit bool // defined by a [table] header
+	dotted   bool // defined by a dotted key
+	inline   bool // defined by an inline table, and so immutable
+}
+
+func newTable() *tomlTable {
+	return &tomlTable{m: make(map[string]interface{})}
+}
+
+// tomlArray is an array of tables, defined by [[table]] headers.
+type tomlArray struct {
+	tables []*tomlTable
+}
+
+// tomlParser contains the state of decoding a TOML document.
+type tomlParser struct {
+	name string
+	src  []byte
+	s    string
+	pos  int
+}
+
+func decodeTOML(name string, b []byte) (interface{}, error) {
+	p := &tomlParser{name: name, src: b, s: string(b)}
+	root := newTable()
+	if err := p.document(root); err != nil {
+		return nil, err
+	}
+	return finishTOML(root), nil
+}
+
+// finishTOML converts the tables of a decoded document to objects.
+func finishTOML(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *tomlTable:
+		m := make(map[string]interface{}, len(v.m))
+		for k, e := range v.m {
+			m[k] = finishTOML(e)
+		}
+		return m
+	case *tomlArray:
+		a := make([]interface{}, len(v.tables))
+		for i, t := range v.tables {
+			a[i] = finishTOML(t)
+		}
+		return a
+	case []interface{}:
+		for i, e := range v {
+			v[i] = finishTOML(e)
+		}
+		return v
+	}
+	return v
+}
+
+func (p *tomlParser) document(root *tomlTable) error {
+	cur := root
+	for {
+		p.skipBlank()
+		if p.pos == len(p.s) {
+			return nil
+		}
+		var err error
+		if p.s[p.pos] == '[' {
+			cur, err = p.header(root)
+		} else {
+			err = p.keyValue(cur)
+		}
+		if err != nil {
+			return err
+		}
+		if err := p.endOfLine(); err != nil {
+			return err
+		}
+	}
+}
+
+// header parses a [table] or [[array]] header and returns the table it
+// defines.
+func (p *tomlParser) header(root *tomlTable) (*tomlTable, error) {
+	start := p.pos
+	array := strings.HasPrefix(p.s[p.pos:], "[[")
+	if array {
+		p.pos += 2
+	} else {
+		p.pos++
+	}
+	keys, err := p.key()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+	if !strings.HasPrefix(p.s[p.pos:], closing) {
+		return nil, p.errorf("expected " + closing + " after table name")
+	}
+	p.pos += len(closing)
+
+	t := root
+	for i, k := range keys[:len(keys)-1] {
+		switch v := t.m[k].(type) {
+		case nil:
+			next := newTable()
+			t.m[k] = next
+			t = next
+		case *tomlTable:
+			if v.inline {
+				return nil, p.errorAt(start, "cannot extend inline table "+joinKey(keys[:i+1]))
+			}
+			t = v
+		case *tomlArray:
+			t = v.tables[len(v.tables)-1]
+		default:
+			return nil, p.errorAt(start, "key "+joinKey(keys[:i+1])+" is not a table")
+		}
+	}
+
+	k := keys[len(keys)-1]
+	if array {
+		next := newTable()
+		switch v := t.m[k].(type) {
+		case nil:
+			t.m[k] = &tomlArray{tables: []*tomlTable{next}}
+		case *tomlArray:
+			v.tables = append(v.tables, next)
+		default:
+			return nil, p.errorAt(start, "key "+joinKey(keys)+" is not an array of tables")
+		}
+		return next, nil
+	}
+	switch v := t.m[k].(type) {
+	case nil:
+		next := newTable()
+		next.explicit = true
+		t.m[k] = next
+		return next, nil
+	case *tomlTable:
+		if v.explicit || v.dotted || v.inline {
+			return nil, p.errorAt(start, "table "+joinKey(keys)+" is already defined")
+		}
+		v.explicit = true
+		return v, nil
+	}
+	return nil, p.errorAt(start, "key "+joinKey(keys)+" is already defined")
+}
+
+// keyValue parses a key/value pair into table t.
+func (p *tomlParser) keyValue(t *tomlTable) error {
+	start := p.pos
+	keys, err := p.key()
+	if err != nil {
+		return err
+	}
+	p.skipSpace()
+	if p.pos == len(p.s) || p.s[p.pos] != '=' {
+		return p.errorf("expected = after key")
+	}
+	p.pos++
+	p.skipSpace()
+	v, err := p.value()
+	if err != nil {
+		return err
+	}
+
+	for i, k := range keys[:len(keys)-1] {
+		switch next := t.m[k].(type) {
+		case nil:
+			n := newTable()
+			n.dotted = true
+			t.m[k] = n
+			t = n
+		case *tomlTable:
+			if next.explicit || next.inline {
+				return p.errorAt(start, "key "+joinKey(keys[:i+1])+" is already defined")
+			}
+			next.dotted = true
+			t = next
+		default:
+			return p.errorAt(start, "key "+joinKey(keys[:i+1])+" is already defined")
+		}
+	}
+	k := keys[len(keys)-1]
+	if _, dup := t.m[k]; dup {
+		return p.errorAt(start, "key "+joinKey(keys)+" is already defined")
+	}
+	t.m[k] = v
+	return nil
+}
+
+// key parses a dotted key.
+func (p *tomlParser) key() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpace()
+		if p.pos == len(p.s) {
+			return nil, p.errorf("expected key")
+		}
+		var k string
+		var err error
+		switch c := p.s[p.pos]; {
+		case c == '"':
+			k, err = p.basicString()
+		case c == '\'':
+			k, err = p.literalString()
+		default:
+			start := p.pos
+			for p.pos < len(p.s) && isBareKeyChar(p.s[p.pos]) {
+				p.pos++
+			}
+			if p.pos == start {
+				return nil, p.errorf("expected key")
+			}
+			k = p.s[start:p.pos]
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+		p.skipSpace()
+		if p.pos == len(p.s) || p.s[p.pos] != '.' {
+			return keys, nil
+		}
+		p.pos++
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// value parses a value.
+func (p *tomlParser) value() (interface{}, error) {
+	if p.pos == len(p.s) {
+		return nil, p.errorf("expected value")
+	}
+	rest := p.s[p.pos:]
+	switch {
+	case strings.HasPrefix(rest, `"""`):
+		return p.multiLineString('"')
+	case strings.HasPrefix(rest, `'''`):
+		return p.multiLineString('\'')
+	case rest[0] == '"':
+		return p.basicString()
+	case rest[0] == '\'':
+		return p.literalString()
+	case rest[0] == '[':
+		return p.array()
+	case rest[0] == '{':
+		return p.inlineTable()
+	case strings.HasPrefix(rest, "true") && !isBareKeyChar(byteAt(rest, 4)):
+		p.pos += 4
+		return true, nil
+	case strings.HasPrefix(rest, "false") && !isBareKeyChar(byteAt(rest, 5)):
+		p.pos += 5
+		return false, nil
+	}
+	return p.scalar()
+}
+
+func byteAt(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return 0
+}
+
+var (
+	tomlDate     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	tomlDateTime = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}[Tt ]\d{2}:\d{2}:\d{2}(\.\d+)?([Zz]|[-+]\d{2}:\d{2})?$`)
+	tomlTime     = regexp.MustCompile(`^\d{2}:\d{2}:\d{2}(\.\d+)?$`)
+	tomlInt      = regexp.MustCompile(`^[-+]?(0|[1-9](_?[0-9])*)$`)
+	tomlPrefixed = regexp.MustCompile(`^0(x[0-9A-Fa-f](_?[0-9A-Fa-f])*|o[0-7](_?[0-7])*|b[01](_?[01])*)$`)
+	tomlFloat    = regexp.MustCompile(`^[-+]?(0|[1-9](_?[0-9])*)(\.[0-9](_?[0-9])*)?([eE][-+]?[0-9](_?[0-9])*)?$`)
+)
+
+// scalar parses a number, date or time. Dates and times are returned as
+// strings.
+func (p *tomlParser) scalar() (interface{}, error) {
+	start := p.pos
+	for p.pos < len(p.s) && (isBareKeyChar(p.s[p.pos]) || strings.IndexByte(":.+", p.s[p.pos]) >= 0) {
+		p.pos++
+	}
+	tok := p.s[start:p.pos]
+	// a date and time may be separated by a space.
+	if tomlDate.MatchString(tok) && p.pos+3 < len(p.s) && p.s[p.pos] == ' ' && p.s[p.pos+3] == ':' {
+		end := p.pos + 1
+		for end < len(p.s) && (isBareKeyChar(p.s[end]) || strings.IndexByte(":.+", p.s[end]) >= 0) {
+			end++
+		}
+		if tomlDateTime.MatchString(p.s[start:end]) {
+			p.pos = end
+			tok = p.s[start:end]
+		}
+	}
+
+	switch {
+	case tok == "":
+		return nil, p.errorAt(start, "expected value")
+	case tomlDate.MatchString(tok) || tomlDateTime.MatchString(tok) || tomlTime.MatchString(tok):
+		return tok, nil
+	case tok == "inf" || tok == "+inf":
+		return math.Inf(1), nil
+	case tok == "-inf":
+		return math.Inf(-1), nil
+	case tok == "nan" || tok == "+nan" || tok == "-nan":
+		return math.NaN(), nil
+	case tomlInt.MatchString(tok):
+		n, err := strconv.ParseInt(strings.Replace(tok, "_", "", -1), 10, 64)
+		if err != nil {
+			return nil, p.errorAt(start, "integer out of range: "+tok)
+		}
+		return n, nil
+	case tomlPrefixed.MatchString(tok):
+		base := map[byte]int{'x': 16, 'o': 8, 'b': 2}[tok[1]]
+		n, err := strconv.ParseInt(strings.Replace(tok[2:], "_", "", -1), base, 64)
+		if err != nil {
+			return nil, p.errorAt(start, "integer out of range: "+tok)
+		}
+		return n, nil
+	case tomlFloat.MatchString(tok):
+		f, err := strconv.ParseFloat(strings.Replace(tok, "_", "", -1), 64)
+		if err != nil {
+			return nil, p.errorAt(start, "invalid float: "+tok)
+		}
+		return f, nil
+	}
+	return nil, p.errorAt(start, "invalid value: "+tok)
+}
+
+// array parses an array, which may span several lines.
+func (p *tomlParser) array() (interface{}, error) {
+	p.pos++
+	a := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.pos == len(p.s) {
+			return nil, p.errorf("unterminated array")
+		}
+		if p.s[p.pos] == ']' {
+			p.pos++
+			return a, nil
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+		p.skipBlank()
+		if p.pos < len(p.s) && p.s[p.pos] == ',' {
+			p.pos++
+			continue
+		}
+		if p.pos < len(p.s) && p.s[p.pos] == ']' {
+			continue
+		}
+		return nil, p.errorf("expected , or ] in array")
+	}
+}
+
+// inlineTable parses an inline table, which must be on a single line.
+func (p *tomlParser) inlineTable() (interface{}, error) {
+	p.pos++
+	t := newTable()
+	p.skipSpace()
+	if p.pos < len(p.s) && p.s[p.pos] == '}' {
+		p.pos++
+		t.inline = true
+		return t, nil
+	}
+	for {
+		if err := p.keyValue(t); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.pos == len(p.s) {
+			return nil, p.errorf("unterminated inline table")
+		}
+		switch p.s[p.pos] {
+		case ',':
+			p.pos++
+			continue
+		case '}':
+			p.pos++
+			freezeTable(t)
+			return t, nil
+		}
+		return nil, p.errorf("expected , or } in inline table")
+	}
+}
+
+// freezeTable marks a table and the tables defined within it as inline.
+func freezeTable(t *tomlTable) {
+	t.inline = true
+	for _, v := range t.m {
+		if t, ok := v.(*tomlTable); ok {
+			freezeTable(t)
+		}
+	}
+}
+
+// basicString parses a string in double quotes.
+func (p *tomlParser) basicString() (string, error) {
+	start := p.pos
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		switch c {
+		case '"':
+			p.pos++
+			return b.String(), nil
+		case '\n':
+			return "", p.errorAt(start, "unterminated string")
+		case '\\':
+			if err := p.escape(&b); err != nil {
+				return "", err
+			}
+			continue
+		}
+		b.WriteByte(c)
+		p.pos++
+	}
+	return "", p.errorAt(start, "unterminated string")
+}
+
+// literalString parses a string in single quotes.
+func (p *tomlParser) literalString() (string, error) {
+	start := p.pos
+	p.pos++
+	end := strings.IndexAny(p.s[p.pos:], "'\n")
+	if end < 0 || p.s[p.pos+end] != '\'' {
+		return "", p.errorAt(start, "unterminated string")
+	}
+	s := p.s[p.pos : p.pos+end]
+	p.pos += end + 1
+	return s, nil
+}
+
+// multiLineString parses a multi-line basic or literal string, delimited by
+// three quotes.
+func (p *tomlParser) multiLineString(quote byte) (string, error) {
+	start := p.pos
+	delim := strings.Repeat(string(quote), 3)
+	p.pos += 3
+	// a newline immediately following the opening delimiter is trimmed.
+	if strings.HasPrefix(p.s[p.pos:], "\r\n") {
+		p.pos += 2
+	} else if strings.HasPrefix(p.s[p.pos:], "\n") {
+		p.pos++
+	}
+	var b strings.Builder
+	for p.pos < len(p.s) {
+		if strings.HasPrefix(p.s[p.pos:], delim) {
+			// up to two quotes may precede the closing delimiter.
+			n := 3
+			for n < 5 && p.pos+n < len(p.s) && p.s[p.pos+n] == quote {
+				n++
+			}
+			b.WriteString(strings.Repeat(string(quote), n-3))
+			p.pos += n
+			return b.String(), nil
+		}
+		c := p.s[p.pos]
+		if c == '\\' && quote == '"' {
+			// a backslash at the end of a line trims the following whitespace.
+			rest := strings.TrimLeft(p.s[p.pos+1:], " \t")
+			if strings.HasPrefix(rest, "\n") || strings.HasPrefix(rest, "\r\n") {
+				p.pos = len(p.s) - len(strings.TrimLeft(rest, " \t\r\n"))
+				continue
+			}
+			if err := p.escape(&b); err != nil {
+				return "", err
+			}
+			continue
+		}
+		b.WriteByte(c)
+		p.pos++
+	}
+	return "", p.errorAt(start, "unterminated string")
+}
+
+// escape parses an escape sequence of a basic string.
+func (p *tomlParser) escape(b *strings.Builder) error {
+	start := p.pos
+	p.pos++
+	if p.pos == len(p.s) {
+		return p.errorAt(start, "invalid escape sequence")
+	}
+	c := p.s[p.pos]
+	p.pos++
+	switch c {
+	case 'b':
+		b.WriteByte('\b')
+	case 't':
+		b.WriteByte('\t')
+	case 'n':
+		b.WriteByte('\n')
+	case 'f':
+		b.WriteByte('\f')
+	case 'r':
+		b.WriteByte('\r')
+	case '"', '\\':
+		b.WriteByte(c)
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if p.pos+n > len(p.s) {
+			return p.errorAt(start, "invalid escape sequence")
+		}
+		r, err := strconv.ParseUint(p.s[p.pos:p.pos+n], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(r)) {
+			return p.errorAt(start, "invalid escape sequence")
+		}
+		b.WriteRune(rune(r))
+		p.pos += n
+	default:
+		return p.errorAt(start, "invalid escape sequence: \\"+string(c))
+	}
+	return nil
+}
+
+// skipSpace skips spaces and tabs.
+func (p *tomlParser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// skipBlank skips whitespace, newlines and comments.
+func (p *tomlParser) skipBlank() {
+	for p.pos < len(p.s) {
+		switch p.s[p.pos] {
+		case ' ', '\t', '\r', '\n':
+			p.pos++
+		case '#':
+			p.skipComment()
+		default:
+			return
+		}
+	}
+}
+
+func (p *tomlParser) skipComment() {
+	if i := strings.IndexByte(p.s[p.pos:], '\n'); i >= 0 {
+		p.pos += i
+	} else {
+		p.pos = len(p.s)
+	}
+}
+
+// endOfLine consumes the remainder of a line, which may hold a comment.
+func (p *tomlParser) endOfLine() error {
+	p.skipSpace()
+	if p.pos < len(p.s) && p.s[p.pos] == '#' {
+		p.skipComment()
+	}
+	switch {
+	case p.pos == len(p.s):
+	case p.s[p.pos] == '\n':
+		p.pos++
+	case strings.HasPrefix(p.s[p.pos:], "\r\n"):
+		p.pos += 2
+	default:
+		return p.errorf("expected end of line")
+	}
+	return nil
+}
+
+func (p *tomlParser) errorf(msg string) error {
+	return p.errorAt(p.pos, msg)
+}
+
+func (p *tomlParser) errorAt(offset int, msg string) error {
+	ln, col := lineColumn(p.src, offset)
+	return &SyntaxError{Name: p.name, Line: ln, Column: col, Msg: msg}
+}
+
+// joinKey returns the dotted form of a key.
+func joinKey(keys []string) string {
+	return strings.Join(keys, ".")
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package data_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eriklott/mustache/data"
+)
+
+func TestDecode_TOML(t *testing.T) {
+	tt := []struct {
+		name string
+		text string
+		want obj
+	}{
+		{
+			name: "Scalars",
+			text: "s = \"a\\tb\\u00e9\"\nl = 'C:\\dir'\nn = -1_000\nh = 0xff\no = 0o17\nb = 0b101\nf = 6.5e-1\nt = true\nd = 1979-05-27\ndt = 1979-05-27 07:32:00Z\ntm = 07:32:00\n",
+			want: obj{"s": "a\tbé", "l": `C:\dir`, "n": int64(-1000), "h": int64(255), "o": int64(15), "b": int64(5), "f": 0.65, "t": true, "d": "1979-05-27", "dt": "1979-05-27 07:32:00Z", "tm": "07:32:00"},
+		},
+		{
+			name: "MultiLine",
+			text: "a = \"\"\"\none\ntwo \\\n   three\"\"\"\nb = '''\nraw \\n'''\nc = \"\"\"x\"\"\"\"\"\n",
+			want: obj{"a": "one\ntwo three", "b": `raw \n`, "c": `x""`},
+		},
+		{
+			name: "Keys",
+			text: "\"quoted key\" = 1\n'lit' = 2\na.b.c = 3\na . d = 4\nbare-key_1 = 5 # comment\n",
+			want: obj{"quoted key": int64(1), "lit": int64(2), "a": obj{"b": obj{"c": int64(3)}, "d": int64(4)}, "bare-key_1": int64(5)},
+		},
+		{
+			name: "Tables",
+			text: "title = \"T\"\n\n[owner]\nname = \"Tom\"\n\n[server.alpha]\nip = \"10.0.0.1\"\n\n[server]\nport = 80\n",
+			want: obj{"title": "T", "owner": obj{"name": "Tom"}, "server": obj{"alpha": obj{"ip": "10.0.0.1"}, "port": int64(80)}},
+		},
+		{
+			name: "ArrayTables",
+			text: "[[products]]\nname = \"a\"\n[products.size]\nw = 1\n[[products]]\nname = \"b\"\n[[products.tags]]\nt = 1\n",
+			want: obj{"products": arr{
+				obj{"name": "a", "size": obj{"w": int64(1)}},
+				obj{"name": "b", "tags": arr{obj{"t": int64(1)}}},
+			}},
+		},
+		{
+			name: "Arrays",
+			text: "a = [1, \"x\", [true], ]\nb = [\n  1, # one\n  2\n]\nc = []\nd = [{x = 1}, {y.z = 2}]\ne = {}\n",
+			want: obj{
+				"a": arr{int64(1), "x", arr{true}},
+				"b": arr{int64(1), int64(2)},
+				"c": arr{},
+				"d": arr{obj{"x": int64(1)}, obj{"y": obj{"z": int64(2)}}},
+				"e": obj{},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := data.Decode("data.toml", []byte(tc.text), data.TOML)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("unexpected data, got:%#v, want:%#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDecode_TOMLError(t *testing.T) {
+	tt := []struct {
+		name string
+		text string
+		err  string
+	}{
+		{name: "DuplicateKey", text: "a = 1\na = 2", err: "data.toml:2:1: key a is already defined"},
+		{name: "DuplicateTable", text: "[a]\n[b]\n[a]", err: "data.toml:3:1: table a is already defined"},
+		{name: "DottedTable", text: "a.b = 1\n[a]", err: "data.toml:2:1: table a is already defined"},
+		{name: "NotTable", text: "a = 1\n[a.b]", err: "data.toml:2:1: key a is not a table"},
+		{name: "NotArray", text: "a = [1]\n[[a]]", err: "data.toml:2:1: key a is not an array of tables"},
+		{name: "Inline", text: "a = {b = 1}\n[a.c]", err: "data.toml:2:1: cannot extend inline table a"},
+		{name: "MissingValue", text: "a =\nb = 1", err: "data.toml:1:4: expected value"},
+		{name: "EndOfLine", text: "a = 1 b = 2", err: "data.toml:1:7: expected end of line"},
+		{name: "Unterminated", text: "a = \"b\nc = 1", err: "data.toml:1:5: unterminated string"},
+		{name: "Number", text: "a = 01", err: "data.toml:1:5: invalid value: 01"},
+		{name: "Escape", text: `a = "\q"`, err: `data.toml:1:6: invalid escape sequence: \q`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := data.Decode("data.toml", []byte(tc.text), data.TOML)
+			if _, ok := err.(*data.SyntaxError); !ok || err.Error() != tc.err {
+				t.Errorf("unexpected error, got:%v, want:%s", err, tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,698 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package data
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// yamlLine is a line of a YAML document.
+type yamlLine struct {
+	num    int    // line number, starting at 1
+	raw    string // the line, without its line ending
+	indent int    // number of spaces preceding the content
+	text   string // the content, following the indent
+	blank  bool   // the line is empty, or holds only a comment
+}
+
+// yamlParser contains the state of decoding a YAML document. Block
+// collections are parsed line by line, by indentation.
+type yamlParser struct {
+	name  string
+	lines []yamlLine
+	pos   int
+}
+
+func decodeYAML(name string, b []byte) (interface{}, error) {
+	p := &yamlParser{name: name}
+	src := strings.TrimPrefix(string(b), "\ufeff")
+	started := false
+	for i, raw := range strings.Split(src, "\n") {
+		raw = strings.TrimSuffix(raw, "\r")
+		text := strings.TrimLeft(raw, " ")
+		l := yamlLine{
+			num:    i + 1,
+			raw:    raw,
+			indent: len(raw) - len(text),
+			text:   text,
+			blank:  text == "" || text[0] == '#',
+		}
+		if l.blank {
+			p.lines = append(p.lines, l)
+			continue
+		}
+		if strings.HasPrefix(text, "\t") {
+			return nil, p.errorf(l, "tabs are not allowed in indentation")
+		}
+		if l.indent == 0 {
+			switch {
+			case !started && strings.HasPrefix(text, "%"):
+				// directives are ignored.
+				l.blank = true
+			case text == "---" || strings.HasPrefix(text, "--- "):
+				if started {
+					return nil, p.errorf(l, "multiple documents are not supported")
+				}
+				started = true
+				l.text = strings.TrimLeft(text[3:], " ")
+				l.indent = 4
+				l.blank = l.text == "" || l.text[0] == '#'
+			case text == "...":
+				p.lines = append(p.lines, yamlLine{num: l.num, raw: raw, blank: true})
+				return p.document()
+			}
+		}
+		started = true
+		p.lines = append(p.lines, l)
+	}
+	return p.document()
+}
+
+// document parses the lines of the document.
+func (p *yamlParser) document() (interface{}, error) {
+	p.skipBlank()
+	if p.pos == len(p.lines) {
+		return nil, nil
+	}
+	v, err := p.node()
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	if p.pos < len(p.lines) {
+		return nil, p.errorf(p.lines[p.pos], "unexpected content")
+	}
+	return v, nil
+}
+
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) && p.lines[p.pos].blank {
+		p.pos++
+	}
+}
+
+// node parses the node starting at the current line.
+func (p *yamlParser) node() (interface{}, error) {
+	l := p.lines[p.pos]
+	if isSequenceItem(l.text) {
+		return p.sequence(l.indent)
+	}
+	if _, _, ok := splitMappingKey(l.text); ok {
+		return p.mapping(l.indent)
+	}
+	p.pos++
+	return p.value(l, stripComment(l.text))
+}
+
+// child parses the node nested in the line preceding the current line, or
+// returns nil if there is none.
+func (p *yamlParser) child(indent int) (interface{}, error) {
+	p.skipBlank()
+	if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		return p.node()
+	}
+	return nil, nil
+}
+
+func (p *yamlParser) sequence(indent int) (interface{}, error) {
+	seq := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.pos == len(p.lines) {
+			return seq, nil
+		}
+		l := &p.lines[p.pos]
+		if l.indent > indent {
+			return nil, p.errorf(*l, "unexpected indentation")
+		}
+		if l.indent < indent || !isSequenceItem(l.text) {
+			return seq, nil
+		}
+		rest := strings.TrimLeft(l.text[1:], " ")
+		var v interface{}
+		var err error
+		if rest == "" || rest[0] == '#' {
+			p.pos++
+			v, err = p.child(indent)
+		} else {
+			// the item starts on the line of the dash, and is parsed as if
+			// the line was indented to the item.
+			l.indent += len(l.text) - len(rest)
+			l.text = rest
+			v, err = p.node()
+		}
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, v)
+	}
+}
+
+func (p *yamlParser) mapping(indent int) (interface{}, error) {
+	m := map[string]interface{}{}
+	for {
+		p.skipBlank()
+		if p.pos == len(p.lines) {
+			return m, nil
+		}
+		l := p.lines[p.pos]
+		if l.indent < indent {
+			return m, nil
+		}
+		if l.indent > indent {
+			return nil, p.errorf(l, "unexpected indentation")
+		}
+		key, rest, ok := splitMappingKey(l.text)
+		if !ok {
+			if isSequenceItem(l.text) {
+				return nil, p.errorf(l, "unexpected sequence item in mapping")
+			}
+			return nil, p.errorf(l, "expected a mapping key")
+		}
+		k, err := p.key(l, key)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := m[k]; dup {
+			return nil, p.errorf(l, "duplicate key: "+k)
+		}
+		p.pos++
+
+		var v interface{}
+		rest = stripComment(rest)
+		switch {
+		case rest == "":
+			// a block sequence may be indented the same as its key.
+			p.skipBlank()
+			if p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isSequenceItem(p.lines[p.pos].text) {
+				v, err = p.sequence(indent)
+			} else {
+				v, err = p.child(indent)
+			}
+		case rest[0] == '|' || rest[0] == '>':
+			v, err = p.blockScalar(l, rest, indent)
+		default:
+			v, err = p.value(l, rest)
+		}
+		if err != nil {
+			return nil, err
+		}
+		m[k] = v
+	}
+}
+
+// key returns the string of a mapping key.
+func (p *yamlParser) key(l yamlLine, key string) (string, error) {
+	if f := unsupportedFeature(key); f != "" {
+		return "", p.errorf(l, "unsupported YAML feature: "+f)
+	}
+	if key != "" && (key[0] == '"' || key[0] == '\'') {
+		s, n, err := unquoteYAML(key)
+		if err != nil {
+			return "", p.errorf(l, err.Error())
+		}
+		if n != len(key) {
+			return "", p.errorf(l, "invalid mapping key: "+key)
+		}
+		return s, nil
+	}
+	return key, nil
+}
+
+// value parses a scalar or flow collection starting on line l. Flow
+// collections may continue on the following lines.
+func (p *yamlParser) value(l yamlLine, s string) (interface{}, error) {
+	if f := unsupportedFeature(s); f != "" {
+		return nil, p.errorf(l, "unsupported YAML feature: "+f)
+	}
+	if s != "" && (s[0] == '[' || s[0] == '{') {
+		for !flowClosed(s) && p.pos < len(p.lines) {
+			if !p.lines[p.pos].blank {
+				s += " " + stripComment(p.lines[p.pos].text)
+			}
+			p.pos++
+		}
+		f := &flowParser{s: s}
+		v, err := f.value()
+		if err == nil {
+			f.skipSpace()
+			if f.pos < len(f.s) {
+				err = errorString("unexpected characters after flow collection")
+			}
+		}
+		if err != nil {
+			return nil, p.errorf(l, err.Error())
+		}
+		return v, nil
+	}
+	if s != "" && (s[0] == '"' || s[0] == '\'') {
+		str, n, err := unquoteYAML(s)
+		if err != nil {
+			return nil, p.errorf(l, err.Error())
+		}
+		if strings.TrimSpace(s[n:]) != "" {
+			return nil, p.errorf(l, "unexpected characters after quoted string")
+		}
+		return str, nil
+	}
+	// plain scalars spanning several lines are not supported.
+	if p.pos < len(p.lines) {
+		if next := p.lines[p.pos]; !next.blank && next.indent > l.indent && !isSequenceItem(next.text) {
+			if _, _, ok := splitMappingKey(next.text); !ok {
+				return nil, p.errorf(next, "multi-line plain scalars are not supported")
+			}
+		}
+	}
+	return resolveScalar(s), nil
+}
+
+// blockScalar parses a literal (|) or folded (>) block scalar with the given
+// header, nested in a line indented by indent.
+func (p *yamlParser) blockScalar(l yamlLine, header string, indent int) (interface{}, error) {
+	folded := header[0] == '>'
+	chomp := byte(0)
+	contentIndent := 0
+	for _, c := range []byte(header[1:]) {
+		switch {
+		case (c == '-' || c == '+') && chomp == 0:
+			chomp = c
+		case c >= '1' && c <= '9' && contentIndent == 0:
+			contentIndent = indent + int(c-'0')
+		default:
+			return nil, p.errorf(l, "invalid block scalar header: "+header)
+		}
+	}
+
+	var lines []string
+	for ; p.pos < len(p.lines); p.pos++ {
+		raw := p.lines[p.pos].raw
+		if strings.TrimSpace(raw) == "" {
+			lines = append(lines, "")
+			continue
+		}
+		n := len(raw) - len(strings.TrimLeft(raw, " "))
+		if contentIndent == 0 {
+			if n <= indent {
+				break
+			}
+			contentIndent = n
+		}
+		if n < contentIndent {
+			break
+		}
+		lines = append(lines, raw[contentIndent:])
+	}
+
+	trailing := 0
+	for trailing < len(lines) && lines[len(lines)-1-trailing] == "" {
+		trailing++
+	}
+	lines = lines[:len(lines)-trailing]
+
+	var s string
+	if folded {
+		var b strings.Builder
+		for _, line := range lines {
+			switch {
+			case line == "":
+				b.WriteByte('\n')
+			case b.Len() > 0 && !strings.HasSuffix(b.String(), "\n"):
+				b.WriteByte(' ')
+				b.WriteString(line)
+			default:
+				b.WriteString(line)
+			}
+		}
+		s = b.String()
+	} else {
+		s = strings.Join(lines, "\n")
+	}
+	switch {
+	case chomp == '-' || len(lines) == 0 && chomp != '+':
+	case chomp == '+':
+		s += "\n" + strings.Repeat("\n", trailing)
+	default:
+		s += "\n"
+	}
+	return s, nil
+}
+
+func (p *yamlParser) errorf(l yamlLine, msg string) error {
+	return &SyntaxError{Name: p.name, Line: l.num, Column: l.indent + 1, Msg: msg}
+}
+
+// unsupportedFeature returns the YAML feature starting a node that is not
+// supported, anchors (&a), aliases (*a) or tags (!!str), or "" if there is
+// none. Plain scalars cannot start with these indicators, so rather than
+// decoding them as strings, they are reported.
+func unsupportedFeature(s string) string {
+	if s == "" {
+		return ""
+	}
+	switch s[0] {
+	case '&':
+		return "anchors"
+	case '*':
+		return "aliases"
+	case '!':
+		return "tags"
+	}
+	return ""
+}
+
+// isSequenceItem reports whether a line starts a block sequence item.
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitMappingKey splits a block mapping entry into its key and the text
+// following the colon.
+func splitMappingKey(text string) (string, string, bool) {
+	if text == "" || text[0] == '[' || text[0] == '{' || text[0] == '#' {
+		return "", "", false
+	}
+	start := 0
+	if text[0] == '"' || text[0] == '\'' {
+		_, n, err := unquoteYAML(text)
+		if err != nil {
+			return "", "", false
+		}
+		start = n
+	}
+	for i := start; i < len(text); i++ {
+		switch text[i] {
+		case ':':
+			if i+1 == len(text) || text[i+1] == ' ' {
+				return strings.TrimRight(text[:i], " "), strings.TrimLeft(text[i+1:], " "), true
+			}
+		case '#':
+			if i > 0 && text[i-1] == ' ' {
+				return "", "", false
+			}
+		}
+		if start > 0 && text[i] != ' ' && text[i] != ':' {
+			// a quoted key must be followed by a colon.
+			return "", "", false
+		}
+	}
+	return "", "", false
+}
+
+// stripComment removes a trailing comment from a value. A # starts a comment
+// when it is preceded by a space and is not within quotes.
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			// quotes only start a string at the start of a scalar.
+			if i == 0 || strings.IndexByte(" [{,:", s[i-1]) >= 0 {
+				quote = c
+			}
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return strings.TrimRight(s[:i], " \t")
+		}
+	}
+	return strings.TrimRight(s, " \t")
+}
+
+// flowClosed reports whether the brackets of a flow collection are balanced.
+func flowClosed(s string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || strings.IndexByte(" [{,:", s[i-1]) >= 0 {
+				quote = c
+			}
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+type errorString string
+
+func (e errorString) Error() string { return string(e) }
+
+// flowParser parses a flow collection.
+type flowParser struct {
+	s   string
+	pos int
+}
+
+func (f *flowParser) skipSpace() {
+	for f.pos < len(f.s) && (f.s[f.pos] == ' ' || f.s[f.pos] == '\t') {
+		f.pos++
+	}
+}
+
+func (f *flowParser) value() (interface{}, error) {
+	f.skipSpace()
+	if f.pos == len(f.s) {
+		return nil, errorString("unexpected end of flow collection")
+	}
+	switch f.s[f.pos] {
+	case '[':
+		f.pos++
+		seq := []interface{}{}
+		for {
+			f.skipSpace()
+			if f.pos < len(f.s) && f.s[f.pos] == ']' {
+				f.pos++
+				return seq, nil
+			}
+			v, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, v)
+			if err := f.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		f.pos++
+		m := map[string]interface{}{}
+		for {
+			f.skipSpace()
+			if f.pos < len(f.s) && f.s[f.pos] == '}' {
+				f.pos++
+				return m, nil
+			}
+			k, err := f.scalar(true)
+			if err != nil {
+				return nil, err
+			}
+			if k == "" {
+				return nil, errorString("missing key in flow mapping")
+			}
+			key := k.(string)
+			f.skipSpace()
+			var v interface{}
+			if f.pos < len(f.s) && f.s[f.pos] == ':' {
+				f.pos++
+				f.skipSpace()
+				if f.pos < len(f.s) && f.s[f.pos] != ',' && f.s[f.pos] != '}' {
+					v, err = f.value()
+					if err != nil {
+						return nil, err
+					}
+				}
+			}
+			m[key] = v
+			if err := f.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return f.scalar(false)
+}
+
+// separator consumes the comma following an item of a flow collection, or
+// leaves the closing bracket to be consumed.
+func (f *flowParser) separator(end byte) error {
+	f.skipSpace()
+	if f.pos < len(f.s) {
+		switch f.s[f.pos] {
+		case ',':
+			f.pos++
+			return nil
+		case end:
+			return nil
+		}
+	}
+	return errorString("expected , or " + string(end) + " in flow collection")
+}
+
+// scalar parses a quoted or plain scalar of a flow collection. Plain keys end
+// at a colon; keys are always strings.
+func (f *flowParser) scalar(key bool) (interface{}, error) {
+	f.skipSpace()
+	if f.pos < len(f.s) && (f.s[f.pos] == '"' || f.s[f.pos] == '\'') {
+		s, n, err := unquoteYAML(f.s[f.pos:])
+		if err != nil {
+			return nil, err
+		}
+		f.pos += n
+		return s, nil
+	}
+	if f.pos < len(f.s) {
+		if feature := unsupportedFeature(f.s[f.pos:]); feature != "" {
+			return nil, errorString("unsupported YAML feature: " + feature)
+		}
+	}
+	start := f.pos
+	for f.pos < len(f.s) {
+		c := f.s[f.pos]
+		if c == ',' || c == ']' || c == '}' || key && c == ':' {
+			break
+		}
+		if c == ':' && (f.pos+1 == len(f.s) || strings.IndexByte(" ,]}", f.s[f.pos+1]) >= 0) {
+			break
+		}
+		f.pos++
+	}
+	s := strings.TrimSpace(f.s[start:f.pos])
+	if key {
+		return s, nil
+	}
+	return resolveScalar(s), nil
+}
+
+// unquoteYAML unquotes the single or double quoted string at the start of s,
+// and returns the string and the length of its quoted form.
+func unquoteYAML(s string) (string, int, error) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if quote == '\'' {
+			if c == '\'' {
+				if i+1 < len(s) && s[i+1] == '\'' {
+					b.WriteByte('\'')
+					i++
+					continue
+				}
+				return b.String(), i + 1, nil
+			}
+			b.WriteByte(c)
+			continue
+		}
+		switch c {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\\':
+			i++
+			if i == len(s) {
+				break
+			}
+			switch e := s[i]; e {
+			case '0':
+				b.WriteByte(0)
+			case 'a':
+				b.WriteByte('\a')
+			case 'b':
+				b.WriteByte('\b')
+			case 't', '\t':
+				b.WriteByte('\t')
+			case 'n':
+				b.WriteByte('\n')
+			case 'v':
+				b.WriteByte('\v')
+			case 'f':
+				b.WriteByte('\f')
+			case 'r':
+				b.WriteByte('\r')
+			case 'e':
+				b.WriteByte(0x1b)
+			case ' ', '"', '/', '\\':
+				b.WriteByte(e)
+			case 'x', 'u', 'U':
+				n := map[byte]int{'x': 2, 'u': 4, 'U': 8}[e]
+				if i+n >= len(s) {
+					return "", 0, errorString("invalid escape sequence")
+				}
+				r, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+				if err != nil || !utf8.ValidRune(rune(r)) {
+					return "", 0, errorString("invalid escape sequence")
+				}
+				b.WriteRune(rune(r))
+				i += n
+			default:
+				return "", 0, errorString("invalid escape sequence: \\" + string(e))
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, errorString("unterminated quoted string")
+}
+
+var (
+	yamlInt   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlFloat = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+)
+
+// resolveScalar returns the value of a plain scalar, as resolved by the YAML
+// core schema.
+func resolveScalar(s string) interface{} {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
+		return math.Inf(1)
+	case "-.inf", "-.Inf", "-.INF":
+		return math.Inf(-1)
+	case ".nan", ".NaN", ".NAN":
+		return math.NaN()
+	}
+	switch {
+	case yamlInt.MatchString(s):
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+	case strings.HasPrefix(s, "0x"):
+		if n, err := strconv.ParseInt(s[2:], 16, 64); err == nil {
+			return n
+		}
+	case strings.HasPrefix(s, "0o"):
+		if n, err := strconv.ParseInt(s[2:], 8, 64); err == nil {
+			return n
+		}
+	}
+	if yamlFloat.MatchString(s) {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	return s
+}
@@ -0,0 +1,120 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package data_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/eriklott/mustache/data"
+)
+
+type obj = map[string]interface{}
+type arr = []interface{}
+
+func TestDecode_YAML(t *testing.T) {
+	tt := []struct {
+		name string
+		text string
+		want obj
+	}{
+		{
+			name: "Scalars",
+			text: "s: hello world\nq: \"a\\tb\\u00e9\"\nsq: 'it''s'\nn: -12\nh: 0x1f\nf: 1.5e3\nt: true\nnull: ~\nempty:\ncolon: a:b\nurl: http://x.y/z\nv: 1.2.3\n",
+			want: obj{"s": "hello world", "q": "a\tbé", "sq": "it's", "n": int64(-12), "h": int64(31), "f": 1500.0, "t": true, "null": nil, "empty": nil, "colon": "a:b", "url": "http://x.y/z", "v": "1.2.3"},
+		},
+		{
+			name: "Comments",
+			text: "---\n# heading\na: x # trailing\nb: \"# not\" # trailing\nc: a#b\n...\n",
+			want: obj{"a": "x", "b": "# not", "c": "a#b"},
+		},
+		{
+			name: "Nested",
+			text: "user:\n  name: Ann\n  address:\n    city: Oslo\n\n  \"quoted key\": 1\nother: 2\n",
+			want: obj{"user": obj{"name": "Ann", "address": obj{"city": "Oslo"}, "quoted key": int64(1)}, "other": int64(2)},
+		},
+		{
+			name: "Sequences",
+			text: "a:\n- 1\n- x\nb:\n  -\n    c: 1\n  - c: 2\n    d: 3\n  - - x\n    - y\n",
+			want: obj{
+				"a": arr{int64(1), "x"},
+				"b": arr{obj{"c": int64(1)}, obj{"c": int64(2), "d": int64(3)}, arr{"x", "y"}},
+			},
+		},
+		{
+			name: "Flow",
+			text: "a: [1, two, \"th,ree\", [], {}]\nb: {x: 1, 'y': [a, b], z}\nc: [\n  1, # one\n  2,\n]\n",
+			want: obj{
+				"a": arr{int64(1), "two", "th,ree", arr{}, obj{}},
+				"b": obj{"x": int64(1), "y": arr{"a", "b"}, "z": nil},
+				"c": arr{int64(1), int64(2)},
+			},
+		},
+		{
+			name: "BlockScalars",
+			text: "lit: |\n  a\n    b\n\n  c\n\nstrip: |-\n  a\nkeep: |+\n  a\n\nfold: >\n  a\n  b\n\n  c\nindent: |2\n    a\n",
+			want: obj{"lit": "a\n  b\n\nc\n", "strip": "a", "keep": "a\n\n", "fold": "a b\nc\n", "indent": "  a\n"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := data.Decode("data.yaml", []byte(tc.text), data.YAML)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("unexpected data, got:%#v, want:%#v", got, tc.want)
+			}
+		})
+	}
+
+	m, err := data.Decode("data.yaml", []byte("a: .inf\nb: .nan"), data.YAML)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f, ok := m["a"].(float64); !ok || !math.IsInf(f, 1) {
+		t.Errorf("unexpected infinity: %v", m["a"])
+	}
+	if f, ok := m["b"].(float64); !ok || !math.IsNaN(f) {
+		t.Errorf("unexpected NaN: %v", m["b"])
+	}
+}
+
+func TestDecode_YAMLError(t *testing.T) {
+	tt := []struct {
+		name string
+		text string
+		err  string
+	}{
+		{name: "Indentation", text: "a: 1\n  b: 2", err: "data.yaml:2:3: unexpected indentation"},
+		{name: "Tab", text: "a:\n\tb: 2", err: "data.yaml:2:1: tabs are not allowed in indentation"},
+		{name: "Duplicate", text: "a: 1\na: 2", err: "data.yaml:2:1: duplicate key: a"},
+		{name: "NotKey", text: "a: 1\nb", err: "data.yaml:2:1: expected a mapping key"},
+		{name: "Sequence", text: "a: 1\n- b", err: "data.yaml:2:1: unexpected sequence item in mapping"},
+		{name: "Unterminated", text: "a: \"b", err: "data.yaml:1:1: unterminated quoted string"},
+		{name: "Flow", text: "a: [1, 2", err: "data.yaml:1:1: expected , or ] in flow collection"},
+		{name: "MultiLine", text: "a: b\n  c", err: "data.yaml:2:3: multi-line plain scalars are not supported"},
+		{name: "Documents", text: "a: 1\n---\nb: 2", err: "data.yaml:2:1: multiple documents are not supported"},
+		{name: "Anchor", text: "a: &x 1\nb: 2", err: "data.yaml:1:1: unsupported YAML feature: anchors"},
+		{name: "AnchorBlock", text: "a: 1\nb: &x\n  c: 2", err: "data.yaml:2:1: unsupported YAML feature: anchors"},
+		{name: "Alias", text: "a: 1\nb: *x", err: "data.yaml:2:1: unsupported YAML feature: aliases"},
+		{name: "AliasItem", text: "a:\n  - 1\n  - *x", err: "data.yaml:3:5: unsupported YAML feature: aliases"},
+		{name: "AliasKey", text: "*x : 1", err: "data.yaml:1:1: unsupported YAML feature: aliases"},
+		{name: "Tag", text: "a: !!str 1", err: "data.yaml:1:1: unsupported YAML feature: tags"},
+		{name: "FlowTag", text: "a: [1, !!str 2]", err: "data.yaml:1:1: unsupported YAML feature: tags"},
+		{name: "FlowAlias", text: "a: {b: *x}", err: "data.yaml:1:1: unsupported YAML feature: aliases"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := data.Decode("data.yaml", []byte(tc.text), data.YAML)
+			if _, ok := err.(*data.SyntaxError); !ok || err.Error() != tc.err {
+				t.Errorf("unexpected error, got:%v, want:%s", err, tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package mustache
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/eriklott/mustache/ast"
+)
+
+// Doc is the documentation of a template, read from the doc comments at its
+// top: the comment tags preceding any other tag or text, other than
+// whitespace. Within a doc comment, lines of the form
+//
+//	@param name [type] [- description]
+//	@partial name [- description]
+//
+// document a key of the data the template expects and a partial it includes.
+// The remaining lines form the description of the template.
+type Doc struct {
+	Name        string  // name of the template
+	Description string  // the doc comment text, other than @param and @partial lines
+	Params      []Param // the @param lines, in order
+	Partials    []Param // the @partial lines, in order; Type is always empty
+}
+
+// Param is a documented parameter or partial of a template.
+type Param struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+// Doc returns the documentation of the named template. An error is returned
+// if a @param or @partial line does not name its parameter.
+func (t *Template) Doc(name string) (*Doc, error) {
+	tree, ok := t.treeMap[name]
+	if !ok {
+		return nil, fmt.Errorf("template not found: %s", name)
+	}
+	doc := &Doc{Name: name}
+	var desc []string
+	for _, c := range DocComments(tree) {
+		for _, line := range strings.Split(c.Text, "\n") {
+			line = strings.TrimSpace(line)
+			tag, rest := cutSpace(line)
+			switch tag {
+			case "@param", "@partial":
+				p, ok := parseParam(rest, tag == "@param")
+				if !ok {
+					return nil, fmt.Errorf("%s:%d:%d: missing name in %s", tree.Name, c.Line, c.Column, tag)
+				}
+				if tag == "@param" {
+					doc.Params = append(doc.Params, p)
+				} else {
+					doc.Partials = append(doc.Partials, p)
+				}
+			default:
+				desc = append(desc, line)
+			}
+		}
+	}
+	doc.Description = strings.TrimSpace(strings.Join(desc, "\n"))
+	return doc, nil
+}
+
+// DocComments returns the doc comments of a tree: the comment tags preceding
+// any other tag, or any text other than whitespace.
+func DocComments(tree *ast.Tree) []*ast.Comment {
+	var comments []*ast.Comment
+	for _, node := range tree.Nodes {
+		switch n := node.(type) {
+		case *ast.Comment:
+			comments = append(comments, n)
+		case *ast.Text:
+			if strings.TrimSpace(n.Text) != "" {
+				return comments
+			}
+		default:
+			return comments
+		}
+	}
+	return comments
+}
+
+// parseParam parses the text following a @param or @partial tag. The type is
+// only read for parameters.
+func parseParam(s string, typed bool) (Param, bool) {
+	var p Param
+	p.Name, s = cutSpace(s)
+	if p.Name == "" || p.Name == "-" {
+		return p, false
+	}
+	if typed && s != "" && !strings.HasPrefix(s, "-") {
+		p.Type, s = cutSpace(s)
+	}
+	p.Description = strings.TrimSpace(strings.TrimPrefix(s, "-"))
+	return p, true
+}
+
+// cutSpace splits s around the first run of whitespace.
+func cutSpace(s string) (string, string) {
+	i := strings.IndexAny(s, " \t")
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], strings.TrimSpace(s[i:])
+}
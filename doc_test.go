@@ -0,0 +1,87 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package mustache_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eriklott/mustache"
+)
+
+func TestTemplate_Doc(t *testing.T) {
+	tt := []struct {
+		name string
+		text string
+		doc  *mustache.Doc
+		err  string
+	}{
+		{
+			name: "Empty",
+			text: "Hello",
+			doc:  &mustache.Doc{Name: "main"},
+		},
+		{
+			name: "Description",
+			text: "{{! Greets a user.\n  Second line. }}\n{{! Third line. }}\nHello {{! not doc }}",
+			doc:  &mustache.Doc{Name: "main", Description: "Greets a user.\nSecond line.\nThird line."},
+		},
+		{
+			name: "Params",
+			text: "{{! Welcome email.\n@param user User - the recipient\n@param  date\n@param count - number of items }}",
+			doc: &mustache.Doc{
+				Name:        "main",
+				Description: "Welcome email.",
+				Params: []mustache.Param{
+					{Name: "user", Type: "User", Description: "the recipient"},
+					{Name: "date"},
+					{Name: "count", Description: "number of items"},
+				},
+			},
+		},
+		{
+			name: "Partials",
+			text: "  {{! @partial footer }}\n{{! @partial header - the page header }}\n{{>header}}",
+			doc: &mustache.Doc{
+				Name: "main",
+				Partials: []mustache.Param{
+					{Name: "footer"},
+					{Name: "header", Description: "the page header"},
+				},
+			},
+		},
+		{
+			name: "AfterTag",
+			text: "{{a}}{{! @param user }}",
+			doc:  &mustache.Doc{Name: "main"},
+		},
+		{
+			name: "MissingName",
+			text: "\n{{! @param - the recipient }}",
+			err:  "main:2:1: missing name in @param",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl := parseTemplates(t, map[string]string{"main": tc.text})
+			doc, err := tmpl.Doc("main")
+			var errStr string
+			if err != nil {
+				errStr = err.Error()
+			}
+			if errStr != tc.err {
+				t.Fatalf("unexpected error, got:%s, want:%s", errStr, tc.err)
+			}
+			if !reflect.DeepEqual(doc, tc.doc) {
+				t.Errorf("unexpected doc, got:%+v, want:%+v", doc, tc.doc)
+			}
+		})
+	}
+
+	if _, err := mustache.NewTemplate().Doc("missing"); err == nil {
+		t.Error("expected error for missing template")
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package mustache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/eriklott/mustache/ast"
+)
+
+// PartialGraph is the graph of partial tags between the templates of a
+// Template. Nodes holds the names of the templates in sorted order, and
+// Edges holds one edge for each partial tag.
+type PartialGraph struct {
+	Nodes []string      `json:"nodes"`
+	Edges []PartialEdge `json:"edges"`
+}
+
+// PartialEdge is a partial tag in the template From that includes the
+// template To.
+type PartialEdge struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	InSection bool   `json:"inSection,omitempty"` // the tag is inside a section
+	Missing   bool   `json:"missing,omitempty"`   // the template To does not exist
+}
+
+// PartialGraph returns the graph of partial tags between the templates.
+func (t *Template) PartialGraph() *PartialGraph {
+	g := &PartialGraph{}
+	for name := range t.treeMap {
+		g.Nodes = append(g.Nodes, name)
+	}
+	sort.Strings(g.Nodes)
+
+	for _, name := range g.Nodes {
+		var edges func(nodes []ast.Node, inSection bool)
+		edges = func(nodes []ast.Node, inSection bool) {
+			for _, node := range nodes {
+				switch n := node.(type) {
+				case *ast.Section:
+					edges(n.Nodes, true)
+				case *ast.Partial:
+					to := n.Resolve(name)
+					_, ok := t.treeMap[to]
+					g.Edges = append(g.Edges, PartialEdge{
+						From:      name,
+						To:        to,
+						Line:      n.Line,
+						Column:    n.Column,
+						InSection: inSection,
+						Missing:   !ok,
+					})
+				}
+			}
+		}
+		edges(t.treeMap[name].Nodes, false)
+	}
+	return g
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language. Edges to missing
+// templates are drawn dashed.
+func (g *PartialGraph) WriteDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("digraph partials {\n")
+	for _, name := range g.Nodes {
+		fmt.Fprintf(bw, "\t%s;\n", strconv.Quote(name))
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(bw, "\t%s -> %s", strconv.Quote(e.From), strconv.Quote(e.To))
+		if e.Missing {
+			bw.WriteString(" [style=dashed]")
+		}
+		bw.WriteString(";\n")
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+// PartialError reports a partial tag that includes a missing template, or
+// that completes a cycle of partials.
+type PartialError struct {
+	Name    string // name of the template containing the tag
+	Line    int
+	Column  int
+	Partial string   // the included template
+	Cycle   []string // for cycles, the templates of the cycle, starting and ending with the same template
+}
+
+func (e *PartialError) Error() string {
+	if len(e.Cycle) > 0 {
+		return fmt.Sprintf("%s:%d:%d: recursive partial: %s", e.Name, e.Line, e.Column, strings.Join(e.Cycle, " > "))
+	}
+	return fmt.Sprintf("%s:%d:%d: partial not found: %s", e.Name, e.Line, e.Column, e.Partial)
+}
+
+// PartialErrors is the list of problems reported by Validate.
+type PartialErrors []*PartialError
+
+func (e PartialErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i := range e {
+		msgs[i] = e[i].Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Validate checks the partial tags of every template. It reports partials
+// that name a missing template, and cycles of templates that include each
+// other, with the full path of the cycle. Each cycle is reported once. A
+// cycle is reported even if it is guarded by a section and would terminate
+// when rendered; the InSection field of the graph's edges tells them apart.
+// If any problem is found, Validate returns a PartialErrors.
+func (t *Template) Validate() error {
+	g := t.PartialGraph()
+	var errs PartialErrors
+
+	out := make(map[string][]PartialEdge)
+	for _, e := range g.Edges {
+		if e.Missing {
+			errs = append(errs, &PartialError{Name: t.treeMap[e.From].Name, Line: e.Line, Column: e.Column, Partial: e.To})
+			continue
+		}
+		out[e.From] = append(out[e.From], e)
+	}
+
+	// depth first search, reporting each edge back to a template on the
+	// current path as a cycle.
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	seen := make(map[string]bool)
+	var path []string
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = visiting
+		path = append(path, name)
+		for _, e := range out[name] {
+			switch state[e.To] {
+			case unvisited:
+				visit(e.To)
+			case visiting:
+				var cycle []string
+				for i := len(path) - 1; i >= 0; i-- {
+					if path[i] == e.To {
+						cycle = append(append(cycle, path[i:]...), e.To)
+						break
+					}
+				}
+				key := cycleKey(cycle)
+				if !seen[key] {
+					seen[key] = true
+					errs = append(errs, &PartialError{Name: t.treeMap[e.From].Name, Line: e.Line, Column: e.Column, Partial: e.To, Cycle: cycle})
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+	}
+	for _, name := range g.Nodes {
+		if state[name] == unvisited {
+			visit(name)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// cycleKey identifies a cycle regardless of the template it starts at.
+func cycleKey(cycle []string) string {
+	nodes := cycle[:len(cycle)-1]
+	min := 0
+	for i := range nodes {
+		if nodes[i] < nodes[min] {
+			min = i
+		}
+	}
+	rotated := append(append([]string{}, nodes[min:]...), nodes[:min]...)
+	return strings.Join(rotated, "\x00")
+}
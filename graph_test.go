@@ -0,0 +1,103 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package mustache_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eriklott/mustache"
+)
+
+func parseTemplates(t *testing.T, templates map[string]string) *mustache.Template {
+	t.Helper()
+	tmpl := mustache.NewTemplate()
+	for name, text := range templates {
+		err := tmpl.Parse(name, text)
+		if err != nil {
+			t.Fatalf("failed to parse template %s: %v", name, err)
+		}
+	}
+	return tmpl
+}
+
+func TestTemplate_Validate(t *testing.T) {
+	tt := []struct {
+		name      string
+		templates map[string]string
+		errs      []string
+	}{
+		{
+			name:      "Valid",
+			templates: map[string]string{"main": "{{>a}}{{>b}}", "a": "{{>b}}", "b": "b"},
+		},
+		{
+			name:      "Missing",
+			templates: map[string]string{"main": "{{>a}}\n {{>missing}}", "a": "a"},
+			errs:      []string{"main:2:2: partial not found: missing"},
+		},
+		{
+			name:      "Self",
+			templates: map[string]string{"main": "{{#items}}{{>main}}{{/items}}"},
+			errs:      []string{"main:1:11: recursive partial: main > main"},
+		},
+		{
+			name:      "Cycle",
+			templates: map[string]string{"main": "{{>a}}", "a": "{{>b}}", "b": "{{>c}}", "c": "{{>a}}"},
+			errs:      []string{"c:1:1: recursive partial: a > b > c > a"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl := parseTemplates(t, tc.templates)
+			err := tmpl.Validate()
+			var got []string
+			var errs mustache.PartialErrors
+			if errors.As(err, &errs) {
+				for _, e := range errs {
+					got = append(got, e.Error())
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.errs) {
+				t.Errorf("unexpected errors, got:%q, want:%q", got, tc.errs)
+			}
+		})
+	}
+}
+
+func TestTemplate_PartialGraph(t *testing.T) {
+	tmpl := parseTemplates(t, map[string]string{
+		"main":   "{{>header}}{{#items}}{{>item}}{{/items}}",
+		"header": "header",
+	})
+	g := tmpl.PartialGraph()
+
+	b, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"nodes":["header","main"],"edges":[` +
+		`{"from":"main","to":"header","line":1,"column":1},` +
+		`{"from":"main","to":"item","line":1,"column":22,"inSection":true,"missing":true}]}`
+	if got := string(b); got != want {
+		t.Errorf("unexpected json, got:%s, want:%s", got, want)
+	}
+
+	var dot strings.Builder
+	err = g.WriteDOT(&dot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "digraph partials {\n\t\"header\";\n\t\"main\";\n\t\"main\" -> \"header\";\n\t\"main\" -> \"item\" [style=dashed];\n}\n"
+	if got := dot.String(); got != want {
+		t.Errorf("unexpected dot, got:%q, want:%q", got, want)
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package helpers provides helpers for common text transformations in
+// templates.
+//
+// Register adds the helpers to a template, where they are found when a key
+// cannot be found in the data, and are applied to the rendered text of their
+// section:
+//
+//	{{#upper}}Hello {{name}}{{/upper}}
+//
+// Helpers taking arguments read them from the start of the section text,
+// each followed by a vertical bar:
+//
+//	{{#truncate}}20|{{description}}{{/truncate}}
+//
+// A helper given malformed arguments returns its text unchanged.
+//
+// Sections of registered helpers are rendered as usual, escaping the values
+// of variable tags, and the result of a helper is written without escaping.
+// Helpers thus keep the markup of their section, but see HTML entities in
+// place of escaped characters: truncate counts and may cut them, and upper
+// changes their case. Values given with a triple mustache are seen as they
+// are, which suits json, whose result is safe within script elements:
+//
+//	<script>var name = {{#json}}{{{name}}}{{/json}};</script>
+//
+// The results of the other helpers are not escaped, so triple mustache
+// values must only be given to them when they hold trusted text.
+//
+// Each helper is also a func(string) string, which can be given in the data
+// as a lambda. Lambdas are passed the unrendered section text, and their
+// result is rendered, so helpers given as lambdas are best used on sections
+// without tags; converted to mustache.Helper, they are passed the rendered
+// text as when registered.
+package helpers
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/eriklott/mustache"
+)
+
+// helpers maps the names of the helpers to their functions.
+var helpers = map[string]func(string) string{
+	"upper":     Upper,
+	"lower":     Lower,
+	"trim":      Trim,
+	"truncate":  Truncate,
+	"join":      Join,
+	"default":   Default,
+	"pluralize": Pluralize,
+	"json":      JSON,
+	"date":      Date,
+	"urlencode": URLEncode,
+}
+
+// Register registers the helpers with a template, under the names upper,
+// lower, trim, truncate, join, default, pluralize, json, date and urlencode.
+func Register(t *mustache.Template) {
+	for name, fn := range helpers {
+		t.RegisterHelper(name, fn)
+	}
+}
+
+// Upper returns s with all letters mapped to upper case.
+func Upper(s string) string {
+	return strings.ToUpper(s)
+}
+
+// Lower returns s with all letters mapped to lower case.
+func Lower(s string) string {
+	return strings.ToLower(s)
+}
+
+// Trim returns s without leading and trailing white space.
+func Trim(s string) string {
+	return strings.TrimSpace(s)
+}
+
+// Truncate shortens text to at most n characters, ending it with an
+// ellipsis when characters are removed. The text is given as n|text.
+func Truncate(s string) string {
+	arg, text, ok := cut(s)
+	if !ok {
+		return s
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(arg))
+	if err != nil || n < 0 {
+		return s
+	}
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	i := 0
+	for j := range text {
+		if i == n {
+			return text[:j] + "…"
+		}
+		i++
+	}
+	return text
+}
+
+// Join joins the lines of text with a separator, given as separator|text.
+// Lines are trimmed of surrounding white space, and blank lines are skipped,
+// so that the items of a list section each end with a line break:
+//
+//	{{#join}}, |{{#tags}}{{.}}
+//	{{/tags}}{{/join}}
+func Join(s string) string {
+	sep, text, ok := cut(s)
+	if !ok {
+		return s
+	}
+	var items []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			items = append(items, line)
+		}
+	}
+	return strings.Join(items, sep)
+}
+
+// Default returns text, or a fallback when text is blank. The text is given
+// as fallback|text.
+func Default(s string) string {
+	fallback, text, ok := cut(s)
+	if !ok {
+		return s
+	}
+	if strings.TrimSpace(text) == "" {
+		return fallback
+	}
+	return text
+}
+
+// Pluralize returns the singular or plural form of a word for a count, given
+// as word|count or singular|plural|count. The plural form of a word is
+// formed by the rules of English when only the word is given.
+func Pluralize(s string) string {
+	args := strings.Split(s, "|")
+	if len(args) != 2 && len(args) != 3 {
+		return s
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(args[len(args)-1]), 64)
+	if err != nil {
+		return s
+	}
+	if n == 1 || n == -1 {
+		return args[0]
+	}
+	if len(args) == 3 {
+		return args[1]
+	}
+	return plural(args[0])
+}
+
+// plural returns the English plural form of a word.
+func plural(word string) string {
+	lower := strings.ToLower(word)
+	switch {
+	case word == "":
+		return word
+	case strings.HasSuffix(lower, "s"), strings.HasSuffix(lower, "x"), strings.HasSuffix(lower, "z"),
+		strings.HasSuffix(lower, "ch"), strings.HasSuffix(lower, "sh"):
+		return word + "es"
+	case len(lower) > 1 && lower[len(lower)-1] == 'y' && !strings.ContainsRune("aeiou", rune(lower[len(lower)-2])):
+		return word[:len(word)-1] + "ies"
+	}
+	return word + "s"
+}
+
+// JSON returns s as a JSON string, with quotes. The characters <, > and &
+// are escaped, so that the string can be embedded in HTML script elements.
+// In a template, the value should be given with a triple mustache, so that it
+// is encoded as it is rather than HTML escaped.
+func JSON(s string) string {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return s
+	}
+	return string(b)
+}
+
+// dateLayouts are the layouts of the dates accepted by Date.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// Date formats a date with a layout of the time package, given as
+// layout|date. The date is either in RFC 3339 format, with or without a time
+// zone or time, or a Unix time in seconds.
+func Date(s string) string {
+	layout, text, ok := cut(s)
+	if !ok {
+		return s
+	}
+	text = strings.TrimSpace(text)
+	if sec, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return time.Unix(sec, 0).UTC().Format(layout)
+	}
+	for _, l := range dateLayouts {
+		if t, err := time.Parse(l, text); err == nil {
+			return t.Format(layout)
+		}
+	}
+	return s
+}
+
+// URLEncode escapes s so it can be placed in a URL query.
+func URLEncode(s string) string {
+	return url.QueryEscape(s)
+}
+
+// cut slices s around the first vertical bar, returning the argument before
+// it and the text after it.
+func cut(s string) (arg, text string, ok bool) {
+	i := strings.IndexByte(s, '|')
+	if i < 0 {
+		return "", s, false
+	}
+	return s[:i], s[i+1:], true
+}
@@ -0,0 +1,152 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package helpers_test
+
+import (
+	"testing"
+
+	"github.com/eriklott/mustache"
+	"github.com/eriklott/mustache/helpers"
+)
+
+func TestHelpers(t *testing.T) {
+	tt := []struct {
+		name string
+		fn   func(string) string
+		in   string
+		want string
+	}{
+		{"Upper", helpers.Upper, "héllo", "HÉLLO"},
+		{"Lower", helpers.Lower, "HÉLLO", "héllo"},
+		{"Trim", helpers.Trim, " \n a b \t", "a b"},
+		{"Truncate", helpers.Truncate, "5|héllo world", "héllo…"},
+		{"Truncate/Short", helpers.Truncate, "5|héllo", "héllo"},
+		{"Truncate/Zero", helpers.Truncate, "0|abc", "…"},
+		{"Truncate/Bar", helpers.Truncate, " 3 |a|b|c", "a|b…"},
+		{"Truncate/NoArg", helpers.Truncate, "abc", "abc"},
+		{"Truncate/BadArg", helpers.Truncate, "x|abc", "x|abc"},
+		{"Truncate/Negative", helpers.Truncate, "-1|abc", "-1|abc"},
+		{"Join", helpers.Join, ", |a\n  b \n\n c\n", "a, b, c"},
+		{"Join/Empty", helpers.Join, ", |\n", ""},
+		{"Join/NoArg", helpers.Join, "a\nb", "a\nb"},
+		{"Default", helpers.Default, "Guest|Ann", "Ann"},
+		{"Default/Blank", helpers.Default, "Guest| \n", "Guest"},
+		{"Default/NoArg", helpers.Default, "Ann", "Ann"},
+		{"Pluralize/One", helpers.Pluralize, "item|1", "item"},
+		{"Pluralize/MinusOne", helpers.Pluralize, "item| -1 ", "item"},
+		{"Pluralize/Zero", helpers.Pluralize, "item|0", "items"},
+		{"Pluralize/Fraction", helpers.Pluralize, "item|1.5", "items"},
+		{"Pluralize/Es", helpers.Pluralize, "box|2", "boxes"},
+		{"Pluralize/Ch", helpers.Pluralize, "Match|2", "Matches"},
+		{"Pluralize/Ies", helpers.Pluralize, "city|2", "cities"},
+		{"Pluralize/Ys", helpers.Pluralize, "day|2", "days"},
+		{"Pluralize/Empty", helpers.Pluralize, "|2", ""},
+		{"Pluralize/Explicit", helpers.Pluralize, "person|people|3", "people"},
+		{"Pluralize/ExplicitOne", helpers.Pluralize, "person|people|1", "person"},
+		{"Pluralize/BadCount", helpers.Pluralize, "item|many", "item|many"},
+		{"Pluralize/NoArg", helpers.Pluralize, "item", "item"},
+		{"Pluralize/TooMany", helpers.Pluralize, "a|b|c|1", "a|b|c|1"},
+		{"JSON", helpers.JSON, "a \"b\"\n<c>&", `"a \"b\"\n\u003cc\u003e\u0026"`},
+		{"JSON/Invalid", helpers.JSON, "\xff", `"�"`},
+		{"Date", helpers.Date, "Jan 2, 2006|2019-03-04T05:06:07Z", "Mar 4, 2019"},
+		{"Date/Zone", helpers.Date, "15:04 MST|2019-03-04T05:06:07.5+02:00", "05:06 +0200"},
+		{"Date/Local", helpers.Date, "2006-01-02 15:04|2019-03-04T05:06:07", "2019-03-04 05:06"},
+		{"Date/Space", helpers.Date, "15:04:05|2019-03-04 05:06:07", "05:06:07"},
+		{"Date/Day", helpers.Date, "Monday| 2019-03-04 ", "Monday"},
+		{"Date/Unix", helpers.Date, "2006-01-02T15:04:05Z07:00|1551675967", "2019-03-04T05:06:07Z"},
+		{"Date/Bad", helpers.Date, "2006|tomorrow", "2006|tomorrow"},
+		{"Date/NoArg", helpers.Date, "2019-03-04", "2019-03-04"},
+		{"URLEncode", helpers.URLEncode, "a b&c=d/é", "a+b%26c%3Dd%2F%C3%A9"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.fn(tc.in); got != tc.want {
+				t.Errorf("unexpected result, got:%q, want:%q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	tt := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"Upper", "{{#upper}}Hi {{name}}{{/upper}}", "HI ANN &AMP; BOB"},
+		{"UpperMarkup", "{{#upper}}<b>{{title}}</b>{{/upper}}", "<B>MUSTACHE</B>"},
+		{"UpperTripleStache", "{{#upper}}{{{markup}}}{{/upper}}", "A<B>&C"},
+		{"Lower", "{{#lower}}Hi {{{name}}}{{/lower}}", "hi ann & bob"},
+		{"Trim", "[{{#trim}}  {{title}}\n{{/trim}}]", "[Mustache]"},
+		{"Truncate", "{{#truncate}}4|{{title}}{{/truncate}}", "Must…"},
+		{"TruncateTripleStache", "{{#truncate}}3|{{{markup}}}{{/truncate}}", "A<B…"},
+		{"Join", "{{#join}}, |{{#tags}}\n{{.}}\n{{/tags}}{{/join}}", "go, web"},
+		{"Default", "{{#default}}Guest|{{nickname}}{{/default}}", "Guest"},
+		{"Pluralize", "{{count}} {{#pluralize}}reply|{{count}}{{/pluralize}}", "2 replies"},
+		{"JSON", "var name = {{#json}}{{{name}}}{{/json}};", `var name = "Ann \u0026 Bob";`},
+		{"JSONScript", "<script>var x = {{#json}}{{{markup}}}{{/json}};</script>", `<script>var x = "A\u003cB\u003e\u0026C";</script>`},
+		{"JSONEscaped", "{{#json}}{{markup}}{{/json}}", `"A\u0026lt;B\u0026gt;\u0026amp;C"`},
+		{"Date", "{{#date}}January 2006|{{created}}{{/date}}", "March 2019"},
+		{"URLEncode", "?q={{#urlencode}}{{{name}}}{{/urlencode}}", "?q=Ann+%26+Bob"},
+		{"Nested", "{{#upper}}{{#truncate}}3|{{title}}{{/truncate}}{{/upper}}", "MUS…"},
+		{"Shadowed", "{{#title}}{{.}}{{/title}}", "Mustache"},
+	}
+	data := map[string]interface{}{
+		"name":     "Ann & Bob",
+		"markup":   "A<B>&C",
+		"title":    "Mustache",
+		"tags":     []string{"go", "web"},
+		"count":    2,
+		"created":  "2019-03-04",
+		"nickname": "",
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, compile := range []bool{false, true} {
+				tmpl := mustache.NewTemplate()
+				tmpl.ContextErrorsEnabled = true
+				helpers.Register(tmpl)
+				if err := tmpl.Parse("main", tc.text); err != nil {
+					t.Fatalf("failed to parse template: %v", err)
+				}
+				if compile {
+					if err := tmpl.Compile(); err != nil {
+						t.Fatalf("failed to compile template: %v", err)
+					}
+				}
+				got, err := tmpl.Render("main", data)
+				if err != nil {
+					t.Fatalf("failed to render template: %v", err)
+				}
+				if got != tc.want {
+					t.Errorf("unexpected response, compiled:%t, got:%q, want:%q", compile, got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestLambdas(t *testing.T) {
+	tmpl := mustache.NewTemplate()
+	if err := tmpl.Parse("main", "{{#upper}}hello{{/upper}} {{#pluralize}}box|3{{/pluralize}} {{#helper}}{{name}}{{/helper}}"); err != nil {
+		t.Fatalf("failed to parse template: %v", err)
+	}
+	data := map[string]interface{}{
+		"upper":     helpers.Upper,
+		"pluralize": helpers.Pluralize,
+		"helper":    mustache.Helper(helpers.Upper),
+		"name":      "ann",
+	}
+	want := "HELLO boxes ANN"
+	got, err := tmpl.Render("main", data)
+	if err != nil {
+		t.Fatalf("failed to render template: %v", err)
+	}
+	if got != want {
+		t.Errorf("unexpected response, got:%q, want:%q", got, want)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package i18n
+
+import (
+	"fmt"
+
+	"github.com/eriklott/mustache/ast"
+)
+
+// Ref is a reference to a message in a template.
+type Ref struct {
+	ID     string
+	Plural bool // the reference gives the key of a count
+	Name   string
+	Line   int
+	Column int
+}
+
+func (r Ref) String() string {
+	return fmt.Sprintf("%s:%d", r.Name, r.Line)
+}
+
+// Extract returns the references to messages in a parsed template, in
+// document order: the sections of the t lambda that are not inverted. A
+// section referencing a message must hold the message ID, optionally followed
+// by the key of a count, and no tags.
+func Extract(tree *ast.Tree) ([]Ref, error) {
+	var refs []Ref
+	err := extractNodes(tree.Name, tree.Nodes, &refs)
+	return refs, err
+}
+
+func extractNodes(name string, nodes []ast.Node, refs *[]Ref) error {
+	for _, node := range nodes {
+		sec, ok := node.(*ast.Section)
+		if !ok {
+			continue
+		}
+		if sec.Inverted || sec.Block != "" || len(sec.Key) != 1 || sec.Key[0] != Tag {
+			if err := extractNodes(name, sec.Nodes, refs); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, n := range sec.Nodes {
+			if _, ok := n.(*ast.Text); !ok {
+				return fmt.Errorf("%s:%d:%d: message reference contains tags", name, sec.Line, sec.Column)
+			}
+		}
+		id, countKey, err := parseRef(sec.Text)
+		if err != nil {
+			return fmt.Errorf("%s:%d:%d: %v", name, sec.Line, sec.Column, err)
+		}
+		*refs = append(*refs, Ref{ID: id, Plural: countKey != "", Name: name, Line: sec.Line, Column: sec.Column})
+	}
+	return nil
+}
+
+// Update returns a catalog of a locale for translators, holding a message
+// for each message referenced by refs. The messages have the text of the
+// messages with the same ID in prev, which may be nil, and are empty
+// otherwise. Plural messages, referenced with a count or plural in prev,
+// have a form for each plural category of the locale. Messages of prev that are no
+// longer referenced are dropped.
+func Update(prev *Catalog, locale string, refs []Ref) *Catalog {
+	c := NewCatalog(locale)
+	plural := make(map[string]bool)
+	for _, ref := range refs {
+		m := c.Message(ref.ID)
+		if m == nil {
+			m = &Message{ID: ref.ID}
+			c.Add(m)
+		}
+		m.Refs = append(m.Refs, ref.String())
+		plural[ref.ID] = plural[ref.ID] || ref.Plural
+	}
+	for _, m := range c.messages {
+		var old *Message
+		if prev != nil {
+			old = prev.Message(m.ID)
+		}
+		if !plural[m.ID] && (old == nil || old.Plural == nil) {
+			if old != nil {
+				m.Text = old.Text
+			}
+			continue
+		}
+		m.Plural = make(map[string]string)
+		for _, cat := range Categories(locale) {
+			m.Plural[cat] = ""
+			if old != nil && old.Plural != nil {
+				m.Plural[cat] = old.Plural[cat]
+			}
+		}
+		if old != nil && old.Plural == nil {
+			m.Plural[Other] = old.Text
+		}
+	}
+	return c
+}
@@ -0,0 +1,82 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package i18n_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eriklott/mustache/i18n"
+	"github.com/eriklott/mustache/parse"
+)
+
+func TestExtract(t *testing.T) {
+	text := "{{#t}}greeting.hello{{/t}}\n{{#user}}{{^t}}x{{/t}}{{#t}} inbox.unread n {{/t}}{{/user}}{{t}}{{#a.t}}{{/a.t}}\n{{#t}}greeting.hello{{/t}}"
+	tree, err := parse.Parse("main", text, "{{", "}}", parse.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs, err := i18n.Extract(tree)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []i18n.Ref{
+		{ID: "greeting.hello", Name: "main", Line: 1, Column: 1},
+		{ID: "inbox.unread", Plural: true, Name: "main", Line: 2, Column: 23},
+		{ID: "greeting.hello", Name: "main", Line: 3, Column: 1},
+	}
+	if !reflect.DeepEqual(refs, want) {
+		t.Errorf("unexpected refs, got:%#v, want:%#v", refs, want)
+	}
+
+	for text, want := range map[string]string{
+		"{{#a}}{{#t}}a{{b}}{{/t}}{{/a}}": "main:1:7: message reference contains tags",
+		"x{{#t}}{{/t}}":                  "main:1:2: invalid message reference: \"\"",
+	} {
+		tree, err := parse.Parse("main", text, "{{", "}}", parse.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := i18n.Extract(tree); err == nil || err.Error() != want {
+			t.Errorf("unexpected error, got:%v, want:%s", err, want)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	prev := i18n.NewCatalog("fr")
+	prev.Add(&i18n.Message{ID: "kept", Text: "Gardé", Refs: []string{"old:1"}})
+	prev.Add(&i18n.Message{ID: "plural", Plural: map[string]string{"one": "un", "other": "plusieurs"}})
+	prev.Add(&i18n.Message{ID: "now.plural", Text: "autre"})
+	prev.Add(&i18n.Message{ID: "dropped", Text: "Supprimé"})
+
+	refs := []i18n.Ref{
+		{ID: "kept", Name: "a", Line: 1},
+		{ID: "new", Name: "a", Line: 2},
+		{ID: "kept", Name: "b", Line: 3},
+		{ID: "plural", Name: "b", Line: 4},
+		{ID: "now.plural", Plural: true, Name: "b", Line: 5},
+		{ID: "new.plural", Name: "b", Line: 6},
+		{ID: "new.plural", Plural: true, Name: "b", Line: 7},
+	}
+	c := i18n.Update(prev, "fr", refs)
+	if c.Locale != "fr" {
+		t.Errorf("unexpected locale: %s", c.Locale)
+	}
+	want := []*i18n.Message{
+		{ID: "kept", Text: "Gardé", Refs: []string{"a:1", "b:3"}},
+		{ID: "new", Refs: []string{"a:2"}},
+		{ID: "new.plural", Plural: map[string]string{"one": "", "many": "", "other": ""}, Refs: []string{"b:6", "b:7"}},
+		{ID: "now.plural", Plural: map[string]string{"one": "", "many": "", "other": "autre"}, Refs: []string{"b:5"}},
+		{ID: "plural", Plural: map[string]string{"one": "un", "many": "", "other": "plusieurs"}, Refs: []string{"b:4"}},
+	}
+	if got := c.Messages(); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected messages, got:%v, want:%v", got, want)
+	}
+
+	if got := i18n.Update(nil, "en", refs[:1]).Messages(); len(got) != 1 || got[0].Text != "" {
+		t.Errorf("unexpected messages without previous catalog: %v", got)
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package i18n translates the messages of templates.
+//
+// Messages are referenced by ID in sections of the t lambda, optionally
+// followed by the key of a count that selects the plural form of the message:
+//
+//	{{#t}}greeting.hello{{/t}}
+//	{{#t}}inbox.unread count{{/t}}
+//
+// Translations are loaded into catalogs, one per locale, from JSON or gettext
+// .po files, and catalogs are collected in a bundle. The context of a bundle
+// for a locale provides the t lambda:
+//
+//	tmpl.Render("inbox", bundle.Context("fr-CA"), data)
+//
+// The translated text of a message is rendered against the context of its
+// section, so it can interpolate values, such as "Bonjour {{name}}". Plural
+// forms are selected by the CLDR plural category of the count in the locale
+// of the catalog holding the message.
+//
+// Extract finds the messages referenced by a parsed template, and Update
+// builds from them a catalog for translators, keeping existing translations.
+package i18n
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/eriklott/mustache"
+)
+
+// Tag is the name of the lambda referencing messages.
+const Tag = "t"
+
+// DefaultCountKey is the key of the count of plural messages referenced by
+// ID only.
+const DefaultCountKey = "count"
+
+// Message is a translated message. A message has either a text, or a text
+// for each plural category of its locale.
+type Message struct {
+	ID     string
+	Text   string
+	Plural map[string]string // text by plural category, or nil
+	Refs   []string          // template positions referencing the message, as name:line
+}
+
+// Catalog holds the messages of a locale.
+type Catalog struct {
+	Locale   string
+	messages map[string]*Message
+}
+
+// NewCatalog allocates a new catalog for a locale.
+func NewCatalog(locale string) *Catalog {
+	return &Catalog{Locale: locale, messages: make(map[string]*Message)}
+}
+
+// Add adds a message to the catalog, replacing any message with the same ID.
+func (c *Catalog) Add(m *Message) {
+	c.messages[m.ID] = m
+}
+
+// Message returns the message with an ID, or nil if there is none.
+func (c *Catalog) Message(id string) *Message {
+	return c.messages[id]
+}
+
+// Messages returns the messages of the catalog, sorted by ID.
+func (c *Catalog) Messages() []*Message {
+	ms := make([]*Message, 0, len(c.messages))
+	for _, m := range c.messages {
+		ms = append(ms, m)
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].ID < ms[j].ID })
+	return ms
+}
+
+// ReadFile reads a catalog from a JSON or .po file, by its extension. The
+// locale of a JSON catalog, or of a .po catalog without Language header, is
+// the name of the file without extension.
+func ReadFile(path string) (*Catalog, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ext := filepath.Ext(path)
+	locale := strings.TrimSuffix(filepath.Base(path), ext)
+	switch strings.ToLower(ext) {
+	case ".json":
+		return ParseJSON(path, locale, b)
+	case ".po":
+		c, err := ParsePO(path, b)
+		if err == nil && c.Locale == "" {
+			c.Locale = locale
+		}
+		return c, err
+	}
+	return nil, fmt.Errorf("%s: unknown catalog format %s", path, ext)
+}
+
+// Bundle holds the catalogs of several locales. Messages missing from the
+// catalog of a locale are looked up in the catalogs of its parent locales,
+// fr for fr-CA, then in the catalogs of the fallback locale.
+type Bundle struct {
+	Fallback string
+	catalogs map[string]*Catalog
+}
+
+// NewBundle allocates a new bundle with a fallback locale.
+func NewBundle(fallback string) *Bundle {
+	return &Bundle{Fallback: fallback, catalogs: make(map[string]*Catalog)}
+}
+
+// Add adds the messages of a catalog to the catalog of its locale in the
+// bundle, replacing messages with the same ID.
+func (b *Bundle) Add(c *Catalog) {
+	key := strings.ToLower(strings.Replace(c.Locale, "_", "-", -1))
+	dst, ok := b.catalogs[key]
+	if !ok {
+		dst = NewCatalog(c.Locale)
+		b.catalogs[key] = dst
+	}
+	for _, m := range c.messages {
+		dst.Add(m)
+	}
+}
+
+// Message looks up a message for a locale. It returns the message and the
+// locale of the catalog holding it, or nil if the message is not found.
+func (b *Bundle) Message(locale, id string) (*Message, string) {
+	for _, l := range append(fallbacks(locale), fallbacks(b.Fallback)...) {
+		if c, ok := b.catalogs[l]; ok {
+			if m := c.Message(id); m != nil {
+				return m, c.Locale
+			}
+		}
+	}
+	return nil, ""
+}
+
+// Lambda returns the t lambda of a locale. The lambda writes the ID of
+// messages that are not found.
+func (b *Bundle) Lambda(locale string) mustache.Lambda {
+	return func(text string, render mustache.RenderFunc) (string, error) {
+		id, countKey, err := parseRef(text)
+		if err != nil {
+			return "", err
+		}
+		m, msgLocale := b.Message(locale, id)
+		if m == nil {
+			return id, nil
+		}
+		s := m.Text
+		if m.Plural != nil {
+			if countKey == "" {
+				countKey = DefaultCountKey
+			}
+			count, err := render("{{{" + countKey + "}}}")
+			if err != nil {
+				return "", err
+			}
+			if s = m.Plural[PluralCategory(msgLocale, count)]; s == "" {
+				s = m.Plural[Other]
+			}
+		}
+		return render(s)
+	}
+}
+
+// Context returns a data context providing the t lambda of a locale.
+func (b *Bundle) Context(locale string) map[string]interface{} {
+	return map[string]interface{}{Tag: b.Lambda(locale)}
+}
+
+// parseRef parses the text of a t section into a message ID and the key of
+// its count, which is empty when not given.
+func parseRef(text string) (id, countKey string, err error) {
+	fields := strings.Fields(text)
+	switch len(fields) {
+	case 1:
+		return fields[0], "", nil
+	case 2:
+		return fields[0], fields[1], nil
+	}
+	return "", "", fmt.Errorf("invalid message reference: %q", text)
+}
@@ -0,0 +1,122 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package i18n_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eriklott/mustache"
+	"github.com/eriklott/mustache/i18n"
+)
+
+func newBundle() *i18n.Bundle {
+	b := i18n.NewBundle("en")
+	en := i18n.NewCatalog("en")
+	en.Add(&i18n.Message{ID: "greeting.hello", Text: "Hello {{name}}"})
+	en.Add(&i18n.Message{ID: "greeting.bye", Text: "Bye"})
+	en.Add(&i18n.Message{ID: "inbox.unread", Plural: map[string]string{"one": "{{count}} message", "other": "{{count}} messages"}})
+	b.Add(en)
+	fr := i18n.NewCatalog("fr")
+	fr.Add(&i18n.Message{ID: "greeting.hello", Text: "Bonjour {{name}}"})
+	fr.Add(&i18n.Message{ID: "inbox.unread", Plural: map[string]string{"one": "{{n}} message", "many": "", "other": "{{n}} messages"}})
+	b.Add(fr)
+	frCA := i18n.NewCatalog("fr_CA")
+	frCA.Add(&i18n.Message{ID: "greeting.hello", Text: "Allô {{name}}"})
+	b.Add(frCA)
+	return b
+}
+
+func TestBundle_Lambda(t *testing.T) {
+	tt := []struct {
+		name   string
+		locale string
+		text   string
+		data   map[string]interface{}
+		want   string
+		err    string
+	}{
+		{name: "Text", locale: "en", text: "{{#t}}greeting.hello{{/t}}", data: map[string]interface{}{"name": "<Ann>"}, want: "Hello &lt;Ann&gt;"},
+		{name: "Region", locale: "fr-CA", text: "{{#t}} greeting.hello {{/t}}", data: map[string]interface{}{"name": "Ann"}, want: "Allô Ann"},
+		{name: "Parent", locale: "fr-BE", text: "{{#t}}greeting.hello{{/t}}", data: map[string]interface{}{"name": "Ann"}, want: "Bonjour Ann"},
+		{name: "Fallback", locale: "fr-CA", text: "{{#t}}greeting.bye{{/t}}", want: "Bye"},
+		{name: "Missing", locale: "fr", text: "{{#t}}greeting.missing{{/t}}", want: "greeting.missing"},
+		{name: "PluralOne", locale: "en", text: "{{#t}}inbox.unread{{/t}}", data: map[string]interface{}{"count": 1}, want: "1 message"},
+		{name: "PluralOther", locale: "en", text: "{{#t}}inbox.unread{{/t}}", data: map[string]interface{}{"count": 2.5}, want: "2.5 messages"},
+		{name: "PluralKey", locale: "fr", text: "{{#t}}inbox.unread n{{/t}}", data: map[string]interface{}{"n": 0}, want: "0 message"},
+		{name: "PluralEmpty", locale: "fr", text: "{{#t}}inbox.unread n{{/t}}", data: map[string]interface{}{"n": 1000000}, want: "1000000 messages"},
+		{name: "Section", locale: "en", text: "{{#users}}{{#t}}greeting.hello{{/t}};{{/users}}", data: map[string]interface{}{"users": []map[string]string{{"name": "a"}, {"name": "b"}}}, want: "Hello a;Hello b;"},
+		{name: "Invalid", locale: "en", text: "{{#t}}a b c{{/t}}", err: "main:1:1: invalid message reference: \"a b c\""},
+		{name: "MissingCount", locale: "en", text: "{{#t}}inbox.unread{{/t}}", err: "main:1:1: lambda:1:1: cannot find value count in context"},
+	}
+
+	b := newBundle()
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl := mustache.NewTemplate()
+			tmpl.ContextErrorsEnabled = true
+			if err := tmpl.Parse("main", tc.text); err != nil {
+				t.Fatalf("failed to parse template: %v", err)
+			}
+			got, err := tmpl.Render("main", b.Context(tc.locale), tc.data)
+			var gotErr string
+			if err != nil {
+				gotErr = err.Error()
+			}
+			if gotErr != tc.err {
+				t.Errorf("unexpected error, got:%s, want:%s", gotErr, tc.err)
+			}
+			if got != tc.want {
+				t.Errorf("unexpected response, got:%q, want:%q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mustache-i18n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"de.json":  `{"greeting": {"hello": "Hallo"}}`,
+		"nl.po":    "msgid \"greeting.hello\"\nmsgstr \"Hallo\"\n",
+		"fr.po":    "msgid \"\"\nmsgstr \"Language: fr_CA\\n\"\n",
+		"fr.yaml":  "",
+		"bad.json": "[]",
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, locale := range map[string]string{"de.json": "de", "nl.po": "nl", "fr.po": "fr_CA"} {
+		c, err := i18n.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("unexpected error reading %s: %v", name, err)
+		}
+		if c.Locale != locale {
+			t.Errorf("unexpected locale of %s, got:%s, want:%s", name, c.Locale, locale)
+		}
+		if locale != "fr_CA" && (c.Message("greeting.hello") == nil || c.Message("greeting.hello").Text != "Hallo") {
+			t.Errorf("unexpected message of %s: %v", name, c.Message("greeting.hello"))
+		}
+	}
+
+	for name, want := range map[string]string{
+		"fr.yaml":      filepath.Join(dir, "fr.yaml") + ": unknown catalog format .yaml",
+		"bad.json":     filepath.Join(dir, "bad.json") + ": top level value is not an object",
+		"missing.json": "open " + filepath.Join(dir, "missing.json") + ": no such file or directory",
+	} {
+		if _, err := i18n.ReadFile(filepath.Join(dir, name)); err == nil || err.Error() != want {
+			t.Errorf("unexpected error reading %s, got:%v, want:%s", name, err, want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package i18n
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/eriklott/mustache/data"
+)
+
+// ParseJSON parses a JSON catalog of a locale. The catalog is an object
+// mapping message IDs to their text:
+//
+//	{
+//		"greeting.hello": "Hello {{name}}",
+//		"inbox.unread": {"one": "{{count}} message", "other": "{{count}} messages"}
+//	}
+//
+// An object whose keys are plural categories, including other, holds the
+// plural forms of a message. Other objects nest messages, their keys joined
+// to the IDs of the messages within by dots. Messages with empty text are
+// untranslated, and skipped. The name is used in error messages.
+func ParseJSON(name, locale string, b []byte) (*Catalog, error) {
+	m, err := data.Decode(name, b, data.JSON)
+	if err != nil {
+		return nil, err
+	}
+	c := NewCatalog(locale)
+	if err := addJSON(c, name, "", m); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// addJSON adds the messages of a JSON object to a catalog, prefixing their
+// IDs with prefix.
+func addJSON(c *Catalog, name, prefix string, obj map[string]interface{}) error {
+	for k, v := range obj {
+		id := prefix + k
+		switch v := v.(type) {
+		case string:
+			if v != "" {
+				c.Add(&Message{ID: id, Text: v})
+			}
+		case map[string]interface{}:
+			if forms, ok := pluralForms(v); ok {
+				if forms != nil {
+					c.Add(&Message{ID: id, Plural: forms})
+				}
+				continue
+			}
+			if err := addJSON(c, name, id+".", v); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%s: message %s is not a string or object", name, id)
+		}
+	}
+	return nil
+}
+
+// pluralForms returns the plural forms held by a JSON object, if its keys
+// are plural categories including other. The forms are nil when they are all
+// empty.
+func pluralForms(obj map[string]interface{}) (map[string]string, bool) {
+	if _, ok := obj[Other]; !ok {
+		return nil, false
+	}
+	forms := make(map[string]string, len(obj))
+	translated := false
+	for k, v := range obj {
+		s, ok := v.(string)
+		if !ok || !isCategory(k) {
+			return nil, false
+		}
+		forms[k] = s
+		translated = translated || s != ""
+	}
+	if !translated {
+		return nil, true
+	}
+	return forms, true
+}
+
+// isCategory reports whether s is the name of a plural category.
+func isCategory(s string) bool {
+	switch s {
+	case Zero, One, Two, Few, Many, Other:
+		return true
+	}
+	return false
+}
+
+// categoryOrder is the CLDR order of the plural categories.
+var categoryOrder = map[string]int{Zero: 0, One: 1, Two: 2, Few: 3, Many: 4, Other: 5}
+
+// WriteJSON writes the catalog as a JSON object, with messages sorted by ID
+// and plural forms in CLDR order.
+func (c *Catalog) WriteJSON(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("{")
+	for i, m := range c.Messages() {
+		if i > 0 {
+			bw.WriteString(",")
+		}
+		bw.WriteString("\n\t")
+		writeJSONString(bw, m.ID)
+		bw.WriteString(": ")
+		if m.Plural == nil {
+			writeJSONString(bw, m.Text)
+			continue
+		}
+		cats := make([]string, 0, len(m.Plural))
+		for cat := range m.Plural {
+			cats = append(cats, cat)
+		}
+		sort.Slice(cats, func(i, j int) bool { return categoryOrder[cats[i]] < categoryOrder[cats[j]] })
+		bw.WriteString("{")
+		for j, cat := range cats {
+			if j > 0 {
+				bw.WriteString(",")
+			}
+			bw.WriteString("\n\t\t")
+			writeJSONString(bw, cat)
+			bw.WriteString(": ")
+			writeJSONString(bw, m.Plural[cat])
+		}
+		bw.WriteString("\n\t}")
+	}
+	if len(c.messages) > 0 {
+		bw.WriteString("\n")
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+// writeJSONString writes s as a JSON string, without escaping HTML.
+func writeJSONString(w *bufio.Writer, s string) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	w.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+}
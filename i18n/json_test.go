@@ -0,0 +1,75 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package i18n_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eriklott/mustache/i18n"
+)
+
+func TestParseJSON(t *testing.T) {
+	src := `{
+		"greeting": {"hello": "Hello <b>{{name}}</b>", "bye": ""},
+		"inbox.unread": {"one": "{{count}} message", "other": "{{count}} messages"},
+		"untranslated": {"one": "", "other": ""},
+		"nested": {"one": {"two": "2"}}
+	}`
+	c, err := i18n.ParseJSON("en.json", "en", []byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []*i18n.Message{
+		{ID: "greeting.hello", Text: "Hello <b>{{name}}</b>"},
+		{ID: "inbox.unread", Plural: map[string]string{"one": "{{count}} message", "other": "{{count}} messages"}},
+		{ID: "nested.one.two", Text: "2"},
+	}
+	if got := c.Messages(); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected messages, got:%v, want:%v", got, want)
+	}
+
+	var b strings.Builder
+	if err := c.WriteJSON(&b); err != nil {
+		t.Fatal(err)
+	}
+	wantJSON := "{\n\t\"greeting.hello\": \"Hello <b>{{name}}</b>\",\n\t\"inbox.unread\": {\n\t\t\"one\": \"{{count}} message\",\n\t\t\"other\": \"{{count}} messages\"\n\t},\n\t\"nested.one.two\": \"2\"\n}\n"
+	if got := b.String(); got != wantJSON {
+		t.Errorf("unexpected JSON, got:%q, want:%q", got, wantJSON)
+	}
+	c, err = i18n.ParseJSON("en.json", "en", []byte(b.String()))
+	if err != nil {
+		t.Fatalf("unexpected error parsing written JSON: %v", err)
+	}
+	if got := c.Messages(); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected messages of written JSON, got:%v, want:%v", got, want)
+	}
+
+	b.Reset()
+	if err := i18n.NewCatalog("en").WriteJSON(&b); err != nil || b.String() != "{}\n" {
+		t.Errorf("unexpected empty catalog: %q, %v", b.String(), err)
+	}
+}
+
+func TestParseJSON_Error(t *testing.T) {
+	tt := []struct {
+		name string
+		src  string
+		err  string
+	}{
+		{name: "Syntax", src: `{"a": }`, err: "en.json:1:7: invalid character '}' looking for beginning of value"},
+		{name: "Number", src: `{"a": {"b": 1}}`, err: "en.json: message a.b is not a string or object"},
+		{name: "PluralNumber", src: `{"a": {"other": 1}}`, err: "en.json: message a.other is not a string or object"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := i18n.ParseJSON("en.json", "en", []byte(tc.src))
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("unexpected error, got:%v, want:%s", err, tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,335 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package i18n
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Plural categories of CLDR, in the order they are listed for a locale.
+const (
+	Zero  = "zero"
+	One   = "one"
+	Two   = "two"
+	Few   = "few"
+	Many  = "many"
+	Other = "other"
+)
+
+// operands are the plural operands of a number, as defined by CLDR: the
+// absolute value n, its integer digits i, the number of visible fraction
+// digits v, the visible fraction digits f, and the fraction digits without
+// trailing zeros t.
+type operands struct {
+	n       float64
+	i       int64
+	v       int
+	f, t    int64
+	integer bool // n has no fraction
+}
+
+// parseOperands returns the operands of a decimal number, such as -1.50.
+func parseOperands(s string) (operands, bool) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+	if intPart == "" || !isDigits(intPart) || !isDigits(fracPart) {
+		return operands{}, false
+	}
+	var o operands
+	var err error
+	if o.n, err = strconv.ParseFloat(s, 64); err != nil {
+		return operands{}, false
+	}
+	if o.i, err = strconv.ParseInt(intPart, 10, 64); err != nil {
+		o.i = int64(o.n)
+	}
+	o.v = len(fracPart)
+	if fracPart != "" {
+		o.f, _ = strconv.ParseInt(fracPart, 10, 64)
+	}
+	if trimmed := strings.TrimRight(fracPart, "0"); trimmed != "" {
+		o.t, _ = strconv.ParseInt(trimmed, 10, 64)
+	}
+	o.integer = o.n == math.Trunc(o.n)
+	return o, true
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// nmod returns n modulo m, and whether n is an integer: CLDR ranges of n
+// only match integers.
+func (o operands) nmod(m int64) (int64, bool) {
+	return o.i % m, o.integer
+}
+
+// in reports whether x is within the range lo..hi.
+func in(x, lo, hi int64) bool {
+	return x >= lo && x <= hi
+}
+
+// pluralRule is the rule of the cardinal plural categories of a language.
+type pluralRule struct {
+	categories []string
+	category   func(o operands) string
+}
+
+// millions is the many category of French, Spanish, Italian, Portuguese and
+// Catalan, for numbers without exponent.
+func millions(o operands) bool {
+	return o.v == 0 && o.i != 0 && o.i%1000000 == 0
+}
+
+var (
+	ruleOther = &pluralRule{[]string{Other}, func(o operands) string {
+		return Other
+	}}
+	// one: i = 1 and v = 0
+	ruleOneInteger = &pluralRule{[]string{One, Other}, func(o operands) string {
+		if o.i == 1 && o.v == 0 {
+			return One
+		}
+		return Other
+	}}
+	// one: n = 1
+	ruleOne = &pluralRule{[]string{One, Other}, func(o operands) string {
+		if o.n == 1 {
+			return One
+		}
+		return Other
+	}}
+	// one: i = 0 or n = 1
+	ruleZeroOne = &pluralRule{[]string{One, Other}, func(o operands) string {
+		if o.i == 0 || o.n == 1 {
+			return One
+		}
+		return Other
+	}}
+	// one: n = 1 or t != 0 and i = 0,1
+	ruleDanish = &pluralRule{[]string{One, Other}, func(o operands) string {
+		if o.n == 1 || o.t != 0 && (o.i == 0 || o.i == 1) {
+			return One
+		}
+		return Other
+	}}
+	// one: n = 1; many: e = 0 and i != 0 and i % 1000000 = 0 and v = 0
+	ruleSpanish = &pluralRule{[]string{One, Many, Other}, func(o operands) string {
+		switch {
+		case o.n == 1:
+			return One
+		case millions(o):
+			return Many
+		}
+		return Other
+	}}
+	// one: i = 1 and v = 0; many: as Spanish
+	ruleItalian = &pluralRule{[]string{One, Many, Other}, func(o operands) string {
+		switch {
+		case o.i == 1 && o.v == 0:
+			return One
+		case millions(o):
+			return Many
+		}
+		return Other
+	}}
+	// one: i = 0,1; many: as Spanish. Portuguese is the same, as i = 0..1.
+	ruleFrench = &pluralRule{[]string{One, Many, Other}, func(o operands) string {
+		switch {
+		case o.i == 0 || o.i == 1:
+			return One
+		case millions(o):
+			return Many
+		}
+		return Other
+	}}
+	// one: v = 0 and i % 10 = 1 and i % 100 != 11
+	// few: v = 0 and i % 10 = 2..4 and i % 100 != 12..14
+	// many: v = 0 and (i % 10 = 0 or i % 10 = 5..9 or i % 100 = 11..14)
+	ruleRussian = &pluralRule{[]string{One, Few, Many, Other}, func(o operands) string {
+		if o.v != 0 {
+			return Other
+		}
+		i10, i100 := o.i%10, o.i%100
+		switch {
+		case i10 == 1 && i100 != 11:
+			return One
+		case in(i10, 2, 4) && !in(i100, 12, 14):
+			return Few
+		}
+		return Many
+	}}
+	// one: i = 1 and v = 0
+	// few: v = 0 and i % 10 = 2..4 and i % 100 != 12..14
+	// many: v = 0 and (i != 1 and i % 10 = 0..1 or i % 10 = 5..9 or i % 100 = 12..14)
+	rulePolish = &pluralRule{[]string{One, Few, Many, Other}, func(o operands) string {
+		if o.v != 0 {
+			return Other
+		}
+		i10, i100 := o.i%10, o.i%100
+		switch {
+		case o.i == 1:
+			return One
+		case in(i10, 2, 4) && !in(i100, 12, 14):
+			return Few
+		}
+		return Many
+	}}
+	// one: i = 1 and v = 0; few: i = 2..4 and v = 0; many: v != 0
+	ruleCzech = &pluralRule{[]string{One, Few, Many, Other}, func(o operands) string {
+		switch {
+		case o.v != 0:
+			return Many
+		case o.i == 1:
+			return One
+		case in(o.i, 2, 4):
+			return Few
+		}
+		return Other
+	}}
+	// one: v = 0 and i % 10 = 1 and i % 100 != 11 or f % 10 = 1 and f % 100 != 11
+	// few: v = 0 and i % 10 = 2..4 and i % 100 != 12..14 or f % 10 = 2..4 and f % 100 != 12..14
+	ruleCroatian = &pluralRule{[]string{One, Few, Other}, func(o operands) string {
+		i10, i100, f10, f100 := o.i%10, o.i%100, o.f%10, o.f%100
+		switch {
+		case o.v == 0 && i10 == 1 && i100 != 11 || f10 == 1 && f100 != 11:
+			return One
+		case o.v == 0 && in(i10, 2, 4) && !in(i100, 12, 14) || in(f10, 2, 4) && !in(f100, 12, 14):
+			return Few
+		}
+		return Other
+	}}
+	// one: i = 1 and v = 0; few: v != 0 or n = 0 or n != 1 and n % 100 = 1..19
+	ruleRomanian = &pluralRule{[]string{One, Few, Other}, func(o operands) string {
+		n100, integer := o.nmod(100)
+		switch {
+		case o.i == 1 && o.v == 0:
+			return One
+		case o.v != 0 || o.n == 0 || integer && in(n100, 1, 19):
+			return Few
+		}
+		return Other
+	}}
+	// one: i = 1 and v = 0 or i = 0 and v != 0; two: i = 2 and v = 0
+	ruleHebrew = &pluralRule{[]string{One, Two, Other}, func(o operands) string {
+		switch {
+		case o.i == 1 && o.v == 0 || o.i == 0 && o.v != 0:
+			return One
+		case o.i == 2 && o.v == 0:
+			return Two
+		}
+		return Other
+	}}
+	// zero: n = 0; one: n = 1; two: n = 2; few: n % 100 = 3..10;
+	// many: n % 100 = 11..99
+	ruleArabic = &pluralRule{[]string{Zero, One, Two, Few, Many, Other}, func(o operands) string {
+		n100, integer := o.nmod(100)
+		switch {
+		case o.n == 0:
+			return Zero
+		case o.n == 1:
+			return One
+		case o.n == 2:
+			return Two
+		case integer && in(n100, 3, 10):
+			return Few
+		case integer && in(n100, 11, 99):
+			return Many
+		}
+		return Other
+	}}
+)
+
+// pluralRules maps locales and languages to their plural rules.
+var pluralRules = map[string]*pluralRule{
+	"ja": ruleOther, "zh": ruleOther, "ko": ruleOther, "th": ruleOther,
+	"vi": ruleOther, "id": ruleOther, "ms": ruleOther, "lo": ruleOther,
+	"my": ruleOther, "km": ruleOther,
+
+	"en": ruleOneInteger, "de": ruleOneInteger, "nl": ruleOneInteger,
+	"sv": ruleOneInteger, "fi": ruleOneInteger, "et": ruleOneInteger,
+	"gl": ruleOneInteger, "sw": ruleOneInteger, "ur": ruleOneInteger,
+
+	"bg": ruleOne, "el": ruleOne, "hu": ruleOne, "nb": ruleOne,
+	"nn": ruleOne, "no": ruleOne, "tr": ruleOne, "sq": ruleOne,
+	"ka": ruleOne, "kk": ruleOne, "az": ruleOne, "ta": ruleOne,
+	"te": ruleOne, "ml": ruleOne, "mr": ruleOne, "ne": ruleOne,
+
+	"hi": ruleZeroOne, "bn": ruleZeroOne, "fa": ruleZeroOne, "gu": ruleZeroOne,
+	"kn": ruleZeroOne, "zu": ruleZeroOne, "am": ruleZeroOne,
+
+	"da":    ruleDanish,
+	"es":    ruleSpanish,
+	"it":    ruleItalian,
+	"ca":    ruleItalian,
+	"pt-pt": ruleItalian,
+	"fr":    ruleFrench,
+	"pt":    ruleFrench,
+	"ru":    ruleRussian,
+	"uk":    ruleRussian,
+	"pl":    rulePolish,
+	"cs":    ruleCzech,
+	"sk":    ruleCzech,
+	"hr":    ruleCroatian,
+	"sr":    ruleCroatian,
+	"bs":    ruleCroatian,
+	"ro":    ruleRomanian,
+	"he":    ruleHebrew,
+	"ar":    ruleArabic,
+}
+
+// rule returns the plural rule of a locale. Locales of languages without a
+// rule use the rule of English.
+func rule(locale string) *pluralRule {
+	for _, l := range fallbacks(locale) {
+		if r, ok := pluralRules[l]; ok {
+			return r
+		}
+	}
+	return ruleOneInteger
+}
+
+// Categories returns the CLDR plural categories used by a locale, in CLDR
+// order. Messages in gettext .po files give their plural forms in this order.
+func Categories(locale string) []string {
+	return append([]string(nil), rule(locale).categories...)
+}
+
+// PluralCategory returns the CLDR plural category of a decimal number in a
+// locale. Visible fraction digits are significant: in English, "1" is one
+// and "1.0" is other. Text that is not a decimal number is other.
+func PluralCategory(locale, number string) string {
+	o, ok := parseOperands(strings.TrimSpace(number))
+	if !ok {
+		return Other
+	}
+	return rule(locale).category(o)
+}
+
+// fallbacks returns the normalized forms of a locale tried when looking up
+// its data, from the most to the least specific: fr_CA gives fr-ca and fr.
+func fallbacks(locale string) []string {
+	l := strings.ToLower(strings.Replace(locale, "_", "-", -1))
+	var ls []string
+	for l != "" {
+		ls = append(ls, l)
+		i := strings.LastIndexByte(l, '-')
+		if i < 0 {
+			break
+		}
+		l = l[:i]
+	}
+	return ls
+}
@@ -0,0 +1,71 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package i18n_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eriklott/mustache/i18n"
+)
+
+func TestPluralCategory(t *testing.T) {
+	tt := []struct {
+		locale string
+		nums   string
+		want   string
+	}{
+		{"en", "1 0 2 1.0 -1 x", "one other other other one other"},
+		{"en-US", "1 2", "one other"},
+		{"xx", "1 2", "one other"},
+		{"ja", "1 2", "other other"},
+		{"fr", "0 1 1.5 2 1000 1000000 1000000.0", "one one one other other many other"},
+		{"fr_CA", "1 2000000", "one many"},
+		{"pt", "0 1 1000000", "one one many"},
+		{"pt-PT", "0 1 1000000", "other one many"},
+		{"es", "1 0 1.0 1000000", "one other one many"},
+		{"da", "1 0.1 1.5 2", "one one one other"},
+		{"tr", "1 1.0 2", "one one other"},
+		{"hi", "0 0.5 1 2", "one one one other"},
+		{"ru", "1 2 5 11 22 111 1.5", "one few many many few many other"},
+		{"pl", "1 2 5 11 22 12 21 1.5", "one few many many few many many other"},
+		{"cs", "1 3 5 1.5", "one few other many"},
+		{"hr", "1 2 11 21 0.1 5", "one few other one one other"},
+		{"ro", "1 0 19 20 1.5", "one few few other few"},
+		{"he", "1 0.5 2 3", "one one two other"},
+		{"ar", "0 1 2 3 11 100 1.5", "zero one two few many other other"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.locale, func(t *testing.T) {
+			nums, want := strings.Fields(tc.nums), strings.Fields(tc.want)
+			if len(nums) != len(want) {
+				t.Fatalf("test has %d numbers and %d categories", len(nums), len(want))
+			}
+			for i, n := range nums {
+				if got := i18n.PluralCategory(tc.locale, n); got != want[i] {
+					t.Errorf("unexpected category of %s, got:%s, want:%s", n, got, want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCategories(t *testing.T) {
+	tt := []struct {
+		locale string
+		want   []string
+	}{
+		{"en", []string{"one", "other"}},
+		{"ja", []string{"other"}},
+		{"ru-RU", []string{"one", "few", "many", "other"}},
+		{"ar", []string{"zero", "one", "two", "few", "many", "other"}},
+	}
+	for _, tc := range tt {
+		if got := i18n.Categories(tc.locale); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("unexpected categories of %s, got:%v, want:%v", tc.locale, got, tc.want)
+		}
+	}
+}
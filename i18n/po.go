@@ -0,0 +1,258 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package i18n
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// poEntry is an entry of a .po file being parsed.
+type poEntry struct {
+	line     int // line of the msgid
+	refs     []string
+	fuzzy    bool
+	id       *string
+	idPlural *string
+	str      *string
+	strs     []*string // plural forms, by index
+}
+
+// poParser parses a .po file.
+type poParser struct {
+	name    string
+	catalog *Catalog
+	entry   poEntry
+	field   *string // the string continued by string lines
+}
+
+// ParsePO parses a gettext .po catalog. The locale of the catalog is given by
+// the Language header, and is empty when the header is missing. The plural
+// forms of a message, msgstr[0], msgstr[1] and so on, are the plural
+// categories of the locale in CLDR order, as returned by Categories; the
+// Plural-Forms header is ignored. Fuzzy and untranslated messages are skipped,
+// and message contexts are not supported. The name is used in error messages.
+func ParsePO(name string, b []byte) (*Catalog, error) {
+	p := &poParser{name: name, catalog: NewCatalog("")}
+	for i, line := range strings.Split(string(b), "\n") {
+		if err := p.line(i+1, strings.TrimSpace(line)); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.flush(); err != nil {
+		return nil, err
+	}
+	return p.catalog, nil
+}
+
+func (p *poParser) errorf(ln int, format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", p.name, ln, fmt.Sprintf(format, args...))
+}
+
+// line parses a line of the file.
+func (p *poParser) line(ln int, line string) error {
+	switch {
+	case line == "":
+		p.field = nil
+		return p.flush()
+
+	case strings.HasPrefix(line, "#"):
+		p.field = nil
+		if p.entry.str != nil || p.entry.strs != nil {
+			if err := p.flush(); err != nil {
+				return err
+			}
+		}
+		switch {
+		case strings.HasPrefix(line, "#:"):
+			p.entry.refs = append(p.entry.refs, strings.Fields(line[2:])...)
+		case strings.HasPrefix(line, "#,"):
+			for _, flag := range strings.Split(line[2:], ",") {
+				if strings.TrimSpace(flag) == "fuzzy" {
+					p.entry.fuzzy = true
+				}
+			}
+		}
+		return nil
+
+	case strings.HasPrefix(line, `"`):
+		if p.field == nil {
+			return p.errorf(ln, "unexpected string")
+		}
+		s, err := p.unquote(ln, line)
+		if err != nil {
+			return err
+		}
+		*p.field += s
+		return nil
+	}
+
+	keyword, rest := line, ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		keyword, rest = line[:i], strings.TrimSpace(line[i+1:])
+	}
+	s, err := p.unquote(ln, rest)
+	if err != nil {
+		return err
+	}
+	e := &p.entry
+	switch {
+	case keyword == "msgid":
+		if e.id != nil {
+			if err := p.flush(); err != nil {
+				return err
+			}
+		}
+		e.line, e.id = ln, &s
+	case keyword == "msgid_plural":
+		if e.id == nil {
+			return p.errorf(ln, "msgid_plural without msgid")
+		}
+		e.idPlural = &s
+	case keyword == "msgstr":
+		if e.id == nil {
+			return p.errorf(ln, "msgstr without msgid")
+		}
+		e.str = &s
+	case strings.HasPrefix(keyword, "msgstr[") && strings.HasSuffix(keyword, "]"):
+		n, err := strconv.Atoi(keyword[len("msgstr[") : len(keyword)-1])
+		if err != nil || n != len(e.strs) {
+			return p.errorf(ln, "unexpected %s", keyword)
+		}
+		if e.idPlural == nil {
+			return p.errorf(ln, "%s without msgid_plural", keyword)
+		}
+		e.strs = append(e.strs, &s)
+	case keyword == "msgctxt":
+		return p.errorf(ln, "msgctxt is not supported")
+	default:
+		return p.errorf(ln, "unknown keyword %s", keyword)
+	}
+	p.field = &s
+	return nil
+}
+
+// unquote decodes a quoted string of the file.
+func (p *poParser) unquote(ln int, s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", p.errorf(ln, "expected quoted string")
+	}
+	u, err := strconv.Unquote(s)
+	if err != nil {
+		return "", p.errorf(ln, "invalid string %s", s)
+	}
+	return u, nil
+}
+
+// flush adds the entry being parsed to the catalog.
+func (p *poParser) flush() error {
+	e := p.entry
+	p.entry = poEntry{}
+	if e.id == nil {
+		return nil
+	}
+	if e.str == nil && e.strs == nil {
+		return p.errorf(e.line, "missing msgstr")
+	}
+	if *e.id == "" {
+		if e.str != nil {
+			p.header(*e.str)
+		}
+		return nil
+	}
+	if e.fuzzy {
+		return nil
+	}
+	m := &Message{ID: *e.id, Refs: e.refs}
+	if e.idPlural == nil {
+		if e.str == nil {
+			return p.errorf(e.line, "missing msgstr")
+		}
+		if *e.str == "" {
+			return nil
+		}
+		m.Text = *e.str
+		p.catalog.Add(m)
+		return nil
+	}
+	if e.strs == nil {
+		return p.errorf(e.line, "missing msgstr[0]")
+	}
+	cats := Categories(p.catalog.Locale)
+	if len(e.strs) > len(cats) {
+		return p.errorf(e.line, "msgstr[%d] has no plural category in locale %q", len(cats), p.catalog.Locale)
+	}
+	m.Plural = make(map[string]string, len(e.strs))
+	translated := false
+	for i, s := range e.strs {
+		m.Plural[cats[i]] = *s
+		translated = translated || *s != ""
+	}
+	if translated {
+		p.catalog.Add(m)
+	}
+	return nil
+}
+
+// header reads the locale from the header entry.
+func (p *poParser) header(s string) {
+	for _, line := range strings.Split(s, "\n") {
+		if i := strings.IndexByte(line, ':'); i >= 0 && strings.TrimSpace(line[:i]) == "Language" {
+			p.catalog.Locale = strings.TrimSpace(line[i+1:])
+		}
+	}
+}
+
+// WritePO writes the catalog as a gettext .po file, with messages sorted by
+// ID and the references of each message as comments. Plural messages repeat
+// their ID as msgid_plural, and have a form for each plural category of the
+// locale, in the order returned by Categories.
+func (c *Catalog) WritePO(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("msgid \"\"\nmsgstr \"\"\n")
+	if c.Locale != "" {
+		fmt.Fprintf(bw, "%s\n", strconv.Quote("Language: "+c.Locale+"\n"))
+	}
+	bw.WriteString("\"MIME-Version: 1.0\\n\"\n")
+	bw.WriteString("\"Content-Type: text/plain; charset=UTF-8\\n\"\n")
+	bw.WriteString("\"Content-Transfer-Encoding: 8bit\\n\"\n")
+	cats := Categories(c.Locale)
+	for _, m := range c.Messages() {
+		bw.WriteString("\n")
+		for _, ref := range m.Refs {
+			fmt.Fprintf(bw, "#: %s\n", ref)
+		}
+		writePOString(bw, "msgid", m.ID)
+		if m.Plural == nil {
+			writePOString(bw, "msgstr", m.Text)
+			continue
+		}
+		writePOString(bw, "msgid_plural", m.ID)
+		for i, cat := range cats {
+			writePOString(bw, fmt.Sprintf("msgstr[%d]", i), m.Plural[cat])
+		}
+	}
+	return bw.Flush()
+}
+
+// writePOString writes a keyword and its string. Strings of several lines
+// are written a line at a time.
+func writePOString(w *bufio.Writer, keyword, s string) {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) <= 1 {
+		fmt.Fprintf(w, "%s %s\n", keyword, strconv.Quote(s))
+		return
+	}
+	fmt.Fprintf(w, "%s \"\"\n", keyword)
+	for _, line := range lines {
+		fmt.Fprintf(w, "%s\n", strconv.Quote(line))
+	}
+}
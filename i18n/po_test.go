@@ -0,0 +1,133 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package i18n_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eriklott/mustache/i18n"
+)
+
+func TestParsePO(t *testing.T) {
+	src := `# translator comment
+msgid ""
+msgstr ""
+"Project-Id-Version: app\n"
+"Language: ru\n"
+"Plural-Forms: nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : 1);\n"
+
+#: main.mustache:1
+#: main.mustache:4
+msgid "greeting.hello"
+msgstr "Привет, {{name}}"
+
+#. extracted comment
+msgid "greeting.multi"
+msgstr ""
+"line one\n"
+"line \"two\""
+#, fuzzy
+msgid "greeting.fuzzy"
+msgstr "Ещё"
+
+msgid "greeting.untranslated"
+msgstr ""
+
+msgid "inbox.unread"
+msgid_plural "inbox.unread"
+msgstr[0] "{{count}} сообщение"
+msgstr[1] "{{count}} сообщения"
+msgstr[2] "{{count}} сообщений"
+msgstr[3] "{{count}} сообщения"
+
+#~ msgid "obsolete"
+#~ msgstr "old"
+`
+	c, err := i18n.ParsePO("ru.po", []byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Locale != "ru" {
+		t.Errorf("unexpected locale, got:%s, want:ru", c.Locale)
+	}
+	want := []*i18n.Message{
+		{ID: "greeting.hello", Text: "Привет, {{name}}", Refs: []string{"main.mustache:1", "main.mustache:4"}},
+		{ID: "greeting.multi", Text: "line one\nline \"two\""},
+		{ID: "inbox.unread", Plural: map[string]string{"one": "{{count}} сообщение", "few": "{{count}} сообщения", "many": "{{count}} сообщений", "other": "{{count}} сообщения"}},
+	}
+	if got := c.Messages(); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected messages, got:%v, want:%v", got, want)
+	}
+
+	var b strings.Builder
+	if err := c.WritePO(&b); err != nil {
+		t.Fatal(err)
+	}
+	wantPO := `msgid ""
+msgstr ""
+"Language: ru\n"
+"MIME-Version: 1.0\n"
+"Content-Type: text/plain; charset=UTF-8\n"
+"Content-Transfer-Encoding: 8bit\n"
+
+#: main.mustache:1
+#: main.mustache:4
+msgid "greeting.hello"
+msgstr "Привет, {{name}}"
+
+msgid "greeting.multi"
+msgstr ""
+"line one\n"
+"line \"two\""
+
+msgid "inbox.unread"
+msgid_plural "inbox.unread"
+msgstr[0] "{{count}} сообщение"
+msgstr[1] "{{count}} сообщения"
+msgstr[2] "{{count}} сообщений"
+msgstr[3] "{{count}} сообщения"
+`
+	if got := b.String(); got != wantPO {
+		t.Errorf("unexpected .po file, got:%q, want:%q", got, wantPO)
+	}
+	c, err = i18n.ParsePO("ru.po", []byte(b.String()))
+	if err != nil {
+		t.Fatalf("unexpected error parsing written .po file: %v", err)
+	}
+	if got := c.Messages(); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected messages of written .po file, got:%v, want:%v", got, want)
+	}
+}
+
+func TestParsePO_Error(t *testing.T) {
+	tt := []struct {
+		name string
+		src  string
+		err  string
+	}{
+		{name: "Keyword", src: "msgid \"a\"\nmsgtxt \"b\"", err: "ru.po:2: unknown keyword msgtxt"},
+		{name: "Context", src: "msgctxt \"menu\"\nmsgid \"a\"", err: "ru.po:1: msgctxt is not supported"},
+		{name: "Unquoted", src: "msgid a", err: "ru.po:1: expected quoted string"},
+		{name: "Escape", src: "msgid \"\\q\"", err: "ru.po:1: invalid string \"\\q\""},
+		{name: "String", src: "\"a\"", err: "ru.po:1: unexpected string"},
+		{name: "MissingStr", src: "msgid \"a\"\n\nmsgid \"b\"", err: "ru.po:1: missing msgstr"},
+		{name: "MissingForms", src: "msgid \"a\"\nmsgid_plural \"a\"\nmsgstr \"b\"", err: "ru.po:1: missing msgstr[0]"},
+		{name: "StrWithoutID", src: "msgstr \"a\"", err: "ru.po:1: msgstr without msgid"},
+		{name: "PluralWithoutID", src: "msgid_plural \"a\"", err: "ru.po:1: msgid_plural without msgid"},
+		{name: "FormWithoutPlural", src: "msgid \"a\"\nmsgstr[0] \"b\"", err: "ru.po:2: msgstr[0] without msgid_plural"},
+		{name: "FormOrder", src: "msgid \"a\"\nmsgid_plural \"a\"\nmsgstr[1] \"b\"", err: "ru.po:3: unexpected msgstr[1]"},
+		{name: "FormCategory", src: "msgid \"a\"\nmsgid_plural \"a\"\nmsgstr[0] \"b\"\nmsgstr[1] \"c\"\nmsgstr[2] \"d\"", err: "ru.po:1: msgstr[2] has no plural category in locale \"\""},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := i18n.ParsePO("ru.po", []byte(tc.src))
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("unexpected error, got:%v, want:%s", err, tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package diff computes line based differences between two texts.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// context is the number of unchanged lines printed around each change.
+const context = 3
+
+// op is a single line of an edit script.
+type op struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// Unified returns a unified diff of the texts a and b, labelled with the
+// names oldName and newName. An empty string is returned when a and b are
+// equal.
+func Unified(oldName, newName, a, b string) string {
+	if a == b {
+		return ""
+	}
+	ops := edits(splitLines(a), splitLines(b))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+
+	for i := 0; i < len(ops); {
+		// find the next change
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// extend the hunk until the changes are separated by more than
+		// twice the context.
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j + 1
+				continue
+			}
+			if j-end >= 2*context {
+				break
+			}
+		}
+		stop := end + context
+		if stop > len(ops) {
+			stop = len(ops)
+		}
+
+		writeHunk(&out, ops, start, stop)
+		i = stop
+	}
+	return out.String()
+}
+
+// writeHunk writes the hunk of ops[start:stop].
+func writeHunk(out *strings.Builder, ops []op, start, stop int) {
+	oldLn, newLn := 1, 1
+	for _, o := range ops[:start] {
+		if o.kind != '+' {
+			oldLn++
+		}
+		if o.kind != '-' {
+			newLn++
+		}
+	}
+	oldCount, newCount := 0, 0
+	for _, o := range ops[start:stop] {
+		if o.kind != '+' {
+			oldCount++
+		}
+		if o.kind != '-' {
+			newCount++
+		}
+	}
+	if oldCount == 0 {
+		oldLn--
+	}
+	if newCount == 0 {
+		newLn--
+	}
+
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", oldLn, oldCount, newLn, newCount)
+	for _, o := range ops[start:stop] {
+		out.WriteByte(o.kind)
+		out.WriteString(o.line)
+		if !strings.HasSuffix(o.line, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// edits returns the shortest edit script transforming a into b, computed from
+// the longest common subsequence of lines.
+func edits(a, b []string) []op {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ops []op
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, op{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, op{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, op{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, op{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, op{'+', b[j]})
+	}
+	return ops
+}
+
+// splitLines splits s after each newline.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,60 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package diff_test
+
+import (
+	"testing"
+
+	"github.com/eriklott/mustache/internal/diff"
+)
+
+func TestUnified(t *testing.T) {
+	tt := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{
+			name: "Equal",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: "",
+		},
+		{
+			name: "Change",
+			a:    "a\nb\nc\n",
+			b:    "a\nB\nc\n",
+			want: "--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name: "Insert",
+			a:    "",
+			b:    "a\n",
+			want: "--- old\n+++ new\n@@ -0,0 +1,1 @@\n+a\n",
+		},
+		{
+			name: "NoNewline",
+			a:    "a",
+			b:    "b",
+			want: "--- old\n+++ new\n@@ -1,1 +1,1 @@\n-a\n\\ No newline at end of file\n+b\n\\ No newline at end of file\n",
+		},
+		{
+			name: "Hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			b:    "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n",
+			want: "--- old\n+++ new\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+twelve\n",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got := diff.Unified("old", "new", tc.a, tc.b)
+			if got != tc.want {
+				t.Errorf("unexpected diff, got:%q, want:%q", got, tc.want)
+			}
+		})
+	}
+}
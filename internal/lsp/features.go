@@ -0,0 +1,392 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/eriklott/mustache"
+	"github.com/eriklott/mustache/ast"
+	"github.com/eriklott/mustache/parse"
+	"github.com/eriklott/mustache/printer"
+)
+
+// formatIndent is the indentation per section depth of standalone tags, as
+// used by mustache fmt.
+const formatIndent = "  "
+
+// diagnostics returns the parse error of a document, or its partial tags
+// naming templates missing from the workspace.
+func (s *Server) diagnostics(doc *document) []diagnostic {
+	diags := []diagnostic{}
+	if doc.err != nil {
+		ln, col := 1, 1
+		switch e := doc.err.(type) {
+		case *parse.Error:
+			ln, col = e.Line, e.Column
+		case *parse.LimitError:
+			ln, col = e.Line, e.Column
+		}
+		start := bytePosition(doc.text, ln, col)
+		end := bytePosition(doc.text, ln, len(lineText(doc.text, ln-1))+1)
+		diags = append(diags, diagnostic{
+			Range:    lspRange{Start: start, End: end},
+			Severity: severityError,
+			Source:   "mustache",
+			Message:  errorMessage(doc.err),
+		})
+		return diags
+	}
+	ast.Inspect(doc.tree, func(node ast.Node) bool {
+		if n, ok := node.(*ast.Partial); ok && s.lookup(n.Resolve(doc.name)) == nil {
+			diags = append(diags, diagnostic{
+				Range:    rangeOf(n.Range),
+				Severity: severityWarning,
+				Source:   "mustache",
+				Message:  "partial not found: " + n.Key,
+			})
+		}
+		return true
+	})
+	return diags
+}
+
+// errorMessage returns the message of an error without its position.
+func errorMessage(err error) string {
+	if e, ok := err.(*parse.Error); ok {
+		return e.Msg
+	}
+	return err.Error()
+}
+
+// definition returns the location of the template included by the partial
+// tag at a position.
+func (s *Server) definition(p textDocumentPositionParams) []location {
+	n, ok := s.nodeAt(p).(*ast.Partial)
+	if !ok {
+		return nil
+	}
+	target := s.lookup(n.Resolve(s.docs[p.TextDocument.URI].name))
+	if target == nil {
+		return nil
+	}
+	return []location{{URI: target.uri}}
+}
+
+// references returns the partial tags including a template: the template of
+// the partial tag at a position, or else the template of the document.
+func (s *Server) references(p textDocumentPositionParams) []location {
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+	name := doc.name
+	if n, ok := s.nodeAt(p).(*ast.Partial); ok {
+		name = n.Resolve(doc.name)
+	}
+	locs := []location{}
+	for _, uri := range s.uris() {
+		doc := s.docs[uri]
+		if doc.tree == nil {
+			continue
+		}
+		ast.Inspect(doc.tree, func(node ast.Node) bool {
+			if n, ok := node.(*ast.Partial); ok && n.Resolve(doc.name) == name {
+				locs = append(locs, location{URI: uri, Range: rangeOf(n.Range)})
+			}
+			return true
+		})
+	}
+	return locs
+}
+
+// completion completes the partial name of a partial tag, or the key of
+// another tag, at a position. Keys are those referenced by the templates of
+// the workspace, along with the parameters documented by the document.
+func (s *Server) completion(p textDocumentPositionParams) []completionItem {
+	items := []completionItem{}
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return items
+	}
+	line := lineText(doc.text, p.Position.Line)
+	prefix := line[:byteOffset(line, p.Position.Character)]
+	i := strings.LastIndex(prefix, parse.DefaultLeftDelim)
+	if i < 0 || strings.Contains(prefix[i:], parse.DefaultRightDelim) {
+		return items
+	}
+	body := prefix[i+len(parse.DefaultLeftDelim):]
+	switch {
+	case strings.HasPrefix(body, "!"), strings.HasPrefix(body, "="):
+		return items
+
+	case strings.HasPrefix(body, ">"):
+		seen := make(map[string]bool)
+		for _, uri := range s.uris() {
+			name := s.docs[uri].name
+			if !seen[name] {
+				seen[name] = true
+				items = append(items, completionItem{Label: name, Kind: completionFile})
+			}
+		}
+
+	default:
+		keys := make(map[string]string)
+		for _, uri := range s.uris() {
+			if tree := s.docs[uri].tree; tree != nil {
+				ast.Inspect(tree, func(node ast.Node) bool {
+					switch n := node.(type) {
+					case *ast.Variable:
+						keys[strings.Join(n.Key, ".")] = ""
+						for _, fb := range n.Fallbacks {
+							if fb.Key != nil {
+								keys[strings.Join(fb.Key, ".")] = ""
+							}
+						}
+					case *ast.Section:
+						keys[strings.Join(n.Key, ".")] = ""
+					}
+					return true
+				})
+			}
+		}
+		if d := s.doc(doc); d != nil {
+			for _, param := range d.Params {
+				keys[param.Name] = param.Type
+			}
+		}
+		delete(keys, ".")
+		for key, typ := range keys {
+			items = append(items, completionItem{Label: key, Kind: completionVariable, Detail: typ})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Label < items[j].Label })
+	return items
+}
+
+// hover describes the tag at a position. Partial tags are described by the
+// doc comments of the included template, and keys by the parameters
+// documented by the document.
+func (s *Server) hover(p textDocumentPositionParams) *hover {
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+	var b strings.Builder
+	var r ast.Range
+	switch n := s.nodeAt(p).(type) {
+	case *ast.Partial:
+		r = n.Range
+		b.WriteString("partial `" + n.Key + "`")
+		target := s.lookup(n.Resolve(doc.name))
+		if target == nil {
+			b.WriteString(": not found")
+			break
+		}
+		if d := s.doc(target); d != nil {
+			if d.Description != "" {
+				b.WriteString("\n\n" + d.Description)
+			}
+			for _, param := range d.Params {
+				b.WriteString("\n\n" + describeParam(param))
+			}
+		}
+
+	case *ast.Variable:
+		r = n.Range
+		s.describeKey(&b, doc, n.Key)
+
+	case *ast.Section:
+		r = n.Range
+		if pos := fromLSP(p.Position); within(n.Close.Range, pos) {
+			r = n.Close.Range
+		}
+		s.describeKey(&b, doc, n.Key)
+
+	default:
+		return nil
+	}
+	rng := rangeOf(r)
+	return &hover{
+		Contents: markupContent{Kind: "markdown", Value: b.String()},
+		Range:    &rng,
+	}
+}
+
+// describeKey writes the description of a key, and of the parameter
+// documenting it.
+func (s *Server) describeKey(b *strings.Builder, doc *document, key []string) {
+	b.WriteString("`" + strings.Join(key, ".") + "`")
+	d := s.doc(doc)
+	if d == nil {
+		return
+	}
+	for _, param := range d.Params {
+		if param.Name == key[0] || param.Name == strings.Join(key, ".") {
+			b.WriteString("\n\n" + describeParam(param))
+		}
+	}
+}
+
+func describeParam(p mustache.Param) string {
+	s := "@param `" + p.Name + "`"
+	if p.Type != "" {
+		s += " *" + p.Type + "*"
+	}
+	if p.Description != "" {
+		s += " — " + p.Description
+	}
+	return s
+}
+
+// doc returns the documentation of a document, or nil if the document does not
+// parse or its doc comments are invalid.
+func (s *Server) doc(doc *document) *mustache.Doc {
+	if doc.tree == nil {
+		return nil
+	}
+	tmpl := mustache.NewTemplate()
+	if err := tmpl.AddTree(doc.name, doc.tree); err != nil {
+		return nil
+	}
+	d, err := tmpl.Doc(doc.name)
+	if err != nil {
+		return nil
+	}
+	return d
+}
+
+// formatting returns the edit replacing a document with its canonical
+// formatting, as printed by mustache fmt.
+func (s *Server) formatting(p formattingParams) ([]textEdit, error) {
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok || doc.tree == nil {
+		return nil, nil
+	}
+	var b bytes.Buffer
+	cfg := printer.Config{Mode: printer.Canonical, Indent: formatIndent, TrimMarkers: s.opts.TrimMarkers}
+	if err := cfg.Fprint(&b, doc.tree); err != nil {
+		return nil, err
+	}
+	if b.String() == doc.text {
+		return []textEdit{}, nil
+	}
+	return []textEdit{{
+		Range:   lspRange{End: endPosition(doc.text)},
+		NewText: b.String(),
+	}}, nil
+}
+
+// nodeAt returns the innermost tag at a position, or nil if the position is
+// not within a tag. A section is returned for positions within its opening or
+// closing tag.
+func (s *Server) nodeAt(p textDocumentPositionParams) ast.Node {
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok || doc.tree == nil {
+		return nil
+	}
+	pos := fromLSP(p.Position)
+	var found ast.Node
+	ast.Inspect(doc.tree, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.Tree:
+			return true
+		case *ast.Section:
+			if within(n.Range, pos) || within(n.Close.Range, pos) {
+				found = n
+				return false
+			}
+			return within(ast.NodeRange(n), pos)
+		case *ast.Variable, *ast.Partial, *ast.Comment, *ast.SetDelims:
+			if within(ast.NodeRange(n), pos) {
+				found = n
+			}
+		}
+		return false
+	})
+	return found
+}
+
+// within reports whether a position, given as a line and UTF-16 column, is
+// within a range, including its end.
+func within(r ast.Range, pos ast.Position) bool {
+	before := func(a, b ast.Position) bool {
+		return a.Line < b.Line || a.Line == b.Line && a.UTF16 <= b.UTF16
+	}
+	return before(r.Start, pos) && before(pos, r.End)
+}
+
+// fromLSP returns the line and UTF-16 column of a protocol position.
+func fromLSP(p position) ast.Position {
+	return ast.Position{Line: p.Line + 1, UTF16: p.Character + 1}
+}
+
+// rangeOf returns the protocol range of a source range.
+func rangeOf(r ast.Range) lspRange {
+	return lspRange{
+		Start: position{Line: r.Start.Line - 1, Character: r.Start.UTF16 - 1},
+		End:   position{Line: r.End.Line - 1, Character: r.End.UTF16 - 1},
+	}
+}
+
+// lineText returns the zero based line of a text, without its line ending.
+func lineText(text string, line int) string {
+	for ; line > 0; line-- {
+		i := strings.IndexByte(text, '\n')
+		if i < 0 {
+			return ""
+		}
+		text = text[i+1:]
+	}
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i]
+	}
+	return strings.TrimSuffix(text, "\r")
+}
+
+// bytePosition returns the protocol position of a line and byte column.
+func bytePosition(text string, ln, col int) position {
+	line := lineText(text, ln-1)
+	if col-1 < len(line) {
+		line = line[:col-1]
+	}
+	return position{Line: ln - 1, Character: utf16Len(line)}
+}
+
+// endPosition returns the protocol position of the end of a text.
+func endPosition(text string) position {
+	line := strings.Count(text, "\n")
+	last := text[strings.LastIndexByte(text, '\n')+1:]
+	return position{Line: line, Character: utf16Len(last)}
+}
+
+// utf16Len returns the number of UTF-16 code units encoding s.
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n++
+		if r >= 0x10000 {
+			n++
+		}
+	}
+	return n
+}
+
+// byteOffset returns the byte offset of a UTF-16 column within a line.
+func byteOffset(line string, character int) int {
+	n := 0
+	for i, r := range line {
+		if n >= character {
+			return i
+		}
+		n++
+		if r >= 0x10000 {
+			n++
+		}
+	}
+	return len(line)
+}
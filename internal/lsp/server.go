@@ -0,0 +1,359 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package lsp implements a Language Server Protocol server for mustache
+// templates.
+//
+// The server knows the templates of its workspace: each .mustache file found
+// under the root folder of the client, named as a partial by its path
+// relative to the root folder without extension, along with the documents
+// open in the client. It publishes parse errors and missing partials as
+// diagnostics, and provides go to definition and references for partials,
+// completion of partial names and data keys, hover information from doc
+// comments, and document formatting.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/eriklott/mustache/ast"
+	"github.com/eriklott/mustache/parse"
+)
+
+// templateExt is the file extension of the templates of a workspace.
+const templateExt = ".mustache"
+
+// document is a template of the workspace.
+type document struct {
+	uri  string
+	name string // template name, used to include the template as a partial
+	text string
+	tree *ast.Tree // nil if the text does not parse
+	err  error     // the parse error
+	open bool      // the document is open in the client
+}
+
+// Server is a language server for mustache templates.
+type Server struct {
+	w    io.Writer
+	log  *log.Logger
+	opts parse.Options // options the templates are parsed with
+	root string        // root folder of the workspace, if any
+	docs map[string]*document
+	exit bool
+}
+
+// Serve runs a language server reading requests from r and writing responses
+// to w, until the client sends the exit notification or r is closed. Errors
+// that are not reported to the client are logged to logw. Templates are
+// parsed with opts, unless the client overrides them in the
+// initializationOptions of its initialize request, as in
+// {"trimMarkers": true, "handlebars": true}.
+func Serve(r io.Reader, w io.Writer, logw io.Writer, opts parse.Options) error {
+	s := &Server{
+		w:    w,
+		log:  log.New(logw, "mustache lsp: ", 0),
+		opts: opts,
+		docs: make(map[string]*document),
+	}
+	br := bufio.NewReader(r)
+	for !s.exit {
+		msg, err := readMessage(br)
+		if err == io.EOF {
+			return nil
+		}
+		if rerr, ok := err.(*responseError); ok {
+			s.write(&message{ID: new(json.RawMessage), Error: rerr})
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		s.handle(msg)
+	}
+	return nil
+}
+
+// handle dispatches a message to its handler, and replies to requests.
+func (s *Server) handle(msg *message) {
+	result, err := s.dispatch(msg.Method, msg.Params)
+	if msg.ID == nil {
+		if err != nil {
+			s.log.Printf("%s: %v", msg.Method, err)
+		}
+		return
+	}
+	reply := &message{ID: msg.ID}
+	switch e := err.(type) {
+	case nil:
+		if result == nil {
+			result = json.RawMessage("null")
+		}
+		reply.Result = result
+	case *responseError:
+		reply.Error = e
+	default:
+		reply.Error = &responseError{Code: codeInternalError, Message: err.Error()}
+	}
+	s.write(reply)
+}
+
+func (s *Server) write(msg *message) {
+	if err := writeMessage(s.w, msg); err != nil {
+		s.log.Print(err)
+	}
+}
+
+func (s *Server) dispatch(method string, params json.RawMessage) (interface{}, error) {
+	switch method {
+	case "initialize":
+		var p initializeParams
+		if err := unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return s.initialize(p), nil
+	case "initialized", "shutdown", "textDocument/didSave":
+		return nil, nil
+	case "exit":
+		s.exit = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var p didOpenParams
+		if err := unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		s.update(p.TextDocument.URI, p.TextDocument.Text)
+		return nil, nil
+	case "textDocument/didChange":
+		var p didChangeParams
+		if err := unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		if n := len(p.ContentChanges); n > 0 {
+			s.update(p.TextDocument.URI, p.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var p didCloseParams
+		if err := unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		s.close(p.TextDocument.URI)
+		return nil, nil
+	case "textDocument/definition":
+		var p textDocumentPositionParams
+		if err := unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return s.definition(p), nil
+	case "textDocument/references":
+		var p textDocumentPositionParams
+		if err := unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return s.references(p), nil
+	case "textDocument/completion":
+		var p textDocumentPositionParams
+		if err := unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return s.completion(p), nil
+	case "textDocument/hover":
+		var p textDocumentPositionParams
+		if err := unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return s.hover(p), nil
+	case "textDocument/formatting":
+		var p formattingParams
+		if err := unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return s.formatting(p)
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: "method not found: " + method}
+}
+
+func unmarshal(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// initialize applies the initialization options of the client, loads the
+// templates found under the root folder, and returns the capabilities of the
+// server.
+func (s *Server) initialize(p initializeParams) interface{} {
+	if o := p.InitializationOptions; o != nil {
+		if o.TrimMarkers != nil {
+			s.opts.TrimMarkers = *o.TrimMarkers
+		}
+		if o.Handlebars != nil {
+			s.opts.Handlebars = *o.Handlebars
+		}
+	}
+	if path, ok := uriPath(p.RootURI); ok {
+		s.root = path
+		err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+			if err != nil || !info.Mode().IsRegular() || filepath.Ext(path) != templateExt {
+				return nil
+			}
+			src, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil
+			}
+			s.load(pathURI(path), string(src), false)
+			return nil
+		})
+		if err != nil {
+			s.log.Print(err)
+		}
+	}
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync":   1, // full
+			"definitionProvider": true,
+			"referencesProvider": true,
+			"completionProvider": map[string]interface{}{
+				"triggerCharacters": []string{"{", ">", "#", "^", "/", "&", "."},
+			},
+			"hoverProvider":              true,
+			"documentFormattingProvider": true,
+		},
+		"serverInfo": map[string]string{"name": "mustache"},
+	}
+}
+
+// load parses a template into the workspace.
+func (s *Server) load(uri, text string, open bool) {
+	doc := &document{
+		uri:  uri,
+		name: s.templateName(uri),
+		text: text,
+		open: open,
+	}
+	doc.tree, doc.err = parse.Parse(doc.name, text, parse.DefaultLeftDelim, parse.DefaultRightDelim, s.opts)
+	if doc.err != nil {
+		doc.tree = nil
+	}
+	s.docs[uri] = doc
+}
+
+// update replaces the text of an open document, and publishes the
+// diagnostics of the open documents.
+func (s *Server) update(uri, text string) {
+	s.load(uri, text, true)
+	s.publishDiagnostics()
+}
+
+// close reverts a closed document to its file on disk, if the file is part
+// of the workspace, or removes it from the workspace.
+func (s *Server) close(uri string) {
+	doc, ok := s.docs[uri]
+	if !ok {
+		return
+	}
+	delete(s.docs, uri)
+	if path, ok := uriPath(uri); ok && s.inRoot(path) {
+		if src, err := ioutil.ReadFile(path); err == nil {
+			s.load(uri, string(src), false)
+		}
+	}
+	// clear the diagnostics of the closed document.
+	s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: doc.uri, Diagnostics: []diagnostic{}})
+	s.publishDiagnostics()
+}
+
+// publishDiagnostics publishes the diagnostics of each open document. Since
+// a document may include any template as a partial, every open document is
+// checked again when one changes.
+func (s *Server) publishDiagnostics() {
+	for _, uri := range s.uris() {
+		doc := s.docs[uri]
+		if doc.open {
+			s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: s.diagnostics(doc)})
+		}
+	}
+}
+
+func (s *Server) notify(method string, params interface{}) {
+	b, err := json.Marshal(params)
+	if err != nil {
+		s.log.Print(err)
+		return
+	}
+	s.write(&message{Method: method, Params: b})
+}
+
+// uris returns the URIs of the documents in sorted order.
+func (s *Server) uris() []string {
+	uris := make([]string, 0, len(s.docs))
+	for uri := range s.docs {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+	return uris
+}
+
+// lookup returns the document of a template name. Documents open in the
+// client take precedence over files of the workspace with the same name.
+func (s *Server) lookup(name string) *document {
+	var found *document
+	for _, uri := range s.uris() {
+		doc := s.docs[uri]
+		if doc.name == name && (found == nil || doc.open && !found.open) {
+			found = doc
+		}
+	}
+	return found
+}
+
+// templateName returns the name of the template of a URI: its path relative
+// to the root folder without extension, or its base name for files outside
+// the root folder.
+func (s *Server) templateName(uri string) string {
+	path, ok := uriPath(uri)
+	if !ok {
+		path = uri
+	}
+	rel := filepath.Base(path)
+	if s.inRoot(path) {
+		rel, _ = filepath.Rel(s.root, path)
+	}
+	return filepath.ToSlash(strings.TrimSuffix(rel, filepath.Ext(rel)))
+}
+
+// inRoot reports whether path is within the root folder.
+func (s *Server) inRoot(path string) bool {
+	if s.root == "" {
+		return false
+	}
+	rel, err := filepath.Rel(s.root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// uriPath returns the file path of a file URI.
+func uriPath(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+	return filepath.FromSlash(u.Path), true
+}
+
+// pathURI returns the file URI of a path.
+func pathURI(path string) string {
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+	return u.String()
+}
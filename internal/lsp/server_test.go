@@ -0,0 +1,310 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eriklott/mustache/parse"
+)
+
+// session records the messages sent to a server.
+type session struct {
+	in bytes.Buffer
+	id int
+}
+
+func (s *session) request(method string, params interface{}) int {
+	s.id++
+	s.send(s.id, method, params)
+	return s.id
+}
+
+func (s *session) notify(method string, params interface{}) {
+	s.send(0, method, params)
+}
+
+// send writes a message; a zero id sends a notification.
+func (s *session) send(id int, method string, params interface{}) {
+	msg := &message{Method: method, Params: mustRaw(params)}
+	if id != 0 {
+		raw := mustRaw(id)
+		msg.ID = &raw
+	}
+	writeMessage(&s.in, msg)
+}
+
+func mustRaw(v interface{}) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func docParams(uri string, line, char int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]string{"uri": uri},
+		"position":     map[string]int{"line": line, "character": char},
+	}
+}
+
+func TestServe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mustache-lsp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"page.mustache":         "{{! The page.\n@param title string - the title }}\n<h1>{{title}}</h1>\n{{>footer}}{{>missing}}\n",
+		"footer.mustache":       "{{! Site footer. }}{{#links}}{{url}}{{/links}}",
+		"partials/nav.mustache": "{{home}}",
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	page := pathURI(filepath.Join(dir, "page.mustache"))
+	footer := pathURI(filepath.Join(dir, "footer.mustache"))
+	scratch := pathURI(filepath.Join(dir, "scratch.mustache"))
+
+	var s session
+	s.request("initialize", map[string]string{"rootUri": pathURI(dir)})
+	s.notify("initialized", struct{}{})
+	s.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]string{"uri": page, "text": files["page.mustache"]},
+	})
+	definition := s.request("textDocument/definition", docParams(page, 3, 5))
+	references := s.request("textDocument/references", docParams(page, 3, 5))
+	hoverKey := s.request("textDocument/hover", docParams(page, 2, 8))
+	hoverPartial := s.request("textDocument/hover", docParams(page, 3, 2))
+	completeParam := s.request("textDocument/completion", docParams(page, 2, 6))
+	s.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]string{"uri": scratch, "text": "é{{#a}}\n{{>fo\n{{ti"},
+	})
+	completePartial := s.request("textDocument/completion", docParams(scratch, 1, 5))
+	completeKey := s.request("textDocument/completion", docParams(scratch, 2, 4))
+	s.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]string{"uri": scratch},
+		"contentChanges": []map[string]string{{"text": "{{# a }}\n{{/a}}"}},
+	})
+	formatting := s.request("textDocument/formatting", map[string]interface{}{
+		"textDocument": map[string]string{"uri": scratch},
+	})
+	unknown := s.request("textDocument/unknown", struct{}{})
+	s.request("shutdown", nil)
+	s.notify("exit", nil)
+
+	var out, log bytes.Buffer
+	if err := Serve(&s.in, &out, &log, parse.Options{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if log.Len() > 0 {
+		t.Errorf("unexpected log output: %s", log.String())
+	}
+
+	results := make(map[int]json.RawMessage)
+	errors := make(map[int]*responseError)
+	diagnostics := make(map[string][]json.RawMessage)
+	r := bufio.NewReader(&out)
+	for {
+		msg, err := readMessage(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read message: %v", err)
+		}
+		switch {
+		case msg.Method == "textDocument/publishDiagnostics":
+			var p struct {
+				URI string `json:"uri"`
+			}
+			json.Unmarshal(msg.Params, &p)
+			diagnostics[p.URI] = append(diagnostics[p.URI], msg.Params)
+		case msg.ID != nil:
+			var id int
+			json.Unmarshal(*msg.ID, &id)
+			if msg.Error != nil {
+				errors[id] = msg.Error
+			}
+			results[id] = mustRaw(msg.Result)
+		}
+	}
+
+	check := func(name string, id int, want string) {
+		t.Helper()
+		var got, w interface{}
+		if err := json.Unmarshal(results[id], &got); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := json.Unmarshal([]byte(want), &w); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, w) {
+			t.Errorf("unexpected %s, got:%s, want:%s", name, results[id], want)
+		}
+	}
+	check("definition", definition, `[{"uri":"`+footer+`","range":{"start":{"line":0,"character":0},"end":{"line":0,"character":0}}}]`)
+	check("references", references, `[{"uri":"`+page+`","range":{"start":{"line":3,"character":0},"end":{"line":3,"character":11}}}]`)
+	check("key hover", hoverKey, `{"contents":{"kind":"markdown","value":"`+"`title`\\n\\n@param `title` *string* — the title"+`"},"range":{"start":{"line":2,"character":4},"end":{"line":2,"character":13}}}`)
+	check("partial hover", hoverPartial, `{"contents":{"kind":"markdown","value":"`+"partial `footer`\\n\\nSite footer."+`"},"range":{"start":{"line":3,"character":0},"end":{"line":3,"character":11}}}`)
+	check("partial completion", completePartial, `[{"label":"footer","kind":17},{"label":"page","kind":17},{"label":"partials/nav","kind":17},{"label":"scratch","kind":17}]`)
+	check("key completion", completeKey, `[{"label":"home","kind":6},{"label":"links","kind":6},{"label":"title","kind":6},{"label":"url","kind":6}]`)
+	check("param completion", completeParam, `[{"label":"home","kind":6},{"label":"links","kind":6},{"label":"title","kind":6,"detail":"string"},{"label":"url","kind":6}]`)
+	check("formatting", formatting, `[{"range":{"start":{"line":0,"character":0},"end":{"line":1,"character":6}},"newText":"{{#a}}\n{{/a}}"}]`)
+	if e := errors[unknown]; e == nil || e.Code != codeMethodNotFound {
+		t.Errorf("unexpected error for unknown method: %v", e)
+	}
+
+	// the last diagnostics published for each document.
+	last := func(uri string) string {
+		d := diagnostics[uri]
+		if len(d) == 0 {
+			return ""
+		}
+		return string(d[len(d)-1])
+	}
+	wantPage := `{"uri":"` + page + `","diagnostics":[{"range":{"start":{"line":3,"character":11},"end":{"line":3,"character":23}},"severity":2,"source":"mustache","message":"partial not found: missing"}]}`
+	if got := last(page); got != wantPage {
+		t.Errorf("unexpected page diagnostics, got:%s, want:%s", got, wantPage)
+	}
+	wantScratch := `{"uri":"` + scratch + `","diagnostics":[]}`
+	if got := last(scratch); got != wantScratch {
+		t.Errorf("unexpected scratch diagnostics, got:%s, want:%s", got, wantScratch)
+	}
+	wantError := `{"uri":"` + scratch + `","diagnostics":[{"range":{"start":{"line":1,"character":0},"end":{"line":1,"character":5}},"severity":1,"source":"mustache","message":"unclosed tag"}]}`
+	if d := diagnostics[scratch]; len(d) < 1 || string(d[0]) != wantError {
+		t.Errorf("unexpected scratch error diagnostics, got:%s, want:%s", d, wantError)
+	}
+	if !strings.HasPrefix(string(results[1]), `{"capabilities":`) {
+		t.Errorf("unexpected initialize result: %s", results[1])
+	}
+}
+
+// replies runs a session, and returns the results and errors of its
+// requests by ID.
+func replies(t *testing.T, s *session, opts parse.Options) (map[int]json.RawMessage, map[int]*responseError) {
+	t.Helper()
+	var out, log bytes.Buffer
+	if err := Serve(&s.in, &out, &log, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	results := make(map[int]json.RawMessage)
+	errors := make(map[int]*responseError)
+	r := bufio.NewReader(&out)
+	for {
+		msg, err := readMessage(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read message: %v", err)
+		}
+		if msg.ID != nil {
+			var id int
+			json.Unmarshal(*msg.ID, &id)
+			if msg.Error != nil {
+				errors[id] = msg.Error
+			}
+			results[id] = mustRaw(msg.Result)
+		}
+	}
+	return results, errors
+}
+
+func TestServe_TrimMarkers(t *testing.T) {
+	const uri = "file:///tmp/trim.mustache"
+	const text = "a  {{~name }}  b\n"
+	tt := []struct {
+		name    string
+		opts    parse.Options
+		init    map[string]interface{}
+		want    string
+		wantErr string
+	}{
+		{name: "Flag", opts: parse.Options{TrimMarkers: true}, init: map[string]interface{}{}, want: `[{"range":{"start":{"line":0,"character":0},"end":{"line":1,"character":0}},"newText":"a  {{~name}}  b\n"}]`},
+		{name: "Option", init: map[string]interface{}{"initializationOptions": map[string]bool{"trimMarkers": true}}, want: `[{"range":{"start":{"line":0,"character":0},"end":{"line":1,"character":0}},"newText":"a  {{~name}}  b\n"}]`},
+		{name: "Disabled", opts: parse.Options{TrimMarkers: true}, init: map[string]interface{}{"initializationOptions": map[string]bool{"trimMarkers": false}}, wantErr: "trim:1:4: ambiguous tag {{~name }}: ~ next to a delimiter is a trim marker when trim markers are enabled"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var s session
+			s.request("initialize", tc.init)
+			s.notify("textDocument/didOpen", map[string]interface{}{
+				"textDocument": map[string]string{"uri": uri, "text": text},
+			})
+			formatting := s.request("textDocument/formatting", map[string]interface{}{
+				"textDocument": map[string]string{"uri": uri},
+			})
+			s.notify("exit", nil)
+
+			results, errors := replies(t, &s, tc.opts)
+			if tc.wantErr != "" {
+				if e := errors[formatting]; e == nil || e.Message != tc.wantErr {
+					t.Errorf("unexpected formatting error, got:%v, want:%s", e, tc.wantErr)
+				}
+				return
+			}
+			var got, want interface{}
+			json.Unmarshal(results[formatting], &got)
+			json.Unmarshal([]byte(tc.want), &want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("unexpected formatting, got:%s, want:%s", results[formatting], tc.want)
+			}
+		})
+	}
+}
+
+func TestServe_Handlebars(t *testing.T) {
+	const uri = "file:///tmp/blocks.mustache"
+	const text = "{{# if a }}x{{ else }}y{{/if}}"
+	tt := []struct {
+		name string
+		opts parse.Options
+		init map[string]interface{}
+		want string
+	}{
+		{name: "Flag", opts: parse.Options{Handlebars: true}, init: map[string]interface{}{}, want: `[{"range":{"start":{"line":0,"character":0},"end":{"line":0,"character":30}},"newText":"{{#if a}}x{{else}}y{{/if}}"}]`},
+		{name: "Option", init: map[string]interface{}{"initializationOptions": map[string]bool{"handlebars": true}}, want: `[{"range":{"start":{"line":0,"character":0},"end":{"line":0,"character":30}},"newText":"{{#if a}}x{{else}}y{{/if}}"}]`},
+		{name: "Disabled", init: map[string]interface{}{}, want: `null`},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var s session
+			s.request("initialize", tc.init)
+			s.notify("textDocument/didOpen", map[string]interface{}{
+				"textDocument": map[string]string{"uri": uri, "text": text},
+			})
+			formatting := s.request("textDocument/formatting", map[string]interface{}{
+				"textDocument": map[string]string{"uri": uri},
+			})
+			s.notify("exit", nil)
+
+			results, _ := replies(t, &s, tc.opts)
+			var got, want interface{}
+			json.Unmarshal(results[formatting], &got)
+			json.Unmarshal([]byte(tc.want), &want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("unexpected formatting, got:%s, want:%s", results[formatting], tc.want)
+			}
+		})
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"io"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 // Type of a token
@@ -27,6 +29,7 @@ const (
 	PARTIAL
 	COMMENT
 	SET_DELIMETERS
+	ELSE
 )
 
 // Scanner transforms a mustache text template into a stream of tokens.
@@ -40,6 +43,14 @@ type Scanner struct {
 	ln        int
 	isNewLine bool
 	buf       Token
+
+	// TrimMarkers enables trim markers: a ~ following the left delimiter or
+	// preceding the right delimiter of a tag.
+	TrimMarkers bool
+
+	// Handlebars enables Handlebars compatibility: block helper sections
+	// such as {{#if cond}}, else tags and helper calls with arguments.
+	Handlebars bool
 }
 
 // NewScanner returns a new scanner instance
@@ -89,13 +100,18 @@ func (s *Scanner) readTo(pattern string, haltEOL bool) (bool, error) {
 }
 
 type Token struct {
-	Type      Type
-	Text      string
-	Indent    string
-	Offset    int
-	EndOffset int
-	Column    int
-	Line      int
+	Type       Type
+	Text       string
+	Raw        string // source of a tag, including delimiters
+	Standalone bool   // true when a tag stands alone on its line
+	Indent     string // whitespace preceding a standalone tag
+	LineEnd    string // whitespace and line ending following a standalone tag
+	TrimLeft   bool   // the tag has a trim marker after its left delimiter
+	TrimRight  bool   // the tag has a trim marker before its right delimiter
+	Offset     int
+	EndOffset  int
+	Column     int
+	Line       int
 }
 
 func (s *Scanner) Next() (Token, error) {
@@ -157,132 +173,140 @@ func (s *Scanner) Next() (Token, error) {
 	s.pos += len(s.ldelim)
 	s.col += len(s.ldelim)
 
+	// a trim marker may follow the left delimiter.
+	var trimLeft, trimRight bool
+	if s.TrimMarkers && s.pos < len(s.src) && s.src[s.pos] == '~' {
+		trimLeft = true
+		s.pos++
+		s.col++
+	}
+	bodyPos := s.pos
+
 	var tagSymbol byte
 	if s.pos < len(s.src) {
 		tagSymbol = s.src[s.pos]
 	}
 
+	// readTag reads to the right delimiter, and returns the text of the tag
+	// from start, excluding a trim marker preceding the delimiter.
+	readTag := func(start int) (string, error) {
+		_, err := s.readTo(s.rdelim, false)
+		if err != nil {
+			return "", s.error(startLn, startCol, "unclosed tag")
+		}
+		end := s.pos - len(s.rdelim)
+		if s.TrimMarkers && end > start && s.src[end-1] == '~' {
+			trimRight = true
+			end--
+		}
+		return s.src[start:end], nil
+	}
+
 	var tagType Type
 	var tagText string
 
 	switch tagSymbol {
 	case '{':
-		_, err = s.readTo("}"+s.rdelim, false)
-		if err != nil {
-			return Token{}, s.error(startLn, startCol, "unclosed tag")
+		closing := "}" + s.rdelim
+		if s.TrimMarkers {
+			i := strings.Index(s.src[s.pos:], closing)
+			if j := strings.Index(s.src[s.pos:], "}~"+s.rdelim); j >= 0 && (i < 0 || j < i) {
+				i, closing, trimRight = j, "}~"+s.rdelim, true
+			}
+			if i < 0 {
+				return Token{}, s.error(startLn, startCol, "unclosed tag")
+			}
+			s.advance(i + len(closing))
+		} else {
+			_, err = s.readTo(closing, false)
+			if err != nil {
+				return Token{}, s.error(startLn, startCol, "unclosed tag")
+			}
 		}
 		tagType = UNESCAPED_VARIABLE
-		key := s.src[startPos+len(s.ldelim)+1 : s.pos-len(s.rdelim)-1]
+		key := s.src[bodyPos+1 : s.pos-len(closing)]
 		key = strings.TrimSpace(key)
-		err = s.validateDottedKey(startLn, startCol, key)
+		err = s.validateVariable(startLn, startCol, key)
 		if err != nil {
 			return Token{}, err
 		}
 		tagText = key
 
-	case '&':
-		_, err = s.readTo(s.rdelim, false)
-		if err != nil {
-			return Token{}, s.error(startLn, startCol, "unclosed tag")
-		}
-		tagType = UNESCAPED_VARIABLE_SYM
-		key := s.src[startPos+len(s.ldelim)+1 : s.pos-len(s.rdelim)]
-		key = strings.TrimSpace(key)
-		err = s.validateDottedKey(startLn, startCol, key)
+	case '&', '#', '^', '/', '>':
+		key, err := readTag(bodyPos + 1)
 		if err != nil {
 			return Token{}, err
 		}
-		tagText = key
-
-	case '#':
-		_, err = s.readTo(s.rdelim, false)
-		if err != nil {
-			return Token{}, s.error(startLn, startCol, "unclosed tag")
-		}
-		tagType = SECTION
-		key := s.src[startPos+len(s.ldelim)+1 : s.pos-len(s.rdelim)]
-		key = strings.TrimSpace(key)
-		err = s.validateDottedKey(startLn, startCol, key)
-		if err != nil {
-			return Token{}, err
-		}
-		tagText = key
-
-	case '^':
-		_, err = s.readTo(s.rdelim, false)
-		if err != nil {
-			return Token{}, s.error(startLn, startCol, "unclosed tag")
-		}
-		tagType = INVERTED_SECTION
-		key := s.src[startPos+len(s.ldelim)+1 : s.pos-len(s.rdelim)]
 		key = strings.TrimSpace(key)
-		err = s.validateDottedKey(startLn, startCol, key)
-		if err != nil {
-			return Token{}, err
-		}
-		tagText = key
-
-	case '/':
-		_, err = s.readTo(s.rdelim, false)
-		if err != nil {
-			return Token{}, s.error(startLn, startCol, "unclosed tag")
-		}
-		tagType = SECTION_END
-		key := s.src[startPos+len(s.ldelim)+1 : s.pos-len(s.rdelim)]
-		key = strings.TrimSpace(key)
-		err = s.validateDottedKey(startLn, startCol, key)
-		if err != nil {
-			return Token{}, err
-		}
-		tagText = key
-
-	case '>':
-		_, err = s.readTo(s.rdelim, false)
-		if err != nil {
-			return Token{}, s.error(startLn, startCol, "unclosed tag")
+		switch {
+		case tagSymbol == '>':
+			err = s.validatePartialKey(startLn, startCol, key)
+		case tagSymbol == '#' && s.Handlebars && isBlock(key):
+			err = s.validateBlock(startLn, startCol, key)
+		case tagSymbol == '&':
+			err = s.validateVariable(startLn, startCol, key)
+		default:
+			err = s.validateDottedKey(startLn, startCol, key)
 		}
-		tagType = PARTIAL
-		key := s.src[startPos+len(s.ldelim)+1 : s.pos-len(s.rdelim)]
-		key = strings.TrimSpace(key)
-		err = s.validatePartialKey(startLn, startCol, key)
 		if err != nil {
 			return Token{}, err
 		}
+		tagType = symbolTypes[tagSymbol]
 		tagText = key
 
 	case '=':
+		if trimLeft {
+			return Token{}, s.error(startLn, startCol, "trim markers are not supported in set delimiter tags")
+		}
 		_, err = s.readTo("="+s.rdelim, false)
 		if err != nil {
 			return Token{}, s.error(startLn, startCol, "unclosed tag")
 		}
-		delims := s.src[startPos+len(s.ldelim)+1 : s.pos-len(s.rdelim)-1]
-		delims = strings.TrimSpace(delims)
-		parts := strings.Split(delims, " ")
-		if len(parts) == 2 {
-			s.ldelim = parts[0]
-			s.rdelim = parts[1]
+		// The closing = may be the opening one, as in {{=}}.
+		end := s.pos - len(s.rdelim) - 1
+		if end <= bodyPos {
+			return Token{}, s.error(startLn, startCol, "missing delimiters")
+		}
+		delims := s.src[bodyPos+1 : end]
+		parts := strings.Fields(delims)
+		switch {
+		case len(parts) == 0:
+			return Token{}, s.error(startLn, startCol, "missing delimiters")
+		case len(parts) != 2:
+			return Token{}, s.error(startLn, startCol, "invalid delimiters: expected two delimiters separated by whitespace: "+strings.TrimSpace(delims))
+		case strings.Contains(parts[0], "="):
+			return Token{}, s.error(startLn, startCol, "invalid delimiter: "+parts[0]+" contains =")
+		case strings.Contains(parts[1], "="):
+			return Token{}, s.error(startLn, startCol, "invalid delimiter: "+parts[1]+" contains =")
 		}
+		s.ldelim = parts[0]
+		s.rdelim = parts[1]
 		tagType = SET_DELIMETERS
-		tagText = delims
+		tagText = parts[0] + " " + parts[1]
 
 	case '!':
-		_, err = s.readTo(s.rdelim, false)
+		text, err := readTag(bodyPos + 1)
 		if err != nil {
-			return Token{}, s.error(startLn, startCol, "unclosed tag")
+			return Token{}, err
 		}
 		tagType = COMMENT
-		tagText = s.src[startPos+len(s.ldelim)+1 : s.pos-len(s.rdelim)]
-		tagText = strings.TrimSpace(tagText)
+		tagText = strings.TrimSpace(text)
 
 	default:
-		_, err = s.readTo(s.rdelim, false)
+		key, err := readTag(bodyPos)
 		if err != nil {
-			return Token{}, s.error(startLn, startCol, "unclosed tag")
+			return Token{}, err
 		}
 		tagType = VARIABLE
-		key := s.src[startPos+len(s.ldelim) : s.pos-len(s.rdelim)]
 		key = strings.TrimSpace(key)
-		err = s.validateDottedKey(startLn, startCol, key)
+		switch {
+		case s.Handlebars && key == "else":
+			tagType = ELSE
+		case s.Handlebars && key[:keyEnd(key, 0)] == "else":
+			err = s.error(startLn, startCol, "invalid else tag: "+key)
+		default:
+			err = s.validateVariable(startLn, startCol, key)
+		}
 		if err != nil {
 			return Token{}, err
 		}
@@ -292,6 +316,9 @@ func (s *Scanner) Next() (Token, error) {
 	tag := Token{
 		Type:      tagType,
 		Text:      tagText,
+		Raw:       s.src[startPos:s.pos],
+		TrimLeft:  trimLeft,
+		TrimRight: trimRight,
 		Offset:    startPos,
 		EndOffset: s.pos,
 		Column:    startCol,
@@ -301,10 +328,13 @@ func (s *Scanner) Next() (Token, error) {
 	if s.isNewLine {
 		s.isNewLine = false
 
-		if isStandaloneTagSymbol(tagSymbol) && s.hasLeftPadding(startPos) {
+		// tags with trim markers control their whitespace, and are never
+		// standalone.
+		if !trimLeft && !trimRight && (isStandaloneTagSymbol(tagSymbol) || tagType == ELSE) && s.hasLeftPadding(startPos) {
 			endOfLinePos, ok := s.hasRightPadding(s.pos)
 			if ok {
 				isStandaloneTag = true
+				tag.LineEnd = s.src[s.pos:endOfLinePos]
 				s.pos = endOfLinePos
 				s.col = 1
 				s.ln++
@@ -314,6 +344,7 @@ func (s *Scanner) Next() (Token, error) {
 	}
 
 	if isStandaloneTag {
+		tag.Standalone = true
 		tag.Indent = text.Text
 		return tag, nil
 	}
@@ -326,17 +357,50 @@ func (s *Scanner) Next() (Token, error) {
 	return text, nil
 }
 
-func (s *Scanner) error(ln, col int, msg string) error {
+// advance moves the scanner forward n bytes.
+func (s *Scanner) advance(n int) {
+	for i := 0; i < n; i++ {
+		s.col++
+		if s.src[s.pos] == '\n' {
+			s.col = 1
+			s.ln++
+		}
+		s.pos++
+	}
+}
+
+// symbolTypes maps tag symbols to the type of the tag.
+var symbolTypes = map[byte]Type{
+	'&': UNESCAPED_VARIABLE_SYM,
+	'#': SECTION,
+	'^': INVERTED_SECTION,
+	'/': SECTION_END,
+	'>': PARTIAL,
+}
+
+// Error is a syntax error found while scanning a template.
+type Error struct {
+	Name   string // name of the template
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *Error) Error() string {
 	var b strings.Builder
-	b.WriteString(s.name)
+	b.WriteString(e.Name)
 	b.WriteString(":")
-	b.WriteString(strconv.Itoa(ln))
+	b.WriteString(strconv.Itoa(e.Line))
 	b.WriteString(":")
-	b.WriteString(strconv.Itoa(col))
+	b.WriteString(strconv.Itoa(e.Column))
 	b.WriteString(":")
 	b.WriteString(" ")
-	b.WriteString(msg)
-	return errors.New(b.String())
+	b.WriteString(e.Msg)
+	return b.String()
+}
+
+func (s *Scanner) error(ln, col int, msg string) error {
+	return &Error{Name: s.name, Line: ln, Column: col, Msg: msg}
 }
 
 func isStandaloneTagSymbol(b byte) bool {
@@ -378,17 +442,14 @@ func (s *Scanner) hasRightPadding(pos int) (int, bool) {
 	}
 }
 
+// validatePartialKey validates the name of a partial, which may be any text
+// without whitespace, such as emails/header or ../layout.html.
 func (s *Scanner) validatePartialKey(ln, col int, raw string) error {
 	if len(raw) == 0 {
 		return s.error(ln, col, "missing key")
 	}
-	for i := range raw {
-		switch raw[i] {
-		case 'a', 'b', 'c', 'd', 'e', 'f', 'g', 'h', 'i', 'j', 'k', 'l', 'm', 'n', 'o', 'p', 'q', 'r', 's', 't', 'u', 'v', 'w', 'x', 'y', 'z', 'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M', 'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
-			// good char, do nothing
-		default:
-			return s.error(ln, col, "invalid key: "+raw)
-		}
+	if strings.IndexFunc(raw, unicode.IsSpace) >= 0 {
+		return s.error(ln, col, "invalid key: "+raw)
 	}
 	return nil
 }
@@ -397,27 +458,305 @@ func (s *Scanner) validateDottedKey(ln, col int, raw string) error {
 	if len(raw) == 0 {
 		return s.error(ln, col, "missing key")
 	}
-	if raw == "." {
+	if _, ok := SplitKey(raw); !ok {
+		return s.error(ln, col, "invalid key: "+raw)
+	}
+	return nil
+}
+
+func (s *Scanner) validateVariable(ln, col int, raw string) error {
+	if len(raw) == 0 {
+		return s.error(ln, col, "missing key")
+	}
+	if s.Handlebars && IsCall(raw) {
+		if _, _, err := SplitCall(raw); err != nil {
+			return s.error(ln, col, err.Error())
+		}
 		return nil
 	}
-	isValid := false
-Loop:
-	for i := range raw {
-		switch raw[i] {
-		case 'a', 'b', 'c', 'd', 'e', 'f', 'g', 'h', 'i', 'j', 'k', 'l', 'm', 'n', 'o', 'p', 'q', 'r', 's', 't', 'u', 'v', 'w', 'x', 'y', 'z', 'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M', 'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
-			isValid = true
-		case '.':
-			if i == 0 {
-				break Loop
+	if _, err := SplitVariable(raw); err != nil {
+		return s.error(ln, col, err.Error())
+	}
+	return nil
+}
+
+// validateBlock validates the text of a block helper section, the name of
+// the helper followed by a key.
+func (s *Scanner) validateBlock(ln, col int, raw string) error {
+	helper, key := SplitBlock(raw)
+	if key == "" {
+		return s.error(ln, col, "missing key: "+helper)
+	}
+	if _, ok := SplitKey(key); !ok {
+		return s.error(ln, col, "invalid key: "+key)
+	}
+	return nil
+}
+
+// BlockHelpers are the block helpers of Handlebars mode.
+var BlockHelpers = []string{"if", "unless", "each", "with"}
+
+// isBlock reports whether the text of a section tag starts with the name of
+// a block helper followed by whitespace, or is the name alone.
+func isBlock(text string) bool {
+	helper, _ := SplitBlock(text)
+	return helper != ""
+}
+
+// SplitBlock splits the text of a section tag into the name of its block
+// helper and its key, as in if cond. The helper is empty when the text does
+// not start with the name of a block helper.
+func SplitBlock(text string) (helper, key string) {
+	i := keyEnd(text, 0)
+	for _, name := range BlockHelpers {
+		if text[:i] == name {
+			return name, strings.TrimSpace(text[i:])
+		}
+	}
+	return "", text
+}
+
+// Argument is an argument of a helper call: a key, or a string, number or
+// boolean literal held by Value when Key is nil. Numbers are held as int64
+// or float64.
+type Argument struct {
+	Key   []string
+	Value interface{}
+}
+
+// IsCall reports whether the text of a variable tag is a helper call: a
+// name followed by whitespace and arguments, rather than by fallbacks.
+func IsCall(text string) bool {
+	i := keyEnd(text, 0)
+	j := skipSpace(text, i)
+	return j > i && j < len(text) && !strings.HasPrefix(text[j:], "??") && text[j] != '|'
+}
+
+// SplitCall splits the text of a helper call into the name of the helper
+// and its arguments, separated by whitespace, as in formatDate created
+// "short". An argument is a string, using the syntax of Go string literals,
+// a number, true, false, or a key.
+func SplitCall(text string) (string, []Argument, error) {
+	i := keyEnd(text, 0)
+	name := text[:i]
+	if names, ok := SplitKey(name); !ok || len(names) != 1 || name == "." {
+		return "", nil, errors.New("invalid helper name: " + name)
+	}
+	var args []Argument
+	for i = skipSpace(text, i); i < len(text); i = skipSpace(text, i) {
+		start := i
+		var arg Argument
+		if text[i] == '"' {
+			end := quoteEnd(text, i)
+			if end < 0 {
+				return "", nil, errors.New("unterminated string: " + text[i:])
+			}
+			str, err := strconv.Unquote(text[i:end])
+			if err != nil {
+				return "", nil, errors.New("invalid string: " + text[i:end])
+			}
+			arg.Value, i = str, end
+			if i < len(text) && skipSpace(text, i) == i {
+				return "", nil, errors.New("invalid argument: " + text[start:keyEnd(text, i)])
+			}
+		} else {
+			i = keyEnd(text, i)
+			word := text[start:i]
+			if v, ok := literal(word); ok {
+				arg.Value = v
+			} else if names, ok := SplitKey(word); ok {
+				arg.Key = names
+			} else {
+				return "", nil, errors.New("invalid argument: " + word)
+			}
+		}
+		args = append(args, arg)
+	}
+	return name, args, nil
+}
+
+// literal returns the value of a number or boolean literal argument.
+func literal(word string) (interface{}, bool) {
+	switch word {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	}
+	digits := strings.TrimPrefix(word, "-")
+	if digits == "" || digits[0] < '0' || digits[0] > '9' {
+		return nil, false
+	}
+	if n, err := strconv.ParseInt(word, 10, 64); err == nil {
+		return n, true
+	}
+	if f, err := strconv.ParseFloat(word, 64); err == nil {
+		return f, true
+	}
+	return nil, false
+}
+
+// Operand is the key, or a fallback, of a variable tag. Key is nil for
+// string fallbacks.
+type Operand struct {
+	Key    []string
+	String string
+}
+
+// SplitVariable splits the text of a variable tag into its key, followed by
+// its fallbacks. Each fallback follows ?? or | default:, which are preceded
+// by whitespace, and is a key or a string, as in nickname ?? name ?? "Guest".
+// Strings use the syntax of Go string literals, and can only be the last
+// fallback.
+func SplitVariable(text string) ([]Operand, error) {
+	var ops []Operand
+	i := 0
+	for {
+		start := i
+		var op Operand
+		if len(ops) > 0 && text[i] == '"' {
+			end := quoteEnd(text, i)
+			if end < 0 {
+				return nil, errors.New("unterminated string: " + text[i:])
 			}
-			isValid = false
+			str, err := strconv.Unquote(text[i:end])
+			if err != nil {
+				return nil, errors.New("invalid string: " + text[i:end])
+			}
+			op.String, i = str, end
+		} else {
+			i = keyEnd(text, i)
+			names, ok := SplitKey(text[start:i])
+			if !ok {
+				return nil, errors.New("invalid key: " + text[start:i])
+			}
+			op.Key = names
+		}
+		ops = append(ops, op)
+
+		j := skipSpace(text, i)
+		switch {
+		case j == len(text):
+			return ops, nil
+		case j > i && strings.HasPrefix(text[j:], "??"):
+			i = j + len("??")
+		case j > i && text[j] == '|':
+			j = skipSpace(text, j+1)
+			if !strings.HasPrefix(text[j:], "default:") {
+				return nil, errors.New("unknown filter: " + text[j:keyEnd(text, j)])
+			}
+			i = j + len("default:")
+		case len(ops) == 1:
+			return nil, errors.New("invalid key: " + text)
 		default:
-			isValid = false
-			break Loop
+			return nil, errors.New("invalid fallback: " + text[start:])
+		}
+		if op.Key == nil {
+			return nil, errors.New("a string must be the last fallback")
+		}
+		if i = skipSpace(text, i); i == len(text) {
+			return nil, errors.New("missing fallback")
 		}
 	}
-	if !isValid {
-		return s.error(ln, col, "invalid key: "+raw)
+}
+
+// keyEnd returns the offset of the first whitespace following offset i of
+// s, outside of quoted names, or the length of s.
+func keyEnd(s string, i int) int {
+	for i < len(s) {
+		if strings.HasPrefix(s[i:], `["`) {
+			end := quoteEnd(s, i+1)
+			if end < 0 {
+				return len(s)
+			}
+			i = end
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if unicode.IsSpace(r) {
+			break
+		}
+		i += size
 	}
-	return nil
+	return i
+}
+
+// skipSpace returns the offset of the first character following offset i of
+// s that is not whitespace.
+func skipSpace(s string, i int) int {
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+// SplitKey splits a key into its names, and reports whether the key is valid.
+// A key is either a single dot, denoting the current context, or names
+// separated by dots. A name is any text without whitespace or dots, or a
+// quoted name in brackets, such as ["first name"], which may contain any
+// character. Quoted names use the syntax of Go string literals, and may
+// directly follow another name, as in user["first name"].
+func SplitKey(key string) ([]string, bool) {
+	if key == "." {
+		return []string{"."}, true
+	}
+	var names []string
+	i := 0
+	for {
+		if strings.HasPrefix(key[i:], `["`) {
+			end := quoteEnd(key, i+1)
+			if end < 0 || end == len(key) || key[end] != ']' {
+				return nil, false
+			}
+			name, err := strconv.Unquote(key[i+1 : end])
+			if err != nil {
+				return nil, false
+			}
+			names = append(names, name)
+			i = end + 1
+		} else {
+			start := i
+			for i < len(key) && key[i] != '.' && !strings.HasPrefix(key[i:], `["`) {
+				r, size := utf8.DecodeRuneInString(key[i:])
+				if unicode.IsSpace(r) {
+					return nil, false
+				}
+				i += size
+			}
+			if i == start {
+				return nil, false
+			}
+			names = append(names, key[start:i])
+		}
+
+		switch {
+		case i == len(key):
+			return names, true
+		case key[i] == '.':
+			i++
+			if i == len(key) {
+				return nil, false
+			}
+		case !strings.HasPrefix(key[i:], `["`):
+			return nil, false
+		}
+	}
+}
+
+// quoteEnd returns the offset following the double quoted string starting
+// at offset i of s, or -1 if the string is not terminated.
+func quoteEnd(s string, i int) int {
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1
+		}
+	}
+	return -1
 }
@@ -35,15 +35,20 @@ func TestScanner_Next(t *testing.T) {
 		{"unescaped variable tag", "{{{ a }}}", []token{{x.UNESCAPED_VARIABLE, "a"}}, false},
 		{"unescaped variable symbole tag", "{{& a }}", []token{{x.UNESCAPED_VARIABLE_SYM, "a"}}, false},
 		{"dotted variable tag", "{{ . }}", []token{{x.VARIABLE, "."}}, false},
+		{"spec key", "{{first_name.user-id.naïve}}", []token{{x.VARIABLE, "first_name.user-id.naïve"}}, false},
+		{"quoted key", "{{ ['a'].[\"first name\"][\"x.y\"] }}", []token{{x.VARIABLE, "['a'].[\"first name\"][\"x.y\"]"}}, false},
 		{"section tag", "{{# a }}", []token{{x.SECTION, "a"}}, false},
 		{"inverted section tag", "{{^ a }}", []token{{x.INVERTED_SECTION, "a"}}, false},
 		{"section end tag", "{{/ a }}", []token{{x.SECTION_END, "a"}}, false},
 		{"partial tag", "{{> a }}", []token{{x.PARTIAL, "a"}}, false},
+		{"partial path", "{{>emails/user-card.html}}", []token{{x.PARTIAL, "emails/user-card.html"}}, false},
+		{"relative partial", "{{> ../layout }}", []token{{x.PARTIAL, "../layout"}}, false},
 		{"comment tag", "{{! abc  }}", []token{{x.COMMENT, "abc"}}, false},
 		{"set delims tag", "{{= | | =}}", []token{{x.SET_DELIMETERS, "| |"}}, false},
 		{"tags", "{{a}}{{b}}", []token{{x.VARIABLE, "a"}, {x.VARIABLE, "b"}}, false},
 		{"text & tag", "abc{{a}}", []token{{x.TEXT, "abc"}, {x.VARIABLE, "a"}}, false},
 		{"change delimes", "{{a}}{{=| |=}}|b|", []token{{x.VARIABLE, "a"}, {x.SET_DELIMETERS, "| |"}, {x.VARIABLE, "b"}}, false},
+		{"delimiter spacing", "{{=  <%  \t %>  =}}<%b%>", []token{{x.SET_DELIMETERS, "<% %>"}, {x.VARIABLE, "b"}}, false},
 		{"leading standalone", " {{#a}} \nabc", []token{{x.SECTION, "a"}, {x.TEXT, "abc"}}, false},
 		{"mid standalone", "abc\n {{#a}} \ndef", []token{{x.TEXT_EOL, "abc\n"}, {x.SECTION, "a"}, {x.TEXT, "def"}}, false},
 		{"trailing standalone", "abc\n {{#a}} ", []token{{x.TEXT_EOL, "abc\n"}, {x.SECTION, "a"}}, false},
@@ -54,6 +59,16 @@ func TestScanner_Next(t *testing.T) {
 		{"leading dot", "{{.a}}", nil, true},
 		{"trailing dot", "{{a.}}", nil, true},
 		{"dot whitespace", "{{a . b}}", nil, true},
+		{"unterminated quote", "{{[\"a}}", nil, true},
+		{"unclosed bracket", "{{[\"a\"}}", nil, true},
+		{"quoted suffix", "{{[\"a\"]b}}", nil, true},
+		{"partial whitespace", "{{> a b }}", nil, true},
+		{"missing delimiters", "{{= =}}", nil, true},
+		{"empty set delims tag", "{{=}}", nil, true},
+		{"one delimiter", "{{=| =}}", nil, true},
+		{"three delimiters", "{{=| | | =}}", nil, true},
+		{"delimiter with equals", "{{=<= =>=}}", nil, true},
+		{"missing partial", "{{> }}", nil, true},
 	}
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
@@ -86,6 +101,173 @@ func TestScanner_Next(t *testing.T) {
 	}
 }
 
+func TestScanner_SetDelimitersError(t *testing.T) {
+	tt := []struct {
+		name string
+		src  string
+		err  string
+	}{
+		{"empty", "{{=}}", "main:1:1: missing delimiters"},
+		{"blank", "{{= =}}", "main:1:1: missing delimiters"},
+		{"empty after text", "a\n b{{=}}", "main:2:3: missing delimiters"},
+		{"empty custom", "{{=<% %>=}}<%=%>", "main:1:12: missing delimiters"},
+		{"one delimiter", "{{=| =}}", "main:1:1: invalid delimiters: expected two delimiters separated by whitespace: |"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			scanner := x.NewScanner("main", tc.src, "{{", "}}")
+			for {
+				_, err := scanner.Next()
+				if err == io.EOF {
+					t.Fatalf("expected error %s", tc.err)
+				}
+				if err != nil {
+					if err.Error() != tc.err {
+						t.Errorf("unexpected error, got:%s, want:%s", err, tc.err)
+					}
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestScanner_TrimMarkers(t *testing.T) {
+	type trimToken struct {
+		Type      x.Type
+		Text      string
+		TrimLeft  bool
+		TrimRight bool
+	}
+	tt := []struct {
+		name   string
+		src    string
+		ldelim string
+		rdelim string
+		tokens []trimToken
+		err    string
+	}{
+		{"variable", "{{~a~}}", "{{", "}}", []trimToken{{x.VARIABLE, "a", true, true}}, ""},
+		{"left", "{{~ a }}", "{{", "}}", []trimToken{{x.VARIABLE, "a", true, false}}, ""},
+		{"right", "{{ a ~}}", "{{", "}}", []trimToken{{x.VARIABLE, "a", false, true}}, ""},
+		{"unescaped", "{{~{a}~}}", "{{", "}}", []trimToken{{x.UNESCAPED_VARIABLE, "a", true, true}}, ""},
+		{"unescaped symbol", "{{~& a}}", "{{", "}}", []trimToken{{x.UNESCAPED_VARIABLE_SYM, "a", true, false}}, ""},
+		{"section", "{{~#a~}}{{~/a~}}", "{{", "}}", []trimToken{{x.SECTION, "a", true, true}, {x.SECTION_END, "a", true, true}}, ""},
+		{"inverted section", "{{^a~}}", "{{", "}}", []trimToken{{x.INVERTED_SECTION, "a", false, true}}, ""},
+		{"partial", "{{~> a}}", "{{", "}}", []trimToken{{x.PARTIAL, "a", true, false}}, ""},
+		{"comment", "{{~! a ~}}", "{{", "}}", []trimToken{{x.COMMENT, "a", true, true}}, ""},
+		{"custom delims", "<%~a~%><%~{b}~%>", "<%", "%>", []trimToken{{x.VARIABLE, "a", true, true}, {x.UNESCAPED_VARIABLE, "b", true, true}}, ""},
+		{"not standalone", "{{~#a}}\n", "{{", "}}", []trimToken{{x.SECTION, "a", true, false}, {x.TEXT_EOL, "\n", false, false}}, ""},
+		{"set delims", "{{~=| |=}}", "{{", "}}", nil, "main:1:1: trim markers are not supported in set delimiter tags"},
+		{"unclosed unescaped", "{{~{a}", "{{", "}}", nil, "main:1:1: unclosed tag"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			scanner := x.NewScanner("main", tc.src, tc.ldelim, tc.rdelim)
+			scanner.TrimMarkers = true
+			var tokens []trimToken
+			for {
+				tok, err := scanner.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					if err.Error() != tc.err {
+						t.Fatalf("unexpected error, got:%v, want:%s", err, tc.err)
+					}
+					return
+				}
+				tokens = append(tokens, trimToken{tok.Type, tok.Text, tok.TrimLeft, tok.TrimRight})
+			}
+			if tc.err != "" {
+				t.Fatalf("expected error: %s", tc.err)
+			}
+			if !reflect.DeepEqual(tc.tokens, tokens) {
+				t.Errorf("unexpected tokens, got:%v, want:%v", tokens, tc.tokens)
+			}
+		})
+	}
+}
+
+func TestScanner_Handlebars(t *testing.T) {
+	type hbsToken struct {
+		Type       x.Type
+		Text       string
+		Standalone bool
+	}
+	tt := []struct {
+		name   string
+		src    string
+		tokens []hbsToken
+		err    string
+	}{
+		{"if", "{{#if a.b}}{{/if}}", []hbsToken{{x.SECTION, "if a.b", false}, {x.SECTION_END, "if", false}}, ""},
+		{"section", "{{#iffy}}", []hbsToken{{x.SECTION, "iffy", true}}, ""},
+		{"else", "{{#a}}\n  {{ else }}\n{{/a}}", []hbsToken{{x.SECTION, "a", true}, {x.ELSE, "else", true}, {x.SECTION_END, "a", true}}, ""},
+		{"call", `{{formatDate created "short"}}`, []hbsToken{{x.VARIABLE, `formatDate created "short"`, false}}, ""},
+		{"fallbacks", `{{a ?? "b"}}`, []hbsToken{{x.VARIABLE, `a ?? "b"`, false}}, ""},
+		{"missing key", "{{#each}}", nil, "main:1:1: missing key: each"},
+		{"invalid key", "{{#with a b}}", nil, "main:1:1: invalid key: a b"},
+		{"inverted", "{{^if a}}", nil, "main:1:1: invalid key: if a"},
+		{"else if", "{{else if a}}", nil, "main:1:1: invalid else tag: else if a"},
+		{"invalid argument", "{{f a.}}", nil, "main:1:1: invalid argument: a."},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			scanner := x.NewScanner("main", tc.src, "{{", "}}")
+			scanner.Handlebars = true
+			var tokens []hbsToken
+			for {
+				tok, err := scanner.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					if err.Error() != tc.err {
+						t.Fatalf("unexpected error, got:%v, want:%s", err, tc.err)
+					}
+					return
+				}
+				tokens = append(tokens, hbsToken{tok.Type, tok.Text, tok.Standalone})
+			}
+			if tc.err != "" {
+				t.Fatalf("expected error: %s", tc.err)
+			}
+			if !reflect.DeepEqual(tc.tokens, tokens) {
+				t.Errorf("unexpected tokens, got:%v, want:%v", tokens, tc.tokens)
+			}
+		})
+	}
+}
+
+func TestSplitCall(t *testing.T) {
+	tt := []struct {
+		text string
+		name string
+		args []x.Argument
+		err  string
+	}{
+		{text: `formatDate created "short"`, name: "formatDate", args: []x.Argument{{Key: []string{"created"}}, {Value: "short"}}},
+		{text: "f 1 -2 2.5 1e3 true false", name: "f", args: []x.Argument{{Value: int64(1)}, {Value: int64(-2)}, {Value: 2.5}, {Value: 1e3}, {Value: true}, {Value: false}}},
+		{text: `f  . a.b user["first name"]  1a`, name: "f", args: []x.Argument{{Key: []string{"."}}, {Key: []string{"a", "b"}}, {Key: []string{"user", "first name"}}, {Key: []string{"1a"}}}},
+		{text: "a.b c", err: "invalid helper name: a.b"},
+		{text: `f "x`, err: `unterminated string: "x`},
+		{text: `f "\q"`, err: `invalid string: "\q"`},
+		{text: `f "x"y`, err: `invalid argument: "x"y`},
+		{text: "f a..b", err: "invalid argument: a..b"},
+	}
+	for _, tc := range tt {
+		name, args, err := x.SplitCall(tc.text)
+		var gotErr string
+		if err != nil {
+			gotErr = err.Error()
+		}
+		if gotErr != tc.err || name != tc.name || !reflect.DeepEqual(args, tc.args) {
+			t.Errorf("unexpected result for %q, got:%q %v %s, want:%q %v %s", tc.text, name, args, gotErr, tc.name, tc.args, tc.err)
+		}
+	}
+}
+
 func BenchmarkScanner_Next(b *testing.B) {
 	srcBytes, err := ioutil.ReadFile("../../testdata/template.mustache")
 	if err != nil {
@@ -106,3 +288,71 @@ func BenchmarkScanner_Next(b *testing.B) {
 		}
 	}
 }
+
+func TestSplitKey(t *testing.T) {
+	tt := []struct {
+		key   string
+		names []string
+	}{
+		{".", []string{"."}},
+		{"a", []string{"a"}},
+		{"a.b-c.d_e", []string{"a", "b-c", "d_e"}},
+		{"é.ü", []string{"é", "ü"}},
+		{"a[0]", []string{"a[0]"}},
+		{`["first name"]`, []string{"first name"}},
+		{`user["first name"].x`, []string{"user", "first name", "x"}},
+		{`a.["b.c"]["\"d\"\n"]`, []string{"a", "b.c", "\"d\"\n"}},
+		{"", nil},
+		{"a.", nil},
+		{".a", nil},
+		{"a..b", nil},
+		{"a b", nil},
+		{`["a"`, nil},
+		{`["a\q"]`, nil},
+		{`["a"].`, nil},
+	}
+	for _, tc := range tt {
+		names, ok := x.SplitKey(tc.key)
+		if ok != (tc.names != nil) || !reflect.DeepEqual(names, tc.names) {
+			t.Errorf("unexpected names for %q, got:%q %v, want:%q", tc.key, names, ok, tc.names)
+		}
+	}
+}
+
+func TestSplitVariable(t *testing.T) {
+	tt := []struct {
+		text string
+		ops  []x.Operand
+		err  string
+	}{
+		{text: "a.b", ops: []x.Operand{{Key: []string{"a", "b"}}}},
+		{text: "a??b", ops: []x.Operand{{Key: []string{"a??b"}}}},
+		{text: "a|b", ops: []x.Operand{{Key: []string{"a|b"}}}},
+		{text: `"a"`, ops: []x.Operand{{Key: []string{`"a"`}}}},
+		{text: `nickname ?? name ?? "Guest"`, ops: []x.Operand{{Key: []string{"nickname"}}, {Key: []string{"name"}}, {String: "Guest"}}},
+		{text: `name | default:"Guest"`, ops: []x.Operand{{Key: []string{"name"}}, {String: "Guest"}}},
+		{text: "a	|  default: b ??c", ops: []x.Operand{{Key: []string{"a"}}, {Key: []string{"b"}}, {Key: []string{"c"}}}},
+		{text: `user["first name"] ?? ["b c"].d ?? "\"x\"\n"`, ops: []x.Operand{{Key: []string{"user", "first name"}}, {Key: []string{"b c", "d"}}, {String: "\"x\"\n"}}},
+		{text: `a ?? ""`, ops: []x.Operand{{Key: []string{"a"}}, {String: ""}}},
+		{text: "a b", err: "invalid key: a b"},
+		{text: "a ??", err: "missing fallback"},
+		{text: "a | default:", err: "missing fallback"},
+		{text: "a | upper", err: "unknown filter: upper"},
+		{text: `a ?? "x" ?? b`, err: "a string must be the last fallback"},
+		{text: `a ?? "x"b`, err: `invalid fallback: "x"b`},
+		{text: "a ?? b c", err: "invalid fallback: b c"},
+		{text: "a ?? b.", err: "invalid key: b."},
+		{text: `a ?? "x`, err: `unterminated string: "x`},
+		{text: `a ?? "\q"`, err: `invalid string: "\q"`},
+	}
+	for _, tc := range tt {
+		ops, err := x.SplitVariable(tc.text)
+		var gotErr string
+		if err != nil {
+			gotErr = err.Error()
+		}
+		if gotErr != tc.err || !reflect.DeepEqual(ops, tc.ops) {
+			t.Errorf("unexpected result for %q, got:%q %s, want:%q %s", tc.text, ops, gotErr, tc.ops, tc.err)
+		}
+	}
+}
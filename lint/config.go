@@ -0,0 +1,92 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+)
+
+// Config selects and configures the rules applied by a Linter. The zero
+// value enables every rule with its default settings.
+//
+// A Config is typically loaded from a JSON file in the project root:
+//
+//	{
+//		"disable": ["triple-stache"],
+//		"maxDepth": 6,
+//		"partials": ["partials/*"]
+//	}
+type Config struct {
+	// Disable lists the names of rules that are not applied.
+	Disable []string `json:"disable"`
+
+	// MaxDepth is the maximum nesting depth of sections. When zero,
+	// DefaultMaxDepth is used.
+	MaxDepth int `json:"maxDepth"`
+
+	// Partials lists path.Match patterns for the names of templates that are
+	// only used as partials. The unused-partial rule reports matching
+	// templates that are never included.
+	Partials []string `json:"partials"`
+}
+
+// Enabled reports whether the named rule is applied.
+func (c Config) Enabled(rule string) bool {
+	for _, name := range c.Disable {
+		if name == rule {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Config) maxDepth() int {
+	if c.MaxDepth > 0 {
+		return c.MaxDepth
+	}
+	return DefaultMaxDepth
+}
+
+// LoadConfig reads a JSON configuration file.
+func LoadConfig(path string) (Config, error) {
+	var c Config
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(b, &c)
+	if err != nil {
+		return c, fmt.Errorf("%s: %v", path, err)
+	}
+	for _, name := range c.Disable {
+		if !isRule(name) {
+			return c, fmt.Errorf("%s: unknown rule: %s", path, name)
+		}
+	}
+	return c, nil
+}
+
+// isPartial reports whether a template name matches one of the configured
+// partial patterns.
+func (c Config) isPartial(name string) bool {
+	for _, pattern := range c.Partials {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func isRule(name string) bool {
+	for _, rule := range Rules {
+		if rule == name {
+			return true
+		}
+	}
+	return false
+}
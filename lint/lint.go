@@ -0,0 +1,224 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package lint reports suspicious constructs in a set of mustache templates.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/eriklott/mustache/ast"
+	"github.com/eriklott/mustache/parse"
+)
+
+// Rule names
+const (
+	UnknownPartial   = "unknown-partial"   // partial tag names a template that does not exist
+	UnusedPartial    = "unused-partial"    // partial template is never included
+	MismatchedClose  = "mismatched-close"  // section closing tag spacing differs from the opening tag
+	TripleStache     = "triple-stache"     // variable is rendered without escaping
+	UnusedDelimiters = "unused-delimiters" // set delimiter tag has no effect
+	MaxDepth         = "max-depth"         // sections are nested too deeply
+)
+
+// Rules lists the names of all rules.
+var Rules = []string{
+	UnknownPartial,
+	UnusedPartial,
+	MismatchedClose,
+	TripleStache,
+	UnusedDelimiters,
+	MaxDepth,
+}
+
+// DefaultMaxDepth is the maximum section depth used when Config.MaxDepth is zero.
+const DefaultMaxDepth = 4
+
+// Diagnostic is a problem reported by a rule.
+type Diagnostic struct {
+	File    string // name of the tree the problem was found in
+	Line    int
+	Column  int
+	Rule    string
+	Message string
+}
+
+// String formats the diagnostic as file:line:col: message (rule).
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s (%s)", d.File, d.Line, d.Column, d.Message, d.Rule)
+}
+
+// Linter checks a set of templates. Trees are added under the name used to
+// include them with a partial tag; diagnostics are reported against the
+// Name of each tree, which is typically its file path.
+type Linter struct {
+	config Config
+	names  []string
+	trees  map[string]*ast.Tree
+}
+
+// New returns a linter that applies the rules enabled by config.
+func New(config Config) *Linter {
+	return &Linter{
+		config: config,
+		trees:  make(map[string]*ast.Tree),
+	}
+}
+
+// Add adds a parsed template to the linter.
+func (l *Linter) Add(name string, tree *ast.Tree) {
+	if _, ok := l.trees[name]; !ok {
+		l.names = append(l.names, name)
+	}
+	l.trees[name] = tree
+}
+
+// Lint applies the enabled rules to every template and returns the
+// diagnostics, ordered by file and position.
+func (l *Linter) Lint() []Diagnostic {
+	var diags []Diagnostic
+	report := func(tree *ast.Tree, ln, col int, rule, format string, args ...interface{}) {
+		if !l.config.Enabled(rule) {
+			return
+		}
+		diags = append(diags, Diagnostic{
+			File:    tree.Name,
+			Line:    ln,
+			Column:  col,
+			Rule:    rule,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	used := make(map[string]bool)
+	for _, name := range l.names {
+		tree := l.trees[name]
+		c := &checker{linter: l, name: name, tree: tree, used: used, report: report}
+		c.check()
+	}
+
+	for _, name := range l.names {
+		if used[name] || !l.config.isPartial(name) {
+			continue
+		}
+		report(l.trees[name], 1, 1, UnusedPartial, "partial %s is never used", name)
+	}
+
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i], diags[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return diags
+}
+
+// checker applies the rules to a single tree.
+type checker struct {
+	linter *Linter
+	name   string // the name the tree was added under
+	tree   *ast.Tree
+	used   map[string]bool
+	report func(tree *ast.Tree, ln, col int, rule, format string, args ...interface{})
+
+	ldelim string
+	rdelim string
+	delims *ast.SetDelims // the last set delimiter tag, until a tag uses its delimiters
+}
+
+func (c *checker) check() {
+	c.ldelim, c.rdelim = c.tree.LDelim, c.tree.RDelim
+	if c.ldelim == "" || c.rdelim == "" {
+		c.ldelim, c.rdelim = parse.DefaultLeftDelim, parse.DefaultRightDelim
+	}
+	c.nodes(c.tree.Nodes, 0)
+	c.flushDelims()
+}
+
+func (c *checker) nodes(nodes []ast.Node, depth int) {
+	for _, node := range nodes {
+		c.node(node, depth)
+	}
+}
+
+func (c *checker) node(node ast.Node, depth int) {
+	switch n := node.(type) {
+	case *ast.Variable:
+		c.delims = nil
+		if n.Unescaped {
+			c.report(c.tree, n.Line, n.Column, TripleStache, "%s is rendered without escaping", strings.Join(n.Key, "."))
+		}
+
+	case *ast.Section:
+		c.delims = nil
+		// only the outermost section exceeding the maximum depth is reported.
+		maxDepth := c.linter.config.maxDepth()
+		if depth == maxDepth {
+			c.report(c.tree, n.Line, n.Column, MaxDepth, "section %s is nested deeper than %d", strings.Join(n.Key, "."), maxDepth)
+		}
+		open := tagBody(n.Raw, n.LDelim, n.RDelim)
+		c.nodes(n.Nodes, depth+1)
+		// the delimiters may have been changed inside the section, and are
+		// used by the closing tag.
+		c.delims = nil
+		close := tagBody(n.Close.Raw, c.ldelim, c.rdelim)
+		if spacing(open) != spacing(close) {
+			pos := n.Close.Range.Start
+			c.report(c.tree, pos.Line, pos.Column, MismatchedClose, "closing tag %s does not match the spacing of opening tag %s", n.Close.Raw, n.Raw)
+		}
+
+	case *ast.Partial:
+		c.delims = nil
+		name := n.Resolve(c.name)
+		c.used[name] = true
+		if _, ok := c.linter.trees[name]; !ok {
+			c.report(c.tree, n.Line, n.Column, UnknownPartial, "unknown partial %s", n.Key)
+		}
+
+	case *ast.Comment:
+		c.delims = nil
+
+	case *ast.SetDelims:
+		c.flushDelims()
+		if n.LDelim == c.ldelim && n.RDelim == c.rdelim {
+			c.report(c.tree, n.Line, n.Column, UnusedDelimiters, "delimiters are already %s %s", n.LDelim, n.RDelim)
+		} else {
+			c.delims = n
+		}
+		c.ldelim, c.rdelim = n.LDelim, n.RDelim
+	}
+}
+
+// flushDelims reports the last set delimiter tag if no tag used its
+// delimiters.
+func (c *checker) flushDelims() {
+	if c.delims != nil {
+		c.report(c.tree, c.delims.Line, c.delims.Column, UnusedDelimiters, "delimiters %s %s are never used", c.delims.LDelim, c.delims.RDelim)
+		c.delims = nil
+	}
+}
+
+// tagBody returns the text of a raw tag between its delimiters, without the
+// tag symbol.
+func tagBody(raw, ldelim, rdelim string) string {
+	raw = strings.TrimPrefix(raw, ldelim)
+	raw = strings.TrimSuffix(raw, rdelim)
+	if len(raw) > 0 {
+		raw = raw[1:]
+	}
+	return raw
+}
+
+// spacing returns the whitespace surrounding the key of a tag body.
+func spacing(body string) [2]string {
+	key := strings.TrimSpace(body)
+	i := strings.Index(body, key)
+	return [2]string{body[:i], body[i+len(key):]}
+}
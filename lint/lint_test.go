@@ -0,0 +1,103 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package lint_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eriklott/mustache/lint"
+	"github.com/eriklott/mustache/parse"
+)
+
+func TestLinter_Lint(t *testing.T) {
+	tt := []struct {
+		name      string
+		templates map[string]string
+		config    lint.Config
+		want      []string
+	}{
+		{
+			name:      "Clean",
+			templates: map[string]string{"main": "{{#a}}{{b}}{{/a}}{{>footer}}", "footer": "{{! footer }}"},
+			config:    lint.Config{Partials: []string{"footer"}},
+			want:      nil,
+		},
+		{
+			name:      "UnknownPartial",
+			templates: map[string]string{"main": "abc\n {{>footer}}"},
+			want:      []string{"main:2:2: unknown partial footer (unknown-partial)"},
+		},
+		{
+			name:      "UnusedPartial",
+			templates: map[string]string{"main": "abc", "partials/footer": "def", "header": "ghi"},
+			config:    lint.Config{Partials: []string{"partials/*"}},
+			want:      []string{"partials/footer:1:1: partial partials/footer is never used (unused-partial)"},
+		},
+		{
+			name:      "RelativePartial",
+			templates: map[string]string{"partials/main": "{{>./footer}}{{>../header}}", "partials/footer": "def", "header": "ghi"},
+			config:    lint.Config{Partials: []string{"partials/*"}},
+			want:      []string{"partials/main:1:1: partial partials/main is never used (unused-partial)"},
+		},
+		{
+			name:      "MismatchedClose",
+			templates: map[string]string{"main": "{{# a }}{{/a}}{{#b}}{{/ b}}{{# c }}{{/ c }}"},
+			want: []string{
+				"main:1:9: closing tag {{/a}} does not match the spacing of opening tag {{# a }} (mismatched-close)",
+				"main:1:21: closing tag {{/ b}} does not match the spacing of opening tag {{#b}} (mismatched-close)",
+			},
+		},
+		{
+			name:      "TripleStache",
+			templates: map[string]string{"main": "{{a}}{{{b}}}{{&c}}"},
+			want: []string{
+				"main:1:6: b is rendered without escaping (triple-stache)",
+				"main:1:13: c is rendered without escaping (triple-stache)",
+			},
+		},
+		{
+			name:      "UnusedDelimiters",
+			templates: map[string]string{"main": "{{={{ }}=}}{{=<% %>=}}<%a%><%=| |=%>|={{ }}=|{{#b}}{{=[ ]=}}[/b]"},
+			want: []string{
+				"main:1:1: delimiters are already {{ }} (unused-delimiters)",
+				"main:1:28: delimiters | | are never used (unused-delimiters)",
+			},
+		},
+		{
+			name:      "MaxDepth",
+			templates: map[string]string{"main": "{{#a}}{{#b}}{{#c}}{{#d}}{{/d}}{{/c}}{{/b}}{{/a}}"},
+			config:    lint.Config{MaxDepth: 2},
+			want:      []string{"main:1:13: section c is nested deeper than 2 (max-depth)"},
+		},
+		{
+			name:      "Disable",
+			templates: map[string]string{"main": "{{{a}}}{{>b}}"},
+			config:    lint.Config{Disable: []string{lint.TripleStache}},
+			want:      []string{"main:1:8: unknown partial b (unknown-partial)"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			l := lint.New(tc.config)
+			for name, src := range tc.templates {
+				tree, err := parse.Parse(name, src, parse.DefaultLeftDelim, parse.DefaultRightDelim, parse.Options{})
+				if err != nil {
+					t.Fatalf("failed to parse template: %v", err)
+				}
+				l.Add(name, tree)
+			}
+
+			var got []string
+			for _, d := range l.Lint() {
+				got = append(got, d.String())
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("unexpected diagnostics, got:%q, want:%q", got, tc.want)
+			}
+		})
+	}
+}
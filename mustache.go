@@ -8,16 +8,71 @@ import (
 	"fmt"
 	"reflect"
 
-	"github.com/eriklott/mustache/internal/ast"
-	"github.com/eriklott/mustache/internal/parse"
+	"github.com/eriklott/mustache/ast"
+	"github.com/eriklott/mustache/parse"
 )
 
+// ParseOptions configures the parsing of templates: the limits enforced on
+// template sources, where a zero value disables a limit, whether trim
+// markers such as {{~name~}} are enabled, and whether Handlebars
+// compatibility is enabled.
+type ParseOptions = parse.Options
+
+// LimitError is returned by Parse when a template exceeds one of the limits
+// configured in ParseOptions.
+type LimitError = parse.LimitError
+
 // Template is the representation of a parsed template.
 type Template struct {
 	treeMap              map[string]*ast.Tree
+	progMap              map[string]program
 	ContextErrorsEnabled bool
+	ParseOptions         ParseOptions
+
+	// LeftDelim and RightDelim are the delimiters in effect at the start of
+	// the templates added by Parse. The default delimiters {{ and }} are used
+	// when they are empty.
+	LeftDelim  string
+	RightDelim string
+
+	// Escape escapes the values of variable tags, other than triple mustache
+	// and ampersand tags. Values are HTML escaped when Escape is nil.
+	Escape func(string) string
+
+	helpers map[string]Helper
+	funcs   map[string]reflect.Value
 }
 
+// Helper is a function applied to the rendered text of a section. Unlike a
+// lambda taking the section text, which receives the text unrendered and
+// whose result is rendered, a helper receives the output of the section and
+// its result is written:
+//
+//	{{#upper}}Hello {{name}}{{/upper}}
+//
+// The section is rendered as usual, so the helper sees the values of
+// variable tags escaped, along with the markup of the section, and its result
+// is written without escaping, as the result of a lambda is. A triple
+// mustache passes a value as it is, for helpers that must not see escaped
+// text, such as one encoding JSON; such a helper must then escape its result
+// itself:
+//
+//	<script>var name = {{#json}}{{{name}}}{{/json}};</script>
+//
+// Helpers are registered with a template by RegisterHelper, and may also be
+// given in the data as values of type Helper. They are not the helpers of
+// Handlebars, whose functions are registered by RegisterFunc.
+type Helper func(string) string
+
+// Lambda is a function applied to the unrendered text of a section, which
+// can render text against the context of the section with render. Its result
+// is written as is. Lambdas are given in the data as values of type Lambda.
+type Lambda func(text string, render RenderFunc) (string, error)
+
+// RenderFunc renders a template text against the context of a lambda. The
+// text is parsed with the default delimiters.
+type RenderFunc func(text string) (string, error)
+
 // NewTemplate allocates a new template.
 func NewTemplate() *Template {
 	t := &Template{
@@ -30,24 +85,138 @@ func NewTemplate() *Template {
 // the Render method, or using a partial tag. If an error occurs during parsing, the parsing
 // process stops, and the error is returned.
 func (t *Template) Parse(name, text string) error {
-	tree, err := parse.Parse(name, text, parse.DefaultLeftDelim, parse.DefaultRightDelim)
+	ldelim, rdelim := t.Delims()
+	tree, err := parse.Parse(name, text, ldelim, rdelim, t.ParseOptions)
 	if err != nil {
 		return err
 	}
 	t.treeMap[name] = tree
+	delete(t.progMap, name)
+	return nil
+}
+
+// Delims returns the delimiters in effect at the start of the templates added
+// by Parse.
+func (t *Template) Delims() (left, right string) {
+	if t.LeftDelim == "" || t.RightDelim == "" {
+		return parse.DefaultLeftDelim, parse.DefaultRightDelim
+	}
+	return t.LeftDelim, t.RightDelim
+}
+
+// Tree returns the parsed tree of the named template, or nil if no template
+// with that name has been added. The returned tree is shared with the template
+// and should not be modified while the template is rendering.
+func (t *Template) Tree(name string) *ast.Tree {
+	return t.treeMap[name]
+}
+
+// RegisterHelper registers a helper under name. When a key of a single name
+// cannot be found in the data contexts, the helper registered under that name
+// is used as its value. Registering a nil helper removes the helper.
+//
+// Helpers transform the rendered text of sections, as in
+// {{#upper}}...{{/upper}}. The functions called with arguments in Handlebars
+// mode, as in {{formatDate created "short"}}, are registered by RegisterFunc
+// instead, and the built-in block helpers if, unless, each and with, held by
+// ast.Section.Block, cannot be registered.
+func (t *Template) RegisterHelper(name string, h Helper) {
+	if h == nil {
+		delete(t.helpers, name)
+		return
+	}
+	if t.helpers == nil {
+		t.helpers = make(map[string]Helper)
+	}
+	t.helpers[name] = h
+}
+
+// Helper returns the helper registered under name, or nil if there is none.
+func (t *Template) Helper(name string) Helper {
+	return t.helpers[name]
+}
+
+// errorType is the type of the error interface.
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// RegisterFunc registers a function under name, called by the helper calls
+// of templates parsed in Handlebars mode, such as {{formatDate created
+// "short"}}. The function must return a single value, or a value and an
+// error, which stops rendering when it is not nil. Arguments given as keys
+// are looked up in the context stack; an argument that is not found is
+// passed as the zero value of its parameter. Registering a nil function
+// removes the function.
+//
+// Handlebars names these functions helpers, but they are unrelated to the
+// section helpers of RegisterHelper, which transform the text of a section.
+func (t *Template) RegisterFunc(name string, fn interface{}) error {
+	if fn == nil {
+		delete(t.funcs, name)
+		return nil
+	}
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return fmt.Errorf("function %s is not a func: %T", name, fn)
+	}
+	typ := v.Type()
+	switch {
+	case typ.NumOut() == 1:
+	case typ.NumOut() == 2 && typ.Out(1) == errorType:
+	default:
+		return fmt.Errorf("function %s must return a value, or a value and an error", name)
+	}
+	if t.funcs == nil {
+		t.funcs = make(map[string]reflect.Value)
+	}
+	t.funcs[name] = v
+	return nil
+}
+
+// AddTree adds a tree to the template, making it available to render by name via
+// the Render method, or using a partial tag. Trees built in code are rendered the
+// same as parsed trees. An existing template with the same name is replaced.
+func (t *Template) AddTree(name string, tree *ast.Tree) error {
+	if tree == nil {
+		return fmt.Errorf("nil tree: %s", name)
+	}
+	t.treeMap[name] = tree
+	delete(t.progMap, name)
 	return nil
 }
 
 // Render applies a data context to a parsed template and returns the output as a string.
 // If an error occurs, the rendering process stops and the error is returned.
 func (t *Template) Render(name string, contexts ...interface{}) (string, error) {
-	tree, ok := t.treeMap[name]
-	if !ok {
-		return "", fmt.Errorf("template not found: %s", name)
+	r := t.newRenderer()
+	defer r.release()
+	buf, err := t.render(r, r.scratch[:0], name, contexts)
+	s := string(buf)
+	// keep the buffer for the next render, unless it is too large.
+	if cap(buf) <= maxPooledBuffer {
+		r.scratch = buf[:0]
 	}
+	return s, err
+}
 
-	// init new renderer
+// AppendRender applies a data context to a parsed template, appends the
+// output to dst and returns the extended buffer. Reusing the buffer across
+// calls avoids allocating the output of each render. If an error occurs, the
+// rendering process stops, and the output written before the error is
+// returned with the error.
+func (t *Template) AppendRender(dst []byte, name string, contexts ...interface{}) ([]byte, error) {
 	r := t.newRenderer()
+	defer r.release()
+	return t.render(r, dst, name, contexts)
+}
+
+// render renders the named template with r, appending the output to buf.
+func (t *Template) render(r *renderer, buf []byte, name string, contexts []interface{}) ([]byte, error) {
+	r.buf = buf
+	r.name = name
+	tree, ok := t.treeMap[name]
+	if !ok {
+		return r.buf, fmt.Errorf("template not found: %s", name)
+	}
 
 	// push contexts onto stack
 	for i := range contexts {
@@ -55,7 +224,11 @@ func (t *Template) Render(name string, contexts ...interface{}) (string, error)
 		r.push(context)
 	}
 
-	err := r.walk(tree.Name, tree)
-	s := r.String()
-	return s, err
+	var err error
+	if prog, ok := t.progMap[name]; ok {
+		err = prog(r)
+	} else {
+		err = r.walk(tree.Name, tree)
+	}
+	return r.buf, err
 }
@@ -6,200 +6,38 @@ package mustache_test
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
-	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/eriklott/mustache"
+	"github.com/eriklott/mustache/ast"
 )
 
-func TestRender_Spec(t *testing.T) {
-	specs := []string{
-		"comments.json",
-		"delimiters.json",
-		"interpolation.json",
-		"inverted.json",
-		"partials.json",
-		"sections.json",
-	}
-
-	type test struct {
-		Name        string            `json:"name"`
-		Data        interface{}       `json:"data"`
-		Expected    string            `json:"expected"`
-		Template    string            `json:"template"`
-		Description string            `json:"desc"`
-		Partials    map[string]string `json:"partials"`
-	}
-
-	type testSuite struct {
-		Tests []test `json:"tests"`
-	}
-
-	for _, spec := range specs {
-		t.Run(spec, func(t *testing.T) {
-			path := filepath.Join("spec", "specs", spec)
-			b, err := ioutil.ReadFile(path)
-			if err != nil {
-				t.Fatalf("failed to read spec file %s: %v", path, err)
-			}
-			suite := testSuite{}
-			err = json.Unmarshal(b, &suite)
-			if err != nil {
-				t.Fatalf("failed to unmarshal json spec %s, %v", path, err)
-			}
-
-			for _, tc := range suite.Tests {
-				t.Run(tc.Name, func(t *testing.T) {
-
-					tmpl := mustache.NewTemplate()
-
-					for k, v := range tc.Partials {
-						err := tmpl.Parse(k, v)
-						if err != nil {
-							t.Fatalf("failed to parse partial %s: %v", k, err)
-						}
-					}
-
-					err = tmpl.Parse("main", tc.Template)
-					if err != nil {
-						t.Fatalf("failed to parse template: %v", err)
-					}
-
-					got, err := tmpl.Render("main", tc.Data)
-					if err != nil {
-						t.Fatalf("failed to render template: %v", err)
-					}
-
-					// specs test against escaped char &quot; rather than go's &#34;
-					got = strings.Replace(got, "&#34;", "&quot;", -1)
-
-					if !reflect.DeepEqual(tc.Expected, got) {
-						t.Errorf("unexpected response, got:%s, want:%s", got, tc.Expected)
-					}
-				})
-			}
-		})
-	}
-}
-
+// TestRender_SpecLambda runs the tests of the lambdas spec module, which
+// TestRender_Spec only runs when the spec submodule is present.
 func TestRender_SpecLambda(t *testing.T) {
-
-	var testLambdaNum = 0
-
 	tt := []struct {
-		name        string
-		description string
-		data        interface{}
-		template    string
-		expected    string
+		name     string
+		data     map[string]interface{}
+		template string
+		expected string
 	}{
-		{
-			"Interpolation",
-			"A lambda's return value should be interpolated.",
-			map[string]interface{}{
-				"lambda": func() string { return "world" },
-			},
-			"Hello, {{lambda}}!",
-			"Hello, world!",
-		},
-		{
-			"Interpolation - Expansion",
-			"A lambda's return value should be parsed.",
-			map[string]interface{}{
-				"planet": "world",
-				"lambda": func() string { return "{{planet}}" },
-			},
-			"Hello, {{lambda}}!",
-			"Hello, world!",
-		},
-		{
-			"Interpolation - Alternate Delimiters",
-			"A lambda's return value should parse with the default delimiters.",
-			map[string]interface{}{
-				"planet": "world",
-				"lambda": func() string { return "|planet| => {{planet}}" },
-			},
-			"{{= | | =}}\nHello, (|&lambda|)!",
-			"Hello, (|planet| => world)!",
-		},
-		{
-			"Interpolation - Multiple Calls",
-			"Interpolated lambdas should not be cached.",
-			map[string]interface{}{
-				"lambda": func() string { testLambdaNum++; return fmt.Sprintf("%d", testLambdaNum) },
-			},
-			"{{lambda}} == {{{lambda}}} == {{lambda}}",
-			"1 == 2 == 3",
-		},
-		{
-			"Escaping",
-			"Lambda results should be appropriately escaped.",
-			map[string]interface{}{
-				"lambda": func() string { return ">" },
-			},
-			"<{{lambda}}{{{lambda}}}",
-			"<&gt;>",
-		},
-		{
-			"Section",
-			"Lambdas used for sections should receive the raw section string.",
-			map[string]interface{}{
-				"x": "Error!",
-				"lambda": func(text string) string {
-					if text == "{{x}}" {
-						return "yes"
-					} else {
-						return "no"
-					}
-				},
-			},
-			"<{{#lambda}}{{x}}{{/lambda}}>",
-			"<yes>",
-		},
-		{
-			"Section - Expansion",
-			"Lambdas used for sections should have their results parsed.",
-			map[string]interface{}{
-				"planet": "Earth",
-				"lambda": func(text string) string { return fmt.Sprintf("%s{{planet}}%s", text, text) },
-			},
-			"<{{#lambda}}-{{/lambda}}>",
-			"<-Earth->",
-		},
-		{
-			"Section - Alternate Delimiters",
-			"Lambdas used for sections should parse with the current delimiters.",
-			map[string]interface{}{
-				"planet": "Earth",
-				"lambda": func(text string) string { return fmt.Sprintf("%s{{planet}} => |planet|%s", text, text) },
-			},
-			"{{= | | =}}<|#lambda|-|/lambda|>",
-			"<-{{planet}} => Earth->",
-		},
-		{
-			"Section - Multiple Calls",
-			"Lambdas used for sections should not be cached.",
-			map[string]interface{}{
-				"planet": "Earth",
-				"lambda": func(text string) string { return "__" + text + "__" },
-			},
-			"{{#lambda}}FILE{{/lambda}} != {{#lambda}}LINE{{/lambda}}",
-			"__FILE__ != __LINE__",
-		},
-		{
-			"Inverted Section",
-			"Lambdas used for inverted sections should be considered truthy.",
-			map[string]interface{}{
-				"static": "static",
-				"lambda": func(text string) string { return "" },
-			},
-			"<{{^lambda}}{{static}}{{/lambda}}>",
-			"<>",
-		},
+		{"Interpolation", nil, "Hello, {{lambda}}!", "Hello, world!"},
+		{"Interpolation - Expansion", map[string]interface{}{"planet": "world"}, "Hello, {{lambda}}!", "Hello, world!"},
+		{"Interpolation - Alternate Delimiters", map[string]interface{}{"planet": "world"}, "{{= | | =}}\nHello, (|&lambda|)!", "Hello, (|planet| => world)!"},
+		{"Interpolation - Multiple Calls", nil, "{{lambda}} == {{{lambda}}} == {{lambda}}", "1 == 2 == 3"},
+		{"Escaping", nil, "<{{lambda}}{{{lambda}}}", "<&gt;>"},
+		{"Section", map[string]interface{}{"x": "Error!"}, "<{{#lambda}}{{x}}{{/lambda}}>", "<yes>"},
+		{"Section - Expansion", map[string]interface{}{"planet": "Earth"}, "<{{#lambda}}-{{/lambda}}>", "<-Earth->"},
+		{"Section - Alternate Delimiters", map[string]interface{}{"planet": "Earth"}, "{{= | | =}}<|#lambda|-|/lambda|>", "<-{{planet}} => Earth->"},
+		{"Section - Multiple Calls", map[string]interface{}{"planet": "Earth"}, "{{#lambda}}FILE{{/lambda}} != {{#lambda}}LINE{{/lambda}}", "__FILE__ != __LINE__"},
+		{"Inverted Section", map[string]interface{}{"static": "static"}, "<{{^lambda}}{{static}}{{/lambda}}>", "<>"},
 	}
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
@@ -209,7 +47,11 @@ func TestRender_SpecLambda(t *testing.T) {
 				t.Fatalf("failed to parse template: %v", err)
 			}
 
-			got, err := tmpl.Render("main", tc.data)
+			data := map[string]interface{}{"lambda": specLambdas[tc.name]()}
+			for k, v := range tc.data {
+				data[k] = v
+			}
+			got, err := tmpl.Render("main", data)
 			if err != nil {
 				t.Fatalf("failed to render template: %v", err)
 			}
@@ -258,6 +100,24 @@ func TestRender_Misc(t *testing.T) {
 			},
 			want: "Hello World!",
 		},
+		{
+			name: "Keys",
+			desc: "Keys may contain any non-whitespace characters other than dots",
+			text: "{{first_name}} {{user-id}} {{naïve}}",
+			data: map[string]interface{}{"first_name": "Ann", "user-id": 7, "naïve": "yes"},
+			want: "Ann 7 yes",
+		},
+		{
+			name: "Quoted Keys",
+			desc: "Quoted names may contain dots and whitespace",
+			text: `{{["first name"]}} {{["a.b"].c}} {{#a["b c"]}}{{d}}{{/a.["b c"]}}`,
+			data: map[string]interface{}{
+				"first name": "Ann",
+				"a.b":        map[string]string{"c": "C"},
+				"a":          map[string]interface{}{"b c": map[string]string{"d": "D"}},
+			},
+			want: "Ann C D",
+		},
 		{
 			name:     "Recursive Partial",
 			desc:     "Infinitely recursive partials will return an error",
@@ -266,6 +126,14 @@ func TestRender_Misc(t *testing.T) {
 			data:     nil,
 			err:      "exceeded maximum partial depth: 100000",
 		},
+		{
+			name:     "Recursive Partial - Helper",
+			desc:     "Partials recursing through helper sections will return an error",
+			text:     "{{>partial}}",
+			partials: map[string]string{"partial": "{{#wrap}}{{>partial}}{{/wrap}}"},
+			data:     map[string]interface{}{"wrap": mustache.Helper(func(s string) string { return s })},
+			err:      "exceeded maximum partial depth: 100000",
+		},
 	}
 
 	for _, tc := range tt {
@@ -385,6 +253,618 @@ func TestRender_ContextMiss(t *testing.T) {
 	}
 }
 
+func TestParse_Limits(t *testing.T) {
+	tmpl := mustache.NewTemplate()
+	tmpl.ParseOptions.MaxDepth = 1
+
+	err := tmpl.Parse("main", "{{#a}}{{#a}}{{/a}}{{/a}}")
+	var limitErr *mustache.LimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("expected *mustache.LimitError, got: %v", err)
+	}
+	if got, want := err.Error(), "main:1:7: exceeded maximum section depth: 1"; got != want {
+		t.Errorf("unexpected error, got:%s, want:%s", got, want)
+	}
+}
+
+func TestRender_TrimMarkers(t *testing.T) {
+	tt := []struct {
+		name     string
+		text     string
+		partials map[string]string
+		want     string
+	}{
+		{"Variable", "a \n {{~b~}} \n c", nil, "aBc"},
+		{"Section", "<ul>\n  {{~#list~}}\n    <li>{{.}}</li>\n  {{~/list~}}\n</ul>", nil, "<ul><li>1</li><li>2</li></ul>"},
+		{"Unescaped", "[ {{~{html}~}} ]", nil, "[<i>]"},
+		{"SetDelims", "{{=<% %>=}}a <%~b%> c", nil, "aB c"},
+		{"Partial", "  {{>p}}\nz", map[string]string{"p": "x\n  {{~#list~}}\n{{.}}\n{{~/list}}\ny\n"}, "  x12\n  y\nz"},
+		{"Disabled", "{{ b }}", nil, "B"},
+	}
+	data := map[string]interface{}{
+		"b":    "B",
+		"html": "<i>",
+		"list": []int{1, 2},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl := mustache.NewTemplate()
+			tmpl.ParseOptions.TrimMarkers = true
+			if err := tmpl.Parse("main", tc.text); err != nil {
+				t.Fatalf("failed to parse template: %v", err)
+			}
+			for key, partial := range tc.partials {
+				if err := tmpl.Parse(key, partial); err != nil {
+					t.Fatalf("failed to parse partial: %v", err)
+				}
+			}
+
+			got, err := tmpl.Render("main", data)
+			if err != nil {
+				t.Fatalf("failed to render template: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("unexpected response, got:%q, want:%q", got, tc.want)
+			}
+
+			if err := tmpl.Compile(); err != nil {
+				t.Fatalf("failed to compile template: %v", err)
+			}
+			got, err = tmpl.Render("main", data)
+			if err != nil {
+				t.Fatalf("failed to render compiled template: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("unexpected compiled response, got:%q, want:%q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRender_PartialPaths(t *testing.T) {
+	tmpl := mustache.NewTemplate()
+	tmpl.ContextErrorsEnabled = true
+	templates := map[string]string{
+		"emails/welcome": "{{>./header}}|{{>user-card}}|{{>../layout.html}}",
+		"emails/header":  "[{{>./footer}}]",
+		"emails/footer":  "footer",
+		"user-card":      "{{name}}",
+		"layout.html":    "layout",
+		"main":           "{{>emails/welcome}}",
+	}
+	for name, text := range templates {
+		if err := tmpl.Parse(name, text); err != nil {
+			t.Fatalf("failed to parse %s: %v", name, err)
+		}
+	}
+	data := map[string]string{"name": "Ann"}
+	want := "[footer]|Ann|layout"
+
+	got, err := tmpl.Render("main", data)
+	if err != nil {
+		t.Fatalf("failed to render template: %v", err)
+	}
+	if got != want {
+		t.Errorf("unexpected response, got:%q, want:%q", got, want)
+	}
+	if err := tmpl.Compile(); err != nil {
+		t.Fatalf("failed to compile template: %v", err)
+	}
+	got, err = tmpl.Render("main", data)
+	if err != nil {
+		t.Fatalf("failed to render compiled template: %v", err)
+	}
+	if got != want {
+		t.Errorf("unexpected compiled response, got:%q, want:%q", got, want)
+	}
+	if err := tmpl.Validate(); err != nil {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+
+	if err := tmpl.Parse("main", "{{>./missing}}"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpl.Render("main", data); err == nil || err.Error() != "main:1:1: partial not found: ./missing" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestTemplate_RegisterHelper(t *testing.T) {
+	tmpl := mustache.NewTemplate()
+	tmpl.ContextErrorsEnabled = true
+	tmpl.RegisterHelper("upper", strings.ToUpper)
+	tmpl.RegisterHelper("wrap", func(s string) string { return "<" + s + ">" })
+	templates := map[string]string{
+		"main":  "{{#upper}}Hi {{name}}{{#items}} {{.}}{{/items}}{{/upper}}|{{#wrap}}{{>inner}}{{/wrap}}|{{^upper}}no{{/upper}}|{{#shadow}}{{name}}{{/shadow}}|{{#local}}{{name}}{{/local}}",
+		"inner": "{{#upper}}{{name}}{{/upper}}",
+	}
+	for name, text := range templates {
+		if err := tmpl.Parse(name, text); err != nil {
+			t.Fatalf("failed to parse %s: %v", name, err)
+		}
+	}
+	// data shadows helpers, and helpers given in the data are passed the
+	// rendered text, whose values are escaped. The results of helpers are
+	// written as they are.
+	data := map[string]interface{}{
+		"name":   "a&b",
+		"items":  []int{1, 2},
+		"shadow": true,
+		"local":  mustache.Helper(func(s string) string { return "[" + s + "]" }),
+	}
+	want := "HI A&AMP;B 1 2|<A&AMP;B>||a&amp;b|[a&amp;b]"
+
+	got, err := tmpl.Render("main", data)
+	if err != nil {
+		t.Fatalf("failed to render template: %v", err)
+	}
+	if got != want {
+		t.Errorf("unexpected response, got:%q, want:%q", got, want)
+	}
+	if err := tmpl.Compile(); err != nil {
+		t.Fatalf("failed to compile template: %v", err)
+	}
+	got, err = tmpl.Render("main", data)
+	if err != nil {
+		t.Fatalf("failed to render compiled template: %v", err)
+	}
+	if got != want {
+		t.Errorf("unexpected compiled response, got:%q, want:%q", got, want)
+	}
+
+	// helpers are only found for keys of a single name.
+	if err := tmpl.Parse("main", "{{upper.x}}"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpl.Render("main", data); err == nil || err.Error() != "main:1:1: cannot find value upper.x in context" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	tmpl.RegisterHelper("upper", nil)
+	if tmpl.Helper("upper") != nil {
+		t.Error("unexpected helper after removal")
+	}
+	if err := tmpl.Parse("main", "{{#upper}}x{{/upper}}"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpl.Render("main", data); err == nil || err.Error() != "main:1:1: cannot find value upper in context" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRender_Lambda(t *testing.T) {
+	tmpl := mustache.NewTemplate()
+	tmpl.ContextErrorsEnabled = true
+	if err := tmpl.Parse("main", "{{=<% %>=}}<%#items%><%#wrap%> <%name%> <%/wrap%><%/items%>|<%^wrap%>no<%/wrap%>|<%#fail%>x<%/fail%>"); err != nil {
+		t.Fatalf("failed to parse template: %v", err)
+	}
+	data := map[string]interface{}{
+		"items": []map[string]string{{"name": "a&b"}, {"name": "c"}},
+		"wrap": mustache.Lambda(func(text string, render mustache.RenderFunc) (string, error) {
+			s, err := render("[{{name}}]")
+			return "<" + text + s + ">", err
+		}),
+		"fail": mustache.Lambda(func(text string, render mustache.RenderFunc) (string, error) {
+			return render("{{missing}}")
+		}),
+	}
+	want := "< <%name%> [a&amp;b]>< <%name%> [c]>||"
+	wantErr := "main:1:82: lambda:1:1: cannot find value missing in context"
+
+	for _, compile := range []bool{false, true} {
+		if compile {
+			if err := tmpl.Compile(); err != nil {
+				t.Fatalf("failed to compile template: %v", err)
+			}
+		}
+		got, err := tmpl.Render("main", data)
+		if err == nil || err.Error() != wantErr {
+			t.Errorf("unexpected error, compiled:%t, got:%v, want:%s", compile, err, wantErr)
+		}
+		if got != want {
+			t.Errorf("unexpected response, compiled:%t, got:%q, want:%q", compile, got, want)
+		}
+	}
+}
+
+func TestRender_Fallbacks(t *testing.T) {
+	var nilName *string
+	data := map[string]interface{}{
+		"name":     "Ann",
+		"nickname": nil,
+		"ptr":      nilName,
+		"empty":    "",
+		"zero":     0,
+		"off":      false,
+		"user":     map[string]string{"first": "Bob"},
+	}
+	tt := []struct {
+		name   string
+		text   string
+		strict bool
+		want   string
+		err    string
+	}{
+		{name: "Missing", text: "{{nick ?? name}}", want: "Ann"},
+		{name: "Nil", text: "{{nickname ?? name}}", want: "Ann"},
+		{name: "NilPointer", text: "{{ptr | default:name}}", want: "Ann"},
+		{name: "Present", text: `{{name ?? "Guest"}}`, want: "Ann"},
+		{name: "EmptyString", text: "{{empty ?? name}}", want: "Ann"},
+		{name: "False", text: `{{off ?? "no"}}`, want: "no"},
+		{name: "Zero", text: "{{zero | default:name}}", want: "Ann"},
+		{name: "AllFalsy", text: "{{off ?? empty ?? zero}}", want: "0"},
+		{name: "Chain", text: "{{a ?? b ?? user.first ?? name}}", want: "Bob"},
+		{name: "String", text: `{{nick ?? "<Guest>"}} {{{nick | default:"<Guest>"}}} {{&nick ?? ""}}`, want: "&lt;Guest&gt; <Guest> "},
+		{name: "Section", text: `{{#user}}{{last ?? first}}{{/user}}`, want: "Bob"},
+		{name: "AllMissing", text: "{{a ?? b}}", want: ""},
+		{name: "StrictString", text: `{{a ?? b ?? "x"}}`, strict: true, want: "x"},
+		{name: "StrictFallback", text: "{{a ?? name}}", strict: true, want: "Ann"},
+		{name: "StrictNil", text: "{{nickname ?? b}}", strict: true, want: ""},
+		{name: "StrictMissing", text: "{{a ?? user.last}}", strict: true, err: "main:1:1: cannot find value a ?? user.last in context"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, compile := range []bool{false, true} {
+				tmpl := mustache.NewTemplate()
+				tmpl.ContextErrorsEnabled = tc.strict
+				if err := tmpl.Parse("main", tc.text); err != nil {
+					t.Fatalf("failed to parse template: %v", err)
+				}
+				if compile {
+					if err := tmpl.Compile(); err != nil {
+						t.Fatalf("failed to compile template: %v", err)
+					}
+				}
+				got, err := tmpl.Render("main", data)
+				var gotErr string
+				if err != nil {
+					gotErr = err.Error()
+				}
+				if gotErr != tc.err {
+					t.Errorf("unexpected error, compiled:%t, got:%s, want:%s", compile, gotErr, tc.err)
+				}
+				if got != tc.want {
+					t.Errorf("unexpected response, compiled:%t, got:%q, want:%q", compile, got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRender_FallbackLambdaCalls(t *testing.T) {
+	var calls, emptyCalls int
+	data := map[string]interface{}{
+		"name":  "Ann",
+		"empty": "",
+		"count": func() string {
+			calls++
+			return "<{{name}}>"
+		},
+		"none": func() string {
+			emptyCalls++
+			return ""
+		},
+	}
+	text := `{{count ?? "x"}}|{{empty ?? count}}|{{&count ?? name}}|{{none ?? name}}`
+	for _, compile := range []bool{false, true} {
+		calls, emptyCalls = 0, 0
+		tmpl := mustache.NewTemplate()
+		if err := tmpl.Parse("main", text); err != nil {
+			t.Fatalf("failed to parse template: %v", err)
+		}
+		if compile {
+			if err := tmpl.Compile(); err != nil {
+				t.Fatalf("failed to compile template: %v", err)
+			}
+		}
+		got, err := tmpl.Render("main", data)
+		if err != nil {
+			t.Fatalf("failed to render template: %v", err)
+		}
+		if want := "&lt;Ann&gt;|&lt;Ann&gt;|<Ann>|Ann"; got != want {
+			t.Errorf("unexpected response, compiled:%t, got:%q, want:%q", compile, got, want)
+		}
+		if calls != 3 || emptyCalls != 1 {
+			t.Errorf("unexpected lambda calls, compiled:%t, got:%d and %d, want:3 and 1", compile, calls, emptyCalls)
+		}
+	}
+}
+
+func TestRender_Handlebars(t *testing.T) {
+	type user struct {
+		Name    string
+		Created time.Time
+	}
+	data := map[string]interface{}{
+		"ok":     true,
+		"no":     false,
+		"items":  []string{"a", "b", "c"},
+		"none":   []string{},
+		"scores": map[string]int{"b": 2, "a": 1},
+		"user":   &user{Name: "Ann", Created: time.Date(2019, 3, 4, 0, 0, 0, 0, time.UTC)},
+		"count":  3,
+		"html":   "<b>",
+	}
+	tt := []struct {
+		name   string
+		text   string
+		strict bool
+		want   string
+		err    string
+	}{
+		{name: "If", text: "{{#if ok}}yes{{else}}no{{/if}}|{{#if no}}yes{{else}}no{{/if}}|{{#if none}}yes{{/if}}", want: "yes|no|"},
+		{name: "Unless", text: "{{#unless no}}yes{{else}}no{{/unless}}|{{#unless ok}}yes{{/unless}}", want: "yes|"},
+		{name: "IfContext", text: "{{#if user}}{{Name}}{{/if}}", want: ""},
+		{name: "With", text: "{{#with user}}{{Name}}{{/with}}|{{#with missing}}x{{else}}{{count}}{{/with}}", want: "Ann|3"},
+		{name: "Each", text: "{{#each items}}{{@index}}:{{this}}{{#unless @last}},{{/unless}}{{/each}}", want: "0:a,1:b,2:c"},
+		{name: "EachFirst", text: "{{#each items}}{{#if @first}}[{{/if}}{{.}}{{/each}}", want: "[abc"},
+		{name: "EachMap", text: "{{#each scores}}{{@key}}={{this}};{{/each}}", want: "a=1;b=2;"},
+		{name: "EachElse", text: "{{#each none}}x{{else}}empty{{/each}}|{{#each count}}x{{else}}not a list{{/each}}", want: "empty|not a list"},
+		{name: "EachOuter", text: "{{#each items}}{{count}}{{/each}}", want: "333"},
+		{name: "SectionElse", text: "{{#none}}x{{else}}y{{/none}}|{{^ok}}x{{else}}y{{/ok}}|{{#items}}{{.}}{{else}}y{{/items}}", want: "y|y|abc"},
+		{name: "Standalone", text: "{{#if ok}}\n  yes\n{{else}}\n  no\n{{/if}}\n", want: "  yes\n"},
+		{name: "This", text: "{{#with user}}{{this.Name}}{{/with}}", want: "Ann"},
+		{name: "Call", text: `{{formatDate user.Created "short"}} {{add count 2}} {{add 1.5 2}} {{join "-" "a" user.Name}} {{join ","}}`, want: "2019-03-04 5 3.5 a-Ann "},
+		{name: "CallEscape", text: `{{wrap html}} {{{wrap html}}} {{&wrap html}}`, want: "[&lt;b&gt;] [<b>] [<b>]"},
+		{name: "CallMissing", text: `{{add missing 2}}`, want: "2"},
+		{name: "CallStrict", text: `{{add missing 2}}`, strict: true, err: "main:1:1: cannot find value missing in context"},
+		{name: "CallError", text: `{{formatDate user.Created "long"}}`, err: "main:1:1: formatDate: unknown format long"},
+		{name: "CallUnknown", text: `{{upper html}}`, err: "main:1:1: function not registered: upper"},
+		{name: "CallArgCount", text: `{{add 1}}`, err: "main:1:1: wrong number of arguments for add: got 1, want 2"},
+		{name: "CallArgType", text: `{{add items 1}}`, err: "main:1:1: wrong type for argument 1 of add: got []string, want float64"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, compile := range []bool{false, true} {
+				tmpl := mustache.NewTemplate()
+				tmpl.ContextErrorsEnabled = tc.strict
+				tmpl.ParseOptions.Handlebars = true
+				tmpl.RegisterFunc("formatDate", func(t time.Time, format string) (string, error) {
+					if format != "short" {
+						return "", fmt.Errorf("unknown format %s", format)
+					}
+					return t.Format("2006-01-02"), nil
+				})
+				tmpl.RegisterFunc("add", func(a, b float64) float64 { return a + b })
+				tmpl.RegisterFunc("join", func(sep string, items ...string) string { return strings.Join(items, sep) })
+				tmpl.RegisterFunc("wrap", func(s string) string { return "[" + s + "]" })
+				if err := tmpl.Parse("main", tc.text); err != nil {
+					t.Fatalf("failed to parse template: %v", err)
+				}
+				if compile {
+					if err := tmpl.Compile(); err != nil {
+						t.Fatalf("failed to compile template: %v", err)
+					}
+				}
+				got, err := tmpl.Render("main", data)
+				var gotErr string
+				if err != nil {
+					gotErr = err.Error()
+				}
+				if gotErr != tc.err {
+					t.Errorf("unexpected error, compiled:%t, got:%s, want:%s", compile, gotErr, tc.err)
+				}
+				if got != tc.want {
+					t.Errorf("unexpected response, compiled:%t, got:%q, want:%q", compile, got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestTemplate_RegisterFunc(t *testing.T) {
+	tmpl := mustache.NewTemplate()
+	tt := []struct {
+		fn  interface{}
+		err string
+	}{
+		{fn: strings.ToUpper},
+		{fn: strconv.Atoi},
+		{fn: "upper", err: "function f is not a func: string"},
+		{fn: func() {}, err: "function f must return a value, or a value and an error"},
+		{fn: func() (int, int) { return 0, 0 }, err: "function f must return a value, or a value and an error"},
+	}
+	for _, tc := range tt {
+		err := tmpl.RegisterFunc("f", tc.fn)
+		var gotErr string
+		if err != nil {
+			gotErr = err.Error()
+		}
+		if gotErr != tc.err {
+			t.Errorf("unexpected error for %T, got:%s, want:%s", tc.fn, gotErr, tc.err)
+		}
+	}
+}
+
+func TestTemplate_DelimsEscape(t *testing.T) {
+	tmpl := mustache.NewTemplate()
+	tmpl.LeftDelim, tmpl.RightDelim = "<%", "%>"
+	tmpl.Escape = strings.ToUpper
+	if err := tmpl.Parse("main", "<%a%> <%{a}%> <%n%> {{a}} <%>p%>"); err != nil {
+		t.Fatalf("failed to parse template: %v", err)
+	}
+	if err := tmpl.Parse("p", "<%#list%><%.%><%/list%>"); err != nil {
+		t.Fatalf("failed to parse partial: %v", err)
+	}
+	data := map[string]interface{}{"a": "x<y", "n": 1.5, "list": []string{"b", "c"}}
+
+	for _, compiled := range []bool{false, true} {
+		if compiled {
+			if err := tmpl.Compile(); err != nil {
+				t.Fatalf("failed to compile template: %v", err)
+			}
+		}
+		got, err := tmpl.Render("main", data)
+		if err != nil {
+			t.Fatalf("failed to render template: %v", err)
+		}
+		if want := "X<Y x<y 1.5 {{a}} BC"; got != want {
+			t.Errorf("unexpected response, got:%s, want:%s", got, want)
+		}
+	}
+}
+
+func TestTemplate_AddTree(t *testing.T) {
+	tmpl := mustache.NewTemplate()
+	err := tmpl.Parse("main", "Hello {{>name}}!")
+	if err != nil {
+		t.Fatalf("failed to parse template: %v", err)
+	}
+
+	tree := &ast.Tree{Name: "name"}
+	tree.Add(&ast.Variable{Key: []string{"name"}})
+	err = tmpl.AddTree("name", tree)
+	if err != nil {
+		t.Fatalf("failed to add tree: %v", err)
+	}
+	if tmpl.Tree("name") != tree {
+		t.Errorf("Tree() did not return the added tree")
+	}
+	if tmpl.Tree("missing") != nil {
+		t.Errorf("Tree() returned a tree for a missing template")
+	}
+
+	got, err := tmpl.Render("main", map[string]string{"name": "World"})
+	if err != nil {
+		t.Fatalf("failed to render template: %v", err)
+	}
+	if want := "Hello World!"; got != want {
+		t.Errorf("unexpected response, got:%s, want:%s", got, want)
+	}
+}
+
+func TestTemplate_AppendRender(t *testing.T) {
+	tmpl := mustache.NewTemplate()
+	if err := tmpl.Parse("main", "{{a}} & {{{b}}}{{#c}}<{{.}}>{{/c}}\n  {{>p}}\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpl.Parse("p", "{{a}}\n{{a}}\n"); err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]interface{}{"a": "<'\"&>", "b": "<b>", "c": []float64{1.5, -2}}
+
+	want, err := tmpl.Render("main", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := []byte("prefix:")
+	buf, err = tmpl.AppendRender(buf, "main", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf); got != "prefix:"+want {
+		t.Errorf("unexpected response, got:%q, want:%q", got, "prefix:"+want)
+	}
+
+	buf, err = tmpl.AppendRender(buf[:0], "missing")
+	if err == nil || len(buf) != 0 {
+		t.Errorf("unexpected response, got:%q, %v, want: template not found", buf, err)
+	}
+}
+
+// TestRender_CaptureIndent checks that a partial rendered within captured
+// output, such as the output of a lambda, does not change the indentation
+// of the partial enclosing the lambda.
+func TestRender_CaptureIndent(t *testing.T) {
+	tmpl := mustache.NewTemplate()
+	templates := map[string]string{
+		"main":  "  {{>outer}}\n",
+		"outer": "{{#wrap}}{{/wrap}}\nc\n",
+		"inner": "b",
+	}
+	for name, text := range templates {
+		if err := tmpl.Parse(name, text); err != nil {
+			t.Fatalf("failed to parse %s: %v", name, err)
+		}
+	}
+	data := map[string]interface{}{
+		"wrap": mustache.Lambda(func(text string, render mustache.RenderFunc) (string, error) {
+			return render("\t{{>inner}}\n")
+		}),
+	}
+	want := "  \tb\n  c\n"
+
+	for _, compile := range []bool{false, true} {
+		if compile {
+			if err := tmpl.Compile(); err != nil {
+				t.Fatalf("failed to compile template: %v", err)
+			}
+		}
+		got, err := tmpl.Render("main", data)
+		if err != nil {
+			t.Fatalf("failed to render template: %v", err)
+		}
+		if got != want {
+			t.Errorf("unexpected response, compiled:%t, got:%q, want:%q", compile, got, want)
+		}
+	}
+}
+
+// TestRender_Reuse checks that pooled renderers do not carry state, such as
+// the indent of a partial that failed, into the next render.
+func TestRender_Reuse(t *testing.T) {
+	tmpl := mustache.NewTemplate()
+	tmpl.ContextErrorsEnabled = true
+	if err := tmpl.Parse("main", "  {{>p}}"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpl.Parse("p", "{{a}}\n{{b}}"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		_, err := tmpl.Render("main", map[string]string{"a": "a"})
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		got, err := tmpl.Render("main", map[string]string{"a": "a", "b": "b"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "  a\n  b"; got != want {
+			t.Fatalf("unexpected response, got:%q, want:%q", got, want)
+		}
+	}
+}
+
+func TestRender_Parallel(t *testing.T) {
+	tmpl := mustache.NewTemplate()
+	if err := tmpl.Parse("main", "{{#list}}{{.}},{{/list}}{{#lambda}}{{n}}{{/lambda}}"); err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error)
+	for i := 0; i < 8; i++ {
+		go func(n int) {
+			data := map[string]interface{}{
+				"n":      n,
+				"list":   []int{n, n},
+				"lambda": func(text string) string { return "[" + text + "]" },
+			}
+			want := fmt.Sprintf("%d,%d,[%d]", n, n, n)
+			for j := 0; j < 100; j++ {
+				got, err := tmpl.Render("main", data)
+				if err == nil && got != want {
+					err = fmt.Errorf("unexpected response, got:%s, want:%s", got, want)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+			errs <- nil
+		}(i)
+	}
+	for i := 0; i < 8; i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
 func BenchmarkRender(b *testing.B) {
 	tmplBytes, err := ioutil.ReadFile("testdata/template.mustache")
 	if err != nil {
@@ -398,7 +878,9 @@ func BenchmarkRender(b *testing.B) {
 	}
 
 	var data interface{}
-	json.Unmarshal(dataBytes, data)
+	if err := json.Unmarshal(dataBytes, &data); err != nil {
+		b.Fatal(err)
+	}
 
 	for n := 0; n < b.N; n++ {
 		t := mustache.NewTemplate()
@@ -0,0 +1,469 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package parse builds syntax trees from mustache templates.
+package parse
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/eriklott/mustache/ast"
+	"github.com/eriklott/mustache/internal/token"
+)
+
+// Default mustache delimeters
+const (
+	DefaultLeftDelim  = "{{"
+	DefaultRightDelim = "}}"
+)
+
+// Options configures the parsing of a template. The limits guard against
+// untrusted template sources; a zero value for any limit disables it.
+//
+// When TrimMarkers is set, a ~ following the left delimiter of a tag, as in
+// {{~name}}, removes the whitespace, including line endings, preceding the
+// tag, and a ~ preceding the right delimiter, as in {{#section~}}, removes
+// the whitespace following it. Tags with trim markers are never standalone.
+//
+// When Handlebars is set, templates may use the block helpers of Handlebars,
+// {{#if cond}}, {{#unless cond}}, {{#each items}} and {{#with user}}, closed
+// by {{/if}} and so on, an {{else}} tag within any section, and helper calls
+// with arguments, such as {{formatDate created "short"}}. The key this
+// denotes the current context, like a single dot.
+type Options struct {
+	MaxSourceSize int  // maximum size of the template source, in bytes
+	MaxDepth      int  // maximum nesting depth of sections
+	MaxTags       int  // maximum number of tags in the template
+	MaxKeyLength  int  // maximum length of a tag key, in bytes
+	TrimMarkers   bool // enable trim markers
+	Handlebars    bool // enable Handlebars compatibility
+}
+
+// Limit identifies one of the limits configured in Options.
+type Limit int
+
+// Parse limits
+const (
+	SourceSize Limit = iota
+	Depth
+	Tags
+	KeyLength
+)
+
+func (l Limit) String() string {
+	switch l {
+	case SourceSize:
+		return "source size"
+	case Depth:
+		return "section depth"
+	case Tags:
+		return "tag count"
+	case KeyLength:
+		return "key length"
+	default:
+		return "limit(" + strconv.Itoa(int(l)) + ")"
+	}
+}
+
+// LimitError is returned when a template exceeds one of the limits configured
+// in Options.
+type LimitError struct {
+	Name   string // name of the template
+	Line   int    // line where the limit was exceeded
+	Column int    // column where the limit was exceeded
+	Limit  Limit  // the limit that was exceeded
+	Max    int    // the configured maximum
+}
+
+func (e *LimitError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Name)
+	b.WriteString(":")
+	b.WriteString(strconv.Itoa(e.Line))
+	b.WriteString(":")
+	b.WriteString(strconv.Itoa(e.Column))
+	b.WriteString(": exceeded maximum ")
+	b.WriteString(e.Limit.String())
+	b.WriteString(": ")
+	b.WriteString(strconv.Itoa(e.Max))
+	return b.String()
+}
+
+// parser contains the state for the parsing process.
+type parser struct {
+	name string
+	src  string
+	opts Options
+	s    *token.Scanner
+	tags int
+
+	texts    []*ast.Text // text nodes since the last tag
+	trimNext bool        // trim the whitespace of the following text nodes
+	cursor   ast.Position
+}
+
+// Parse transforms a template string into a tree of nodes. If an error is
+// encountered, parsing stops and the error is returned: an *Error for syntax
+// errors, or a *LimitError when a limit of opts is exceeded.
+func Parse(name, src, leftDelim, rightDelim string, opts Options) (*ast.Tree, error) {
+	p := &parser{
+		name: name,
+		src:  src,
+		opts: opts,
+		s:    token.NewScanner(name, src, leftDelim, rightDelim),
+	}
+	p.s.TrimMarkers = opts.TrimMarkers
+	p.s.Handlebars = opts.Handlebars
+	p.cursor = ast.Position{Line: 1, Column: 1, Char: 1, UTF16: 1}
+	tree := &ast.Tree{
+		Name:   name,
+		LDelim: leftDelim,
+		RDelim: rightDelim,
+	}
+	if opts.MaxSourceSize > 0 && len(src) > opts.MaxSourceSize {
+		return tree, p.limitError(1, 1, SourceSize, opts.MaxSourceSize)
+	}
+	err := p.parse(tree)
+	return tree, err
+}
+
+// parentNode represents an element that can add an ast.Node (mainly a ast.Tree or ast.Section)
+type parentNode interface {
+	Add(ast.Node)
+}
+
+// openSection is a section whose closing tag has not yet been parsed.
+type openSection struct {
+	node    *ast.Section
+	tok     token.Token
+	hasElse bool
+}
+
+// parse parses the template string, constructing nodes and adding them to
+// the tree. Open sections are tracked on an explicit stack rather than by
+// recursion, so deeply nested templates cannot exhaust the goroutine stack.
+// If an error is encountered, parse stops and the error is returned.
+func (p *parser) parse(tree *ast.Tree) error {
+	var stack []openSection
+	var parent parentNode = tree
+	for {
+		t, err := p.s.Next()
+		if err == io.EOF {
+			// eof reached while parsing the inside of a section.
+			if len(stack) > 0 {
+				open := stack[len(stack)-1].tok
+				return p.error(open.Line, open.Column, "unclosed section tag: "+open.Text)
+			}
+
+			// eof reached normally. parsing is complete.
+			return nil
+		}
+		if e, ok := err.(*token.Error); ok {
+			return &Error{Name: e.Name, Line: e.Line, Column: e.Column, Msg: e.Msg}
+		}
+		if err != nil {
+			return err
+		}
+
+		if t.Type != token.TEXT && t.Type != token.TEXT_EOL {
+			err := p.checkTag(t)
+			if err != nil {
+				return err
+			}
+			p.trim(t)
+		}
+
+		switch t.Type {
+		case token.TEXT, token.TEXT_EOL:
+			text := &ast.Text{
+				Text:      t.Text,
+				EndOfLine: t.Type == token.TEXT_EOL,
+				Range:     p.tokenRange(t),
+			}
+			if p.trimNext {
+				trimmed := strings.TrimLeft(text.Text, whitespace)
+				text.TrimmedLeft = text.Text[:len(text.Text)-len(trimmed)]
+				text.Text = trimmed
+				text.EndOfLine = text.EndOfLine && text.Text != ""
+				p.trimNext = text.Text == ""
+			}
+			p.texts = append(p.texts, text)
+			parent.Add(text)
+
+		case token.VARIABLE, token.UNESCAPED_VARIABLE, token.UNESCAPED_VARIABLE_SYM:
+			parent.Add(p.variable(t))
+
+		case token.SECTION, token.INVERTED_SECTION:
+			if p.opts.MaxDepth > 0 && len(stack) >= p.opts.MaxDepth {
+				return p.limitError(t.Line, t.Column, Depth, p.opts.MaxDepth)
+			}
+			node := &ast.Section{
+				Tag:      p.tag(t),
+				Inverted: t.Type == token.INVERTED_SECTION,
+				LDelim:   p.s.LeftDelim(),
+				RDelim:   p.s.RightDelim(),
+				Line:     t.Line,
+				Column:   t.Column,
+			}
+			key := t.Text
+			if p.opts.Handlebars && !node.Inverted {
+				node.Block, key = token.SplitBlock(key)
+			}
+			node.Key = p.this(splitKey(key))
+			parent.Add(node)
+			stack = append(stack, openSection{node: node, tok: t})
+			parent = node
+
+		case token.ELSE:
+			if len(stack) == 0 || stack[len(stack)-1].hasElse {
+				return p.error(t.Line, t.Column, "unexpected else")
+			}
+			stack[len(stack)-1].hasElse = true
+			parent.Add(&ast.Else{
+				Tag:    p.tag(t),
+				Line:   t.Line,
+				Column: t.Column,
+			})
+
+		case token.SECTION_END:
+			if len(stack) == 0 || !closes(stack[len(stack)-1], t.Text) {
+				return p.error(t.Line, t.Column, "unexpected section closing tag: "+t.Text)
+			}
+			open := stack[len(stack)-1]
+			open.node.Text = p.src[open.tok.EndOffset:t.Offset]
+			open.node.Close = p.tag(t)
+			stack = stack[:len(stack)-1]
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1].node
+			} else {
+				parent = tree
+			}
+
+		case token.PARTIAL:
+			parent.Add(&ast.Partial{
+				Tag:    p.tag(t),
+				Key:    t.Text,
+				Line:   t.Line,
+				Column: t.Column,
+			})
+
+		case token.COMMENT:
+			parent.Add(&ast.Comment{
+				Tag:    p.tag(t),
+				Text:   t.Text,
+				Line:   t.Line,
+				Column: t.Column,
+			})
+
+		case token.SET_DELIMETERS:
+			parent.Add(&ast.SetDelims{
+				Tag:    p.tag(t),
+				LDelim: p.s.LeftDelim(),
+				RDelim: p.s.RightDelim(),
+				Line:   t.Line,
+				Column: t.Column,
+			})
+		}
+	}
+}
+
+// whitespace is the whitespace removed by trim markers.
+const whitespace = " \t\r\n"
+
+// trim applies the trim markers of a scanned tag. A left trim marker removes
+// trailing whitespace from the text nodes preceding the tag, up to the first
+// text that is not entirely whitespace. A right trim marker removes leading
+// whitespace from the text nodes that follow.
+func (p *parser) trim(t token.Token) {
+	if t.TrimLeft {
+		for i := len(p.texts) - 1; i >= 0; i-- {
+			text := p.texts[i]
+			trimmed := strings.TrimRight(text.Text, whitespace)
+			text.TrimmedRight = text.Text[len(trimmed):] + text.TrimmedRight
+			text.Text = trimmed
+			text.EndOfLine = false
+			if text.Text != "" {
+				break
+			}
+		}
+	}
+	p.texts = p.texts[:0]
+	p.trimNext = t.TrimRight
+}
+
+// checkTag enforces the tag count and key length limits for a scanned tag.
+func (p *parser) checkTag(t token.Token) error {
+	p.tags++
+	if p.opts.MaxTags > 0 && p.tags > p.opts.MaxTags {
+		return p.limitError(t.Line, t.Column, Tags, p.opts.MaxTags)
+	}
+	switch t.Type {
+	case token.COMMENT, token.SET_DELIMETERS:
+		return nil
+	}
+	if p.opts.MaxKeyLength > 0 && len(t.Text) > p.opts.MaxKeyLength {
+		return p.limitError(t.Line, t.Column, KeyLength, p.opts.MaxKeyLength)
+	}
+	return nil
+}
+
+// limitError returns a LimitError positioned at the given line and column.
+func (p *parser) limitError(ln, col int, limit Limit, max int) error {
+	return &LimitError{
+		Name:   p.name,
+		Line:   ln,
+		Column: col,
+		Limit:  limit,
+		Max:    max,
+	}
+}
+
+// Error is a syntax error in a template.
+type Error struct {
+	Name   string // name of the template
+	Line   int    // line of the error
+	Column int    // column of the error, in bytes
+	Msg    string // description of the error
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Name)
+	b.WriteString(":")
+	b.WriteString(strconv.Itoa(e.Line))
+	b.WriteString(":")
+	b.WriteString(strconv.Itoa(e.Column))
+	b.WriteString(":")
+	b.WriteString(" ")
+	b.WriteString(e.Msg)
+	return b.String()
+}
+
+// error returns an error positioned at the line and column number of where
+// in the template the error occured.
+func (p *parser) error(ln, col int, msg string) error {
+	return &Error{Name: p.name, Line: ln, Column: col, Msg: msg}
+}
+
+// tag returns the source details of a tag token.
+func (p *parser) tag(t token.Token) ast.Tag {
+	return ast.Tag{
+		Raw:        t.Raw,
+		Standalone: t.Standalone,
+		Indent:     t.Indent,
+		LineEnd:    t.LineEnd,
+		TrimLeft:   t.TrimLeft,
+		TrimRight:  t.TrimRight,
+		Range:      p.tokenRange(t),
+	}
+}
+
+// tokenRange returns the source range of a token.
+func (p *parser) tokenRange(t token.Token) ast.Range {
+	start := p.position(t.Offset)
+	return ast.Range{Start: start, End: p.position(t.EndOffset)}
+}
+
+// position returns the position of a byte offset in the source. Tokens are
+// scanned in order, so the position is found by advancing a cursor from the
+// previous position, rather than by scanning the line from its start.
+func (p *parser) position(offset int) ast.Position {
+	c := p.cursor
+	if offset < c.Offset {
+		c = ast.Position{Line: 1, Column: 1, Char: 1, UTF16: 1}
+	}
+	for c.Offset < offset {
+		r, size := utf8.DecodeRuneInString(p.src[c.Offset:])
+		if c.Offset+size > offset {
+			// the offset falls within a character.
+			size = offset - c.Offset
+		}
+		c.Offset += size
+		if r == '\n' {
+			c.Line++
+			c.Column, c.Char, c.UTF16 = 1, 1, 1
+			continue
+		}
+		c.Column += size
+		c.Char++
+		c.UTF16++
+		if r >= 0x10000 {
+			// encoded as a surrogate pair
+			c.UTF16++
+		}
+	}
+	p.cursor = c
+	return c
+}
+
+// variable returns the node of a variable tag, validated by the scanner. The
+// text of the tag is split into its key and fallbacks, or in Handlebars mode,
+// into the name and arguments of a helper call.
+func (p *parser) variable(t token.Token) *ast.Variable {
+	n := &ast.Variable{
+		Tag:       p.tag(t),
+		Unescaped: t.Type != token.VARIABLE,
+		Line:      t.Line,
+		Column:    t.Column,
+	}
+	if p.opts.Handlebars && token.IsCall(t.Text) {
+		name, args, _ := token.SplitCall(t.Text)
+		n.Key = []string{name}
+		for _, arg := range args {
+			n.Args = append(n.Args, ast.Argument{Key: p.this(arg.Key), Value: arg.Value})
+		}
+		return n
+	}
+	ops, _ := token.SplitVariable(t.Text)
+	n.Key = p.this(ops[0].Key)
+	for _, op := range ops[1:] {
+		n.Fallbacks = append(n.Fallbacks, ast.Fallback{Key: p.this(op.Key), String: op.String})
+	}
+	return n
+}
+
+// this replaces a leading this in the names of a key by a dot, denoting the
+// current context, in Handlebars mode.
+func (p *parser) this(names []string) []string {
+	if p.opts.Handlebars && len(names) > 0 && names[0] == "this" {
+		names[0] = "."
+	}
+	return names
+}
+
+// closes reports whether the text of a closing tag closes an open section:
+// the name of its block helper, or its key.
+func closes(open openSection, text string) bool {
+	if open.node.Block != "" {
+		return text == open.node.Block
+	}
+	return sameKey(open.tok.Text, text)
+}
+
+// splitKey splits a key, validated by the scanner, into its names.
+func splitKey(key string) []string {
+	names, _ := token.SplitKey(key)
+	return names
+}
+
+// sameKey reports whether two keys have the same names, which may be written
+// differently, as in a.b and a["b"].
+func sameKey(a, b string) bool {
+	if a == b {
+		return true
+	}
+	ka, kb := splitKey(a), splitKey(b)
+	if len(ka) != len(kb) {
+		return false
+	}
+	for i := range ka {
+		if ka[i] != kb[i] {
+			return false
+		}
+	}
+	return true
+}
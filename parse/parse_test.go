@@ -0,0 +1,782 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package parse_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eriklott/mustache/ast"
+	"github.com/eriklott/mustache/parse"
+)
+
+func TestParse(t *testing.T) {
+	tt := []struct {
+		name  string
+		tmpl  string
+		err   string
+		nodes []ast.Node
+	}{
+		{
+			name: "Text",
+			tmpl: "abc",
+			nodes: []ast.Node{
+				&ast.Text{Text: "abc"},
+			},
+		},
+		{
+			name: "Newline",
+			tmpl: "\n",
+			nodes: []ast.Node{
+				&ast.Text{Text: "\n", EndOfLine: true},
+			},
+		},
+		{
+			name: "Carriage return",
+			tmpl: "\r\n",
+			nodes: []ast.Node{
+				&ast.Text{Text: "\r\n", EndOfLine: true},
+			},
+		},
+		{
+			name: "Variable",
+			tmpl: "{{a}}",
+			nodes: []ast.Node{
+				&ast.Variable{
+					Tag:       ast.Tag{Raw: "{{a}}"},
+					Key:       []string{"a"},
+					Unescaped: false,
+					Line:      1,
+					Column:    1,
+				},
+			},
+		},
+		{
+			name: "Variable/Quoted",
+			tmpl: `{{user["first name"].x}}`,
+			nodes: []ast.Node{
+				&ast.Variable{
+					Tag:       ast.Tag{Raw: `{{user["first name"].x}}`},
+					Key:       []string{"user", "first name", "x"},
+					Unescaped: false,
+					Line:      1,
+					Column:    1,
+				},
+			},
+		},
+		{
+			name: "Variable/Fallbacks",
+			tmpl: `{{{ a ?? b.c | default:"x" }}}`,
+			nodes: []ast.Node{
+				&ast.Variable{
+					Tag:       ast.Tag{Raw: `{{{ a ?? b.c | default:"x" }}}`},
+					Key:       []string{"a"},
+					Fallbacks: []ast.Fallback{{Key: []string{"b", "c"}}, {String: "x"}},
+					Unescaped: true,
+					Line:      1,
+					Column:    1,
+				},
+			},
+		},
+		{
+			name: "Variable/Whitespace",
+			tmpl: "{{ a }}",
+			nodes: []ast.Node{
+				&ast.Variable{
+					Tag:       ast.Tag{Raw: "{{ a }}"},
+					Key:       []string{"a"},
+					Unescaped: false,
+					Line:      1,
+					Column:    1,
+				},
+			},
+		},
+		{
+			name: "Variable/Empty",
+			tmpl: "{{}}",
+			err:  "main:1:1: missing key",
+		},
+		{
+			name: "Variable/Key/Dotted",
+			tmpl: "{{a.b.c}}",
+			nodes: []ast.Node{
+				&ast.Variable{
+					Tag:       ast.Tag{Raw: "{{a.b.c}}"},
+					Key:       []string{"a", "b", "c"},
+					Unescaped: false,
+					Line:      1,
+					Column:    1,
+				},
+			},
+		},
+		{
+			name: "Variable/Key/LeadingDot",
+			tmpl: "{{.a.b}}",
+			err:  "main:1:1: invalid key: .a.b",
+		},
+		{
+			name: "Variable/Key/TrailingDot",
+			tmpl: "{{a.b.}}",
+			err:  "main:1:1: invalid key: a.b.",
+		},
+		{
+			name: "Variable/Key/Whitespace",
+			tmpl: "{{a . b}}",
+			err:  "main:1:1: invalid key: a . b",
+		},
+		{
+			name: "Variable/Key/SingleDot",
+			tmpl: "{{.}}",
+			nodes: []ast.Node{
+				&ast.Variable{
+					Tag:       ast.Tag{Raw: "{{.}}"},
+					Key:       []string{"."},
+					Unescaped: false,
+					Line:      1,
+					Column:    1,
+				},
+			},
+		},
+		{
+			name: "Variable/UnescapedSymbol",
+			tmpl: "{{&a}}",
+			nodes: []ast.Node{
+				&ast.Variable{
+					Tag:       ast.Tag{Raw: "{{&a}}"},
+					Key:       []string{"a"},
+					Unescaped: true,
+					Line:      1,
+					Column:    1,
+				},
+			},
+		},
+		{
+			name: "Variable/Unescaped",
+			tmpl: "{{{a}}}",
+			nodes: []ast.Node{
+				&ast.Variable{
+					Tag:       ast.Tag{Raw: "{{{a}}}"},
+					Key:       []string{"a"},
+					Unescaped: true,
+					Line:      1,
+					Column:    1,
+				},
+			},
+		},
+		{
+			name: "Variable/Unescaped/Empty",
+			tmpl: "{{{}}}",
+			err:  "main:1:1: missing key",
+		},
+		{
+			name: "Variable/Unescaped/MismatchDelims",
+			tmpl: "{{{a}}",
+			err:  "main:1:1: unclosed tag",
+		},
+		{
+			name: "Section",
+			tmpl: "{{#a}}{{/a}}",
+			nodes: []ast.Node{
+				&ast.Section{
+					Tag:    ast.Tag{Raw: "{{#a}}"},
+					Close:  ast.Tag{Raw: "{{/a}}"},
+					Key:    []string{"a"},
+					LDelim: "{{",
+					RDelim: "}}",
+					Line:   1,
+					Column: 1,
+				},
+			},
+		},
+		{
+			name: "Section/Inverted",
+			tmpl: "{{^a}}{{/a}}",
+			nodes: []ast.Node{
+				&ast.Section{
+					Tag:      ast.Tag{Raw: "{{^a}}"},
+					Close:    ast.Tag{Raw: "{{/a}}"},
+					Key:      []string{"a"},
+					Inverted: true,
+					LDelim:   "{{",
+					RDelim:   "}}",
+					Line:     1,
+					Column:   1,
+				},
+			},
+		},
+		{
+			name: "Section/MissingClosingTag",
+			tmpl: "{{#a}}",
+			err:  "main:1:1: unclosed section tag: a",
+		},
+		{
+			name: "Section/MissingOpeningTag",
+			tmpl: "{{/a}}",
+			err:  "main:1:1: unexpected section closing tag: a",
+		},
+		{
+			name: "Section/EmptyOpeningTag",
+			tmpl: "{{#}}",
+			err:  "main:1:1: missing key",
+		},
+		{
+			name: "Section/EmptyClosingTag",
+			tmpl: "{{/}}",
+			err:  "main:1:1: missing key",
+		},
+		{
+			name: "Section/Children",
+			tmpl: "{{#a}}abc{{/a}}",
+			nodes: []ast.Node{
+				&ast.Section{
+					Tag:      ast.Tag{Raw: "{{#a}}"},
+					Close:    ast.Tag{Raw: "{{/a}}"},
+					Key:      []string{"a"},
+					Inverted: false,
+					LDelim:   "{{",
+					RDelim:   "}}",
+					Text:     "abc",
+					Line:     1,
+					Column:   1,
+					Nodes: []ast.Node{
+						&ast.Text{Text: "abc"},
+					},
+				},
+			},
+		},
+		{
+			name: "Partial",
+			tmpl: "{{>a}}",
+			nodes: []ast.Node{
+				&ast.Partial{
+					Tag:    ast.Tag{Raw: "{{>a}}", Standalone: true},
+					Key:    "a",
+					Line:   1,
+					Column: 1,
+				},
+			},
+		},
+		{
+			name: "Partial/Empty",
+			tmpl: "{{>}}",
+			err:  "main:1:1: missing key",
+		},
+		{
+			name: "Comment",
+			tmpl: "{{! This is a comment }}",
+			nodes: []ast.Node{
+				&ast.Comment{
+					Tag:    ast.Tag{Raw: "{{! This is a comment }}", Standalone: true},
+					Text:   "This is a comment",
+					Line:   1,
+					Column: 1,
+				},
+			},
+		},
+		{
+			name: "SetDelim",
+			tmpl: "{{=| |=}}",
+			nodes: []ast.Node{
+				&ast.SetDelims{
+					Tag:    ast.Tag{Raw: "{{=| |=}}", Standalone: true},
+					LDelim: "|",
+					RDelim: "|",
+					Line:   1,
+					Column: 1,
+				},
+			},
+		},
+		{
+			name: "SetDelim/ChangesDelimeters",
+			tmpl: "{{=| |=}}|a|",
+			nodes: []ast.Node{
+				&ast.SetDelims{
+					Tag:    ast.Tag{Raw: "{{=| |=}}"},
+					LDelim: "|",
+					RDelim: "|",
+					Line:   1,
+					Column: 1,
+				},
+				&ast.Variable{
+					Tag:    ast.Tag{Raw: "|a|"},
+					Key:    []string{"a"},
+					Line:   1,
+					Column: 10,
+				},
+			},
+		},
+		{
+			name: "Standalone/FirstLine",
+			tmpl: " {{!a}} \nb",
+			nodes: []ast.Node{
+				&ast.Comment{
+					Tag:    ast.Tag{Raw: "{{!a}}", Standalone: true, Indent: " ", LineEnd: " \n"},
+					Text:   "a",
+					Line:   1,
+					Column: 2,
+				},
+				&ast.Text{Text: "b"},
+			},
+		},
+		{
+			name: "Standalone/MidLine",
+			tmpl: "\n {{!a}} \nb",
+			nodes: []ast.Node{
+				&ast.Text{Text: "\n", EndOfLine: true},
+				&ast.Comment{
+					Tag:    ast.Tag{Raw: "{{!a}}", Standalone: true, Indent: " ", LineEnd: " \n"},
+					Text:   "a",
+					Line:   2,
+					Column: 2,
+				},
+				&ast.Text{Text: "b", EndOfLine: false},
+			},
+		},
+		{
+			name: "Standalone/LastLine",
+			tmpl: "a\n {{!b}} ",
+			nodes: []ast.Node{
+				&ast.Text{Text: "a\n", EndOfLine: true},
+				&ast.Comment{
+					Tag:    ast.Tag{Raw: "{{!b}}", Standalone: true, Indent: " ", LineEnd: " "},
+					Text:   "b",
+					Line:   2,
+					Column: 2,
+				},
+			},
+		},
+		{
+			name: "Partial/StandaloneIndent",
+			tmpl: "  {{>a}}  ",
+			nodes: []ast.Node{
+				&ast.Partial{
+					Tag:    ast.Tag{Raw: "{{>a}}", Standalone: true, Indent: "  ", LineEnd: "  "},
+					Key:    "a",
+					Line:   1,
+					Column: 3,
+				},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tree, err := parse.Parse("main", tc.tmpl, parse.DefaultLeftDelim, parse.DefaultRightDelim, parse.Options{})
+
+			var errStr string
+			if err != nil {
+				errStr = err.Error()
+			}
+			if errStr != tc.err {
+				t.Errorf("unexpected error, got: %s, want: %s", errStr, tc.err)
+			}
+			if err != nil || tc.err != "" {
+				return
+			}
+
+			clearRanges(tree)
+			if !reflect.DeepEqual(tc.nodes, tree.Nodes) {
+				t.Errorf("Parse() mismatch, got:%v, want:%v", tc.nodes, tree.Nodes)
+			}
+		})
+	}
+}
+
+// clearRanges zeroes the source ranges of a tree, which are tested
+// separately by TestParse_Ranges.
+func clearRanges(tree *ast.Tree) {
+	ast.Inspect(tree, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.Text:
+			n.Range = ast.Range{}
+		case *ast.Variable:
+			n.Range = ast.Range{}
+		case *ast.Section:
+			n.Range = ast.Range{}
+			n.Close.Range = ast.Range{}
+		case *ast.Partial:
+			n.Range = ast.Range{}
+		case *ast.Comment:
+			n.Range = ast.Range{}
+		case *ast.SetDelims:
+			n.Range = ast.Range{}
+		case *ast.Else:
+			n.Range = ast.Range{}
+		}
+		return true
+	})
+}
+
+func TestParse_Ranges(t *testing.T) {
+	// é is 2 bytes and 1 UTF-16 code unit; 😀 is 4 bytes and 2 UTF-16 code units.
+	tmpl := "é😀{{a}}\n  {{#s}}\nx{{/s}}\n{{! c }}"
+	tree, err := parse.Parse("main", tmpl, parse.DefaultLeftDelim, parse.DefaultRightDelim, parse.Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pos := func(offset, ln, col, char, utf16 int) ast.Position {
+		return ast.Position{Offset: offset, Line: ln, Column: col, Char: char, UTF16: utf16}
+	}
+
+	var got []ast.Range
+	ast.Inspect(tree, func(node ast.Node) bool {
+		if node != nil {
+			got = append(got, ast.NodeRange(node))
+		}
+		if s, ok := node.(*ast.Section); ok {
+			got = append(got, s.Close.Range)
+		}
+		return true
+	})
+	want := []ast.Range{
+		{Start: pos(0, 1, 1, 1, 1), End: pos(37, 4, 9, 9, 9)},   // tree
+		{Start: pos(0, 1, 1, 1, 1), End: pos(6, 1, 7, 3, 4)},    // é😀
+		{Start: pos(6, 1, 7, 3, 4), End: pos(11, 1, 12, 8, 9)},  // {{a}}
+		{Start: pos(11, 1, 12, 8, 9), End: pos(12, 2, 1, 1, 1)}, // \n
+		{Start: pos(14, 2, 3, 3, 3), End: pos(28, 3, 8, 8, 8)},  // {{#s}}\nx{{/s}}
+		{Start: pos(22, 3, 2, 2, 2), End: pos(28, 3, 8, 8, 8)},  // {{/s}}
+		{Start: pos(21, 3, 1, 1, 1), End: pos(22, 3, 2, 2, 2)},  // x
+		{Start: pos(28, 3, 8, 8, 8), End: pos(29, 4, 1, 1, 1)},  // \n
+		{Start: pos(29, 4, 1, 1, 1), End: pos(37, 4, 9, 9, 9)},  // {{! c }}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected ranges, got:%v, want:%v", got, want)
+	}
+}
+
+func TestParse_TrimMarkers(t *testing.T) {
+	tt := []struct {
+		name  string
+		tmpl  string
+		err   string
+		nodes []ast.Node
+	}{
+		{
+			name: "Variable",
+			tmpl: "a \n{{~b~}} \t c",
+			nodes: []ast.Node{
+				&ast.Text{Text: "a", TrimmedRight: " \n"},
+				&ast.Variable{
+					Tag:    ast.Tag{Raw: "{{~b~}}", TrimLeft: true, TrimRight: true},
+					Key:    []string{"b"},
+					Line:   2,
+					Column: 1,
+				},
+				&ast.Text{Text: "c", TrimmedLeft: " \t "},
+			},
+		},
+		{
+			name: "Lines",
+			tmpl: "a\n\n  {{~b}}",
+			nodes: []ast.Node{
+				&ast.Text{Text: "a", TrimmedRight: "\n"},
+				&ast.Text{Text: "", TrimmedRight: "\n"},
+				&ast.Text{Text: "", TrimmedRight: "  "},
+				&ast.Variable{
+					Tag:    ast.Tag{Raw: "{{~b}}", TrimLeft: true},
+					Key:    []string{"b"},
+					Line:   3,
+					Column: 3,
+				},
+			},
+		},
+		{
+			name: "Section",
+			tmpl: "{{#a~}}\n  b\n{{~/a}}",
+			nodes: []ast.Node{
+				&ast.Section{
+					Tag:    ast.Tag{Raw: "{{#a~}}", TrimRight: true},
+					Key:    []string{"a"},
+					LDelim: "{{",
+					RDelim: "}}",
+					Text:   "\n  b\n",
+					Line:   1,
+					Column: 1,
+					Close:  ast.Tag{Raw: "{{~/a}}", TrimLeft: true},
+					Nodes: []ast.Node{
+						&ast.Text{Text: "", TrimmedLeft: "\n"},
+						&ast.Text{Text: "b", TrimmedLeft: "  ", TrimmedRight: "\n"},
+					},
+				},
+			},
+		},
+		{
+			name: "SetDelims",
+			tmpl: "{{=<% %>=}} a <%~b~%> c",
+			nodes: []ast.Node{
+				&ast.SetDelims{
+					Tag:    ast.Tag{Raw: "{{=<% %>=}}"},
+					LDelim: "<%",
+					RDelim: "%>",
+					Line:   1,
+					Column: 1,
+				},
+				&ast.Text{Text: " a", TrimmedRight: " "},
+				&ast.Variable{
+					Tag:    ast.Tag{Raw: "<%~b~%>", TrimLeft: true, TrimRight: true},
+					Key:    []string{"b"},
+					Line:   1,
+					Column: 15,
+				},
+				&ast.Text{Text: "c", TrimmedLeft: " "},
+			},
+		},
+		{
+			name: "SetDelims/TrimMarker",
+			tmpl: "{{~=<% %>=}}",
+			err:  "main:1:1: trim markers are not supported in set delimiter tags",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tree, err := parse.Parse("main", tc.tmpl, parse.DefaultLeftDelim, parse.DefaultRightDelim, parse.Options{TrimMarkers: true})
+
+			var errStr string
+			if err != nil {
+				errStr = err.Error()
+			}
+			if errStr != tc.err {
+				t.Errorf("unexpected error, got: %s, want: %s", errStr, tc.err)
+			}
+			if err != nil || tc.err != "" {
+				return
+			}
+
+			clearRanges(tree)
+			if !reflect.DeepEqual(tc.nodes, tree.Nodes) {
+				t.Errorf("Parse() mismatch, got:%v, want:%v", tree.Nodes, tc.nodes)
+			}
+		})
+	}
+}
+
+func TestParse_Handlebars(t *testing.T) {
+	tt := []struct {
+		name  string
+		tmpl  string
+		err   string
+		nodes []ast.Node
+	}{
+		{
+			name: "Block",
+			tmpl: "{{#each this}}{{this.name}}{{else}}-{{/each}}",
+			nodes: []ast.Node{
+				&ast.Section{
+					Tag:    ast.Tag{Raw: "{{#each this}}"},
+					Key:    []string{"."},
+					Block:  "each",
+					LDelim: "{{",
+					RDelim: "}}",
+					Text:   "{{this.name}}{{else}}-",
+					Line:   1,
+					Column: 1,
+					Close:  ast.Tag{Raw: "{{/each}}"},
+					Nodes: []ast.Node{
+						&ast.Variable{
+							Tag:    ast.Tag{Raw: "{{this.name}}"},
+							Key:    []string{".", "name"},
+							Line:   1,
+							Column: 15,
+						},
+						&ast.Else{
+							Tag:    ast.Tag{Raw: "{{else}}"},
+							Line:   1,
+							Column: 28,
+						},
+						&ast.Text{Text: "-"},
+					},
+				},
+			},
+		},
+		{
+			name: "Call",
+			tmpl: `{{{formatDate created "short" 2}}}`,
+			nodes: []ast.Node{
+				&ast.Variable{
+					Tag:       ast.Tag{Raw: `{{{formatDate created "short" 2}}}`},
+					Key:       []string{"formatDate"},
+					Args:      []ast.Argument{{Key: []string{"created"}}, {Value: "short"}, {Value: int64(2)}},
+					Unescaped: true,
+					Line:      1,
+					Column:    1,
+				},
+			},
+		},
+		{
+			name: "UnexpectedElse",
+			tmpl: "a{{else}}",
+			err:  "main:1:2: unexpected else",
+		},
+		{
+			name: "SecondElse",
+			tmpl: "{{^a}}{{else}}{{else}}{{/a}}",
+			err:  "main:1:15: unexpected else",
+		},
+		{
+			name: "MismatchedClose",
+			tmpl: "{{#if a}}{{/a}}",
+			err:  "main:1:10: unexpected section closing tag: a",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tree, err := parse.Parse("main", tc.tmpl, parse.DefaultLeftDelim, parse.DefaultRightDelim, parse.Options{Handlebars: true})
+
+			var errStr string
+			if err != nil {
+				errStr = err.Error()
+			}
+			if errStr != tc.err {
+				t.Errorf("unexpected error, got: %s, want: %s", errStr, tc.err)
+			}
+			if err != nil || tc.err != "" {
+				return
+			}
+
+			clearRanges(tree)
+			if !reflect.DeepEqual(tc.nodes, tree.Nodes) {
+				t.Errorf("Parse() mismatch, got:%v, want:%v", tree.Nodes, tc.nodes)
+			}
+		})
+	}
+}
+
+func TestParse_Limits(t *testing.T) {
+	tt := []struct {
+		name  string
+		tmpl  string
+		opts  parse.Options
+		err   string
+		limit parse.Limit
+	}{
+		{
+			name:  "SourceSize",
+			tmpl:  "abcdef",
+			opts:  parse.Options{MaxSourceSize: 5},
+			err:   "main:1:1: exceeded maximum source size: 5",
+			limit: parse.SourceSize,
+		},
+		{
+			name:  "Depth",
+			tmpl:  "{{#a}}{{#b}}{{#c}}{{/c}}{{/b}}{{/a}}",
+			opts:  parse.Options{MaxDepth: 2},
+			err:   "main:1:13: exceeded maximum section depth: 2",
+			limit: parse.Depth,
+		},
+		{
+			name:  "Tags",
+			tmpl:  "{{a}} {{! b }} {{c}}",
+			opts:  parse.Options{MaxTags: 2},
+			err:   "main:1:16: exceeded maximum tag count: 2",
+			limit: parse.Tags,
+		},
+		{
+			name:  "KeyLength",
+			tmpl:  "{{abc}}{{abcd}}",
+			opts:  parse.Options{MaxKeyLength: 3},
+			err:   "main:1:8: exceeded maximum key length: 3",
+			limit: parse.KeyLength,
+		},
+		{
+			name: "WithinLimits",
+			tmpl: "{{#a}}{{b}}{{/a}}",
+			opts: parse.Options{MaxSourceSize: 17, MaxDepth: 1, MaxTags: 3, MaxKeyLength: 1},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parse.Parse("main", tc.tmpl, parse.DefaultLeftDelim, parse.DefaultRightDelim, tc.opts)
+
+			var errStr string
+			if err != nil {
+				errStr = err.Error()
+			}
+			if errStr != tc.err {
+				t.Fatalf("unexpected error, got: %s, want: %s", errStr, tc.err)
+			}
+			if err == nil {
+				return
+			}
+
+			var limitErr *parse.LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("expected *parse.LimitError, got %T", err)
+			}
+			if limitErr.Limit != tc.limit {
+				t.Errorf("unexpected limit, got: %v, want: %v", limitErr.Limit, tc.limit)
+			}
+		})
+	}
+}
+
+func TestParse_Error(t *testing.T) {
+	tt := []struct {
+		name string
+		tmpl string
+		want parse.Error
+	}{
+		{"Scanner", "ab\n {{a b}}", parse.Error{Name: "main", Line: 2, Column: 2, Msg: "invalid key: a b"}},
+		{"Parser", "{{#a}}{{/b}}", parse.Error{Name: "main", Line: 1, Column: 7, Msg: "unexpected section closing tag: b"}},
+		{"QuotedClose", `{{#["a.b"]}}{{/a.b}}`, parse.Error{Name: "main", Line: 1, Column: 13, Msg: "unexpected section closing tag: a.b"}},
+		{"Delimiters", "a\n  {{=<% %> %>=}}", parse.Error{Name: "main", Line: 2, Column: 3, Msg: "invalid delimiters: expected two delimiters separated by whitespace: <% %> %>"}},
+		{"DelimiterEquals", "{{=<= =>=}}", parse.Error{Name: "main", Line: 1, Column: 1, Msg: "invalid delimiter: <= contains ="}},
+		{"MissingDelimiters", "{{=   =}}", parse.Error{Name: "main", Line: 1, Column: 1, Msg: "missing delimiters"}},
+		{"Fallback", "{{#a}}{{& a ?? }}", parse.Error{Name: "main", Line: 1, Column: 7, Msg: "missing fallback"}},
+		{"SectionFallback", `{{#a ?? "x"}}{{/a}}`, parse.Error{Name: "main", Line: 1, Column: 1, Msg: `invalid key: a ?? "x"`}},
+		{"InvalidKey", `{{a["b"}}`, parse.Error{Name: "main", Line: 1, Column: 1, Msg: `invalid key: a["b"`}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parse.Parse("main", tc.tmpl, parse.DefaultLeftDelim, parse.DefaultRightDelim, parse.Options{})
+			var perr *parse.Error
+			if !errors.As(err, &perr) {
+				t.Fatalf("expected *parse.Error, got %T", err)
+			}
+			if *perr != tc.want {
+				t.Errorf("unexpected error, got:%+v, want:%+v", *perr, tc.want)
+			}
+		})
+	}
+}
+
+func TestParse_DeepNesting(t *testing.T) {
+	const depth = 100000
+	tmpl := strings.Repeat("{{#a}}", depth) + strings.Repeat("{{/a}}", depth)
+
+	tree, err := parse.Parse("main", tmpl, parse.DefaultLeftDelim, parse.DefaultRightDelim, parse.Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	n := 0
+	for nodes := tree.Nodes; len(nodes) > 0; n++ {
+		nodes = nodes[0].(*ast.Section).Nodes
+	}
+	if n != depth {
+		t.Errorf("unexpected depth, got: %d, want: %d", n, depth)
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	tmplBytes, err := ioutil.ReadFile("../../testdata/template.mustache")
+	if err != nil {
+		b.Fatal(err)
+	}
+	tmpl := string(tmplBytes)
+
+	for n := 0; n < b.N; n++ {
+		_, err := parse.Parse("main", tmpl, parse.DefaultLeftDelim, parse.DefaultRightDelim, parse.Options{})
+		if err != nil {
+			b.Fatal((err))
+		}
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package printer implements printing of mustache syntax trees.
+package printer
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/eriklott/mustache/ast"
+	"github.com/eriklott/mustache/parse"
+)
+
+// A Mode value is a set of flags (or 0). They control printing.
+type Mode uint
+
+// Printer modes
+const (
+	// Canonical prints tags in their canonical form rather than as they appear
+	// in the source: tag whitespace is normalized, whitespace trailing a
+	// standalone tag is removed, and standalone tags other than partials are
+	// indented by their section depth. The rendered output of a canonical tree
+	// is identical to the original, except for the raw text passed to section
+	// lambdas.
+	Canonical Mode = 1 << iota
+)
+
+// Config controls the output of Fprint.
+//
+// TrimMarkers tells that the tree was parsed with trim markers enabled.
+// Without it, a tag with a ~ next to its delimiters, such as {{~name~}}, is
+// ambiguous: the ~ is part of its key, but becomes a trim marker once trim
+// markers are enabled. Rather than rewrite such a tag, Canonical mode
+// reports an error.
+type Config struct {
+	Mode        Mode   // default: 0
+	Indent      string // indentation per section depth of standalone tags in Canonical mode
+	TrimMarkers bool   // the tree was parsed with trim markers enabled
+}
+
+// printer contains the state for the printing process.
+type printer struct {
+	Config
+	w      io.Writer
+	err    error
+	name   string
+	ldelim string
+	rdelim string
+}
+
+// Fprint prints the tree to w. Without the Canonical mode, the output of a
+// parsed tree is identical to its source.
+func (c *Config) Fprint(w io.Writer, tree *ast.Tree) error {
+	p := &printer{
+		Config: *c,
+		w:      w,
+		name:   tree.Name,
+		ldelim: tree.LDelim,
+		rdelim: tree.RDelim,
+	}
+	if p.ldelim == "" || p.rdelim == "" {
+		p.ldelim, p.rdelim = parse.DefaultLeftDelim, parse.DefaultRightDelim
+	}
+	p.nodes(tree.Nodes, 0)
+	return p.err
+}
+
+// Fprint prints the tree to w, reproducing the source the tree was parsed
+// from.
+func Fprint(w io.Writer, tree *ast.Tree) error {
+	return (&Config{}).Fprint(w, tree)
+}
+
+// write writes s to the output. After the first error, write does nothing.
+func (p *printer) write(s string) {
+	if p.err != nil || len(s) == 0 {
+		return
+	}
+	_, p.err = io.WriteString(p.w, s)
+}
+
+func (p *printer) nodes(nodes []ast.Node, depth int) {
+	for _, node := range nodes {
+		p.node(node, depth)
+	}
+}
+
+func (p *printer) node(node ast.Node, depth int) {
+	switch n := node.(type) {
+	case *ast.Text:
+		p.write(n.TrimmedLeft)
+		p.write(n.Text)
+		p.write(n.TrimmedRight)
+
+	case *ast.Variable:
+		var body string
+		switch {
+		case !n.Unescaped:
+			body = variableKeys(n)
+			// a key starting with a tag symbol, or ending with a trim
+			// marker, is separated from the delimiters.
+			if strings.IndexAny(body, "{&#^/>!=~") == 0 || strings.HasSuffix(body, "~") {
+				body = " " + body + " "
+			}
+		case strings.HasPrefix(strings.TrimPrefix(n.Raw[len(p.ldelim):], "~"), "{"):
+			body = "{" + variableKeys(n) + "}"
+		default:
+			body = "&" + variableKeys(n)
+		}
+		p.tag(n.Tag, body, depth, false)
+
+	case *ast.Section:
+		sym := "#"
+		if n.Inverted {
+			sym = "^"
+		}
+		if n.Block != "" {
+			p.tag(n.Tag, sym+n.Block+" "+joinKey(n.Key), depth, false)
+			p.nodes(n.Nodes, depth+1)
+			p.tag(n.Close, "/"+n.Block, depth, false)
+			break
+		}
+		p.tag(n.Tag, sym+joinKey(n.Key), depth, false)
+		p.nodes(n.Nodes, depth+1)
+		p.tag(n.Close, "/"+joinKey(n.Key), depth, false)
+
+	case *ast.Else:
+		// the else tag is printed at the depth of its section.
+		p.tag(n.Tag, "else", depth-1, false)
+
+	case *ast.Partial:
+		p.tag(n.Tag, ">"+n.Key, depth, true)
+
+	case *ast.Comment:
+		body := "!"
+		if n.Text != "" {
+			body = "! " + n.Text + " "
+		}
+		p.tag(n.Tag, body, depth, false)
+
+	case *ast.SetDelims:
+		p.tag(n.Tag, "="+n.LDelim+" "+n.RDelim+"=", depth, false)
+		p.ldelim, p.rdelim = n.LDelim, n.RDelim
+	}
+}
+
+// variableKeys returns the key of a variable followed by its fallbacks,
+// which are separated by ??, or the name of a helper call followed by its
+// arguments.
+func variableKeys(n *ast.Variable) string {
+	s := joinKey(n.Key)
+	for _, arg := range n.Args {
+		switch v := arg.Value.(type) {
+		case nil:
+			s += " " + joinKey(arg.Key)
+		case string:
+			s += " " + strconv.Quote(v)
+		case float64:
+			s += " " + strconv.FormatFloat(v, 'g', -1, 64)
+		default:
+			s += " " + fmt.Sprint(v)
+		}
+	}
+	for _, fb := range n.Fallbacks {
+		if fb.Key == nil {
+			s += " ?? " + strconv.Quote(fb.String)
+		} else {
+			s += " ?? " + joinKey(fb.Key)
+		}
+	}
+	return s
+}
+
+// tag prints a tag. In Canonical mode, the tag is printed from body, the
+// text between the delimiters, rather than from its raw source.
+func (p *printer) tag(t ast.Tag, body string, depth int, keepIndent bool) {
+	if p.Mode&Canonical == 0 {
+		if t.Standalone {
+			p.write(t.Indent)
+		}
+		p.write(t.Raw)
+		if t.Standalone {
+			p.write(t.LineEnd)
+		}
+		return
+	}
+
+	if !p.TrimMarkers && p.err == nil {
+		body := t.Raw[len(p.ldelim) : len(t.Raw)-len(p.rdelim)]
+		if strings.HasPrefix(body, "~") || strings.HasSuffix(body, "~") {
+			pos := t.Range.Start
+			p.err = fmt.Errorf("%s:%d:%d: ambiguous tag %s: ~ next to a delimiter is a trim marker when trim markers are enabled", p.name, pos.Line, pos.Column, t.Raw)
+			return
+		}
+	}
+	if t.Standalone {
+		if keepIndent {
+			p.write(t.Indent)
+		} else {
+			p.write(strings.Repeat(p.Indent, depth))
+		}
+	}
+	p.write(p.ldelim)
+	if t.TrimLeft {
+		p.write("~")
+	}
+	p.write(body)
+	if t.TrimRight {
+		p.write("~")
+	}
+	p.write(p.rdelim)
+	if t.Standalone {
+		p.write(lineEnding(t.LineEnd))
+	}
+}
+
+// joinKey joins a split key back into its dotted form. Names that cannot be
+// written bare are quoted, as in user["first name"]. A key of the current
+// context followed by names is written this.name, as in Handlebars mode.
+func joinKey(key []string) string {
+	if len(key) == 1 && key[0] == "." {
+		return "."
+	}
+	if len(key) > 1 && key[0] == "." {
+		rest := joinKey(key[1:])
+		if strings.HasPrefix(rest, "[") {
+			return "this" + rest
+		}
+		return "this." + rest
+	}
+	var b strings.Builder
+	for i, name := range key {
+		if name == "" || strings.Contains(name, ".") || strings.Contains(name, `["`) || strings.IndexFunc(name, unicode.IsSpace) >= 0 {
+			b.WriteString("[" + strconv.Quote(name) + "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(name)
+	}
+	return b.String()
+}
+
+// lineEnding returns the line ending at the end of s, or an empty string
+// if s does not end with a line ending.
+func lineEnding(s string) string {
+	switch {
+	case strings.HasSuffix(s, "\r\n"):
+		return "\r\n"
+	case strings.HasSuffix(s, "\n"):
+		return "\n"
+	default:
+		return ""
+	}
+}
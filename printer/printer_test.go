@@ -0,0 +1,233 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package printer_test
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/eriklott/mustache"
+	"github.com/eriklott/mustache/parse"
+	"github.com/eriklott/mustache/printer"
+)
+
+func TestFprint_Source(t *testing.T) {
+	tmplBytes, err := ioutil.ReadFile("../testdata/template.mustache")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tt := []struct {
+		name string
+		tmpl string
+	}{
+		{"Text", "abc\ndef\r\n"},
+		{"Variables", "{{a}} {{ b.c }} {{{ d }}} {{& e}}"},
+		{"Sections", "{{# a }}\n  {{^b}} x {{/b}}  \n{{/ a }}"},
+		{"Partials", "  {{> a }}\t\r\n{{>b}}"},
+		{"Comments", "{{!}}\n {{! multi\nline }} \n"},
+		{"SetDelims", "{{=<% %>=}}\n<% a %><%={{ }}=%>{{b}}"},
+		{"Template", string(tmplBytes)},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tree, err := parse.Parse("main", tc.tmpl, parse.DefaultLeftDelim, parse.DefaultRightDelim, parse.Options{})
+			if err != nil {
+				t.Fatalf("failed to parse template: %v", err)
+			}
+			var b strings.Builder
+			err = printer.Fprint(&b, tree)
+			if err != nil {
+				t.Fatalf("failed to print template: %v", err)
+			}
+			if got := b.String(); got != tc.tmpl {
+				t.Errorf("unexpected output, got:%q, want:%q", got, tc.tmpl)
+			}
+		})
+	}
+}
+
+func TestFprint_Canonical(t *testing.T) {
+	tt := []struct {
+		name string
+		tmpl string
+		want string
+	}{
+		{"Variables", "{{ a }} {{{ b.c }}} {{& d }}", "{{a}} {{{b.c}}} {{&d}}"},
+		{"Comments", "{{!a}}{{!   }}", "{{! a }}{{!}}"},
+		{"Sections", "{{# a }}\n {{^ b }}\nx\n   {{/b}}  \n{{/a}}\n", "{{#a}}\n  {{^b}}\nx\n  {{/b}}\n{{/a}}\n"},
+		{"Partials", "{{#a}}\n   {{> b }}  \r\n{{/a}}", "{{#a}}\n   {{>b}}\r\n{{/a}}"},
+		{"SetDelims", "{{= <% %> =}}<% a %>\n", "{{=<% %>=}}<%a%>\n"},
+		{"Triple/SetDelims", "{{=| |=}}|{ a }|", "{{=| |=}}|{a}|"},
+		{"QuotedKeys", "{{ user.[\"first name\"] }}{{#[\"a.b\"]}}{{/[\"a.b\"]}}{{ naïve_user-id }}", "{{user[\"first name\"]}}{{#[\"a.b\"]}}{{/[\"a.b\"]}}{{naïve_user-id}}"},
+		{"Fallbacks", "{{ nickname  ??name | default: \"Gu\\\"est\" }}{{{a | default:b}}}{{& a ?? \"\" }}", "{{nickname ?? name ?? \"Gu\\\"est\"}}{{{a ?? b}}}{{&a ?? \"\"}}"},
+		{"SymbolKeys", "{{ #a }}{{ !b }}{{ c~ }}", "{{ #a }}{{ !b }}{{ c~ }}"},
+	}
+
+	cfg := printer.Config{Mode: printer.Canonical, Indent: "  "}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tree, err := parse.Parse("main", tc.tmpl, parse.DefaultLeftDelim, parse.DefaultRightDelim, parse.Options{})
+			if err != nil {
+				t.Fatalf("failed to parse template: %v", err)
+			}
+			var b strings.Builder
+			err = cfg.Fprint(&b, tree)
+			if err != nil {
+				t.Fatalf("failed to print template: %v", err)
+			}
+			if got := b.String(); got != tc.want {
+				t.Errorf("unexpected output, got:%q, want:%q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFprint_TrimMarkers(t *testing.T) {
+	tt := []struct {
+		name string
+		tmpl string
+		want string
+	}{
+		{"Variables", "a \n{{~ b ~}}\n c {{~{ d }}} {{& e ~}}", "a \n{{~b~}}\n c {{~{d}}} {{&e~}}"},
+		{"Sections", "{{# a ~}}\n  x\n{{~/ a }}", "{{#a~}}\n  x\n{{~/a}}"},
+		{"SetDelims", "{{=| |=}} |~{ a }~| ", "{{=| |=}} |~{a}~| "},
+	}
+
+	opts := parse.Options{TrimMarkers: true}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tree, err := parse.Parse("main", tc.tmpl, parse.DefaultLeftDelim, parse.DefaultRightDelim, opts)
+			if err != nil {
+				t.Fatalf("failed to parse template: %v", err)
+			}
+			var b strings.Builder
+			err = printer.Fprint(&b, tree)
+			if err != nil {
+				t.Fatalf("failed to print template: %v", err)
+			}
+			if got := b.String(); got != tc.tmpl {
+				t.Errorf("unexpected source output, got:%q, want:%q", got, tc.tmpl)
+			}
+			b.Reset()
+			err = (&printer.Config{Mode: printer.Canonical, TrimMarkers: true}).Fprint(&b, tree)
+			if err != nil {
+				t.Fatalf("failed to print template: %v", err)
+			}
+			if got := b.String(); got != tc.want {
+				t.Errorf("unexpected canonical output, got:%q, want:%q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFprint_AmbiguousTrimMarkers(t *testing.T) {
+	tt := []struct {
+		name string
+		tmpl string
+		err  string
+	}{
+		{"Variable", "a {{~b~}}", "main:1:3: ambiguous tag {{~b~}}: ~ next to a delimiter is a trim marker when trim markers are enabled"},
+		{"Section", "{{#a~}}\n{{/a~}}", "main:1:1: ambiguous tag {{#a~}}: ~ next to a delimiter is a trim marker when trim markers are enabled"},
+		{"Comment", "{{!a ~}}", "main:1:1: ambiguous tag {{!a ~}}: ~ next to a delimiter is a trim marker when trim markers are enabled"},
+		{"SetDelims", "{{=| |=}}|~a|", "main:1:10: ambiguous tag |~a|: ~ next to a delimiter is a trim marker when trim markers are enabled"},
+		{"Separated", "{{ ~a~ }}", ""},
+	}
+
+	cfg := printer.Config{Mode: printer.Canonical}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tree, err := parse.Parse("main", tc.tmpl, parse.DefaultLeftDelim, parse.DefaultRightDelim, parse.Options{})
+			if err != nil {
+				t.Fatalf("failed to parse template: %v", err)
+			}
+			var b strings.Builder
+			err = cfg.Fprint(&b, tree)
+			var gotErr string
+			if err != nil {
+				gotErr = err.Error()
+			}
+			if gotErr != tc.err {
+				t.Errorf("unexpected error, got:%s, want:%s", gotErr, tc.err)
+			}
+		})
+	}
+}
+
+func TestFprint_Handlebars(t *testing.T) {
+	tt := []struct {
+		name string
+		tmpl string
+		want string
+	}{
+		{"Blocks", "{{# if  a }}\n  x\n  {{ else }}\ny{{/ if }}", "{{#if a}}\n  x\n{{else}}\ny{{/if}}"},
+		{"This", "{{#each this}}{{this.name}}{{this.[\"a b\"]}}{{/each}}", "{{#each .}}{{this.name}}{{this[\"a b\"]}}{{/each}}"},
+		{"Call", "{{ f  a \"b\"  -1 2.5 true }}", "{{f a \"b\" -1 2.5 true}}"},
+	}
+
+	opts := parse.Options{Handlebars: true}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tree, err := parse.Parse("main", tc.tmpl, parse.DefaultLeftDelim, parse.DefaultRightDelim, opts)
+			if err != nil {
+				t.Fatalf("failed to parse template: %v", err)
+			}
+			var b strings.Builder
+			err = printer.Fprint(&b, tree)
+			if err != nil {
+				t.Fatalf("failed to print template: %v", err)
+			}
+			if got := b.String(); got != tc.tmpl {
+				t.Errorf("unexpected source output, got:%q, want:%q", got, tc.tmpl)
+			}
+			b.Reset()
+			err = (&printer.Config{Mode: printer.Canonical, TrimMarkers: true}).Fprint(&b, tree)
+			if err != nil {
+				t.Fatalf("failed to print template: %v", err)
+			}
+			if got := b.String(); got != tc.want {
+				t.Errorf("unexpected canonical output, got:%q, want:%q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFprint_CanonicalRender(t *testing.T) {
+	tmpl := "{{# a }}\n\t{{!note}}  \n  {{ b }} {{{ c }}}\n {{/ a }}\n{{^ a}} {{> p }} {{/a}}\n  {{> p }}\n"
+	data := map[string]interface{}{
+		"a": []map[string]string{{"b": "<1>", "c": "<2>"}, {"b": "3"}},
+	}
+
+	tree, err := parse.Parse("main", tmpl, parse.DefaultLeftDelim, parse.DefaultRightDelim, parse.Options{})
+	if err != nil {
+		t.Fatalf("failed to parse template: %v", err)
+	}
+	var b strings.Builder
+	err = (&printer.Config{Mode: printer.Canonical, Indent: "  "}).Fprint(&b, tree)
+	if err != nil {
+		t.Fatalf("failed to print template: %v", err)
+	}
+
+	render := func(text string) string {
+		tmpl := mustache.NewTemplate()
+		if err := tmpl.Parse("main", text); err != nil {
+			t.Fatalf("failed to parse template: %v", err)
+		}
+		if err := tmpl.Parse("p", "x\ny\n"); err != nil {
+			t.Fatalf("failed to parse partial: %v", err)
+		}
+		s, err := tmpl.Render("main", data)
+		if err != nil {
+			t.Fatalf("failed to render template: %v", err)
+		}
+		return s
+	}
+
+	if got, want := render(b.String()), render(tmpl); got != want {
+		t.Errorf("unexpected output, got:%q, want:%q", got, want)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package mustache
+
+import (
+	"fmt"
+
+	"github.com/eriklott/mustache/ast"
+)
+
+// ReferenceKind is the kind of tag a key is referenced by.
+type ReferenceKind int
+
+// Kinds of references
+const (
+	VariableReference ReferenceKind = iota
+	SectionReference
+	InvertedSectionReference
+)
+
+func (k ReferenceKind) String() string {
+	switch k {
+	case VariableReference:
+		return "variable"
+	case SectionReference:
+		return "section"
+	case InvertedSectionReference:
+		return "inverted section"
+	default:
+		return fmt.Sprintf("ReferenceKind(%d)", int(k))
+	}
+}
+
+// Reference is a key referenced by a variable or section tag.
+type Reference struct {
+	Name     string        // name of the template containing the tag
+	Key      []string      // the dotted key, split into its parts
+	Kind     ReferenceKind // the kind of tag
+	Escaped  bool          // true for variables rendered with html escaping
+	Fallback bool          // true for the fallback keys of variables
+	Argument bool          // true for the argument keys of helper calls
+	Sections [][]string    // keys of the enclosing sections, outermost first
+	Line     int
+	Column   int
+}
+
+// References returns every key referenced by the named template and the
+// partials it includes, in the order they appear in the template. The
+// sections enclosing a reference include the sections surrounding the
+// partial tags it was reached through. Missing partials are skipped, and a
+// partial that includes itself is only followed once on each path.
+func (t *Template) References(name string) ([]Reference, error) {
+	tree, ok := t.treeMap[name]
+	if !ok {
+		return nil, fmt.Errorf("template not found: %s", name)
+	}
+	r := &referencer{
+		template: t,
+		name:     name,
+		active:   map[string]bool{name: true},
+	}
+	r.nodes(tree.Name, tree.Nodes, nil)
+	return r.refs, nil
+}
+
+// referencer contains the state of collecting references.
+type referencer struct {
+	template *Template
+	name     string          // the name of the template being collected
+	active   map[string]bool // templates on the current include path
+	refs     []Reference
+}
+
+func (r *referencer) nodes(treeName string, nodes []ast.Node, sections [][]string) {
+	for _, node := range nodes {
+		r.node(treeName, node, sections)
+	}
+}
+
+func (r *referencer) node(treeName string, node ast.Node, sections [][]string) {
+	switch n := node.(type) {
+	case *ast.Variable:
+		if n.Args != nil {
+			// the name of a helper call is a function, not a key.
+			for _, arg := range n.Args {
+				if arg.Key != nil {
+					r.refs = append(r.refs, Reference{
+						Name:     treeName,
+						Key:      arg.Key,
+						Kind:     VariableReference,
+						Escaped:  !n.Unescaped,
+						Argument: true,
+						Sections: sections,
+						Line:     n.Line,
+						Column:   n.Column,
+					})
+				}
+			}
+			return
+		}
+		r.refs = append(r.refs, Reference{
+			Name:     treeName,
+			Key:      n.Key,
+			Kind:     VariableReference,
+			Escaped:  !n.Unescaped,
+			Sections: sections,
+			Line:     n.Line,
+			Column:   n.Column,
+		})
+		for _, fb := range n.Fallbacks {
+			if fb.Key != nil {
+				r.refs = append(r.refs, Reference{
+					Name:     treeName,
+					Key:      fb.Key,
+					Kind:     VariableReference,
+					Escaped:  !n.Unescaped,
+					Fallback: true,
+					Sections: sections,
+					Line:     n.Line,
+					Column:   n.Column,
+				})
+			}
+		}
+
+	case *ast.Section:
+		kind := SectionReference
+		if n.Inverted {
+			kind = InvertedSectionReference
+		}
+		r.refs = append(r.refs, Reference{
+			Name:     treeName,
+			Key:      n.Key,
+			Kind:     kind,
+			Sections: sections,
+			Line:     n.Line,
+			Column:   n.Column,
+		})
+		inner := make([][]string, len(sections)+1)
+		copy(inner, sections)
+		inner[len(sections)] = n.Key
+		r.nodes(treeName, n.Nodes, inner)
+
+	case *ast.Partial:
+		name := n.Resolve(r.name)
+		tree, ok := r.template.treeMap[name]
+		if !ok || r.active[name] {
+			return
+		}
+		from := r.name
+		r.name, r.active[name] = name, true
+		r.nodes(tree.Name, tree.Nodes, sections)
+		r.name, r.active[name] = from, false
+	}
+}
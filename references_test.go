@@ -0,0 +1,51 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package mustache_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eriklott/mustache"
+)
+
+func TestTemplate_References(t *testing.T) {
+	tmpl := mustache.NewTemplate()
+	templates := map[string]string{
+		"main": "{{title}}\n{{#users}}{{>user}}{{/users}}{{^users}}{{{empty}}}{{/users}}",
+		"user": "{{name}}{{#friends}}{{>user}}{{/friends}}{{nick ?? name ?? \"?\"}}",
+	}
+	for name, text := range templates {
+		err := tmpl.Parse(name, text)
+		if err != nil {
+			t.Fatalf("failed to parse template: %v", err)
+		}
+	}
+
+	got, err := tmpl.References("main")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	users := []string{"users"}
+	want := []mustache.Reference{
+		{Name: "main", Key: []string{"title"}, Kind: mustache.VariableReference, Escaped: true, Line: 1, Column: 1},
+		{Name: "main", Key: users, Kind: mustache.SectionReference, Line: 2, Column: 1},
+		{Name: "user", Key: []string{"name"}, Kind: mustache.VariableReference, Escaped: true, Sections: [][]string{users}, Line: 1, Column: 1},
+		{Name: "user", Key: []string{"friends"}, Kind: mustache.SectionReference, Sections: [][]string{users}, Line: 1, Column: 9},
+		{Name: "user", Key: []string{"nick"}, Kind: mustache.VariableReference, Escaped: true, Sections: [][]string{users}, Line: 1, Column: 42},
+		{Name: "user", Key: []string{"name"}, Kind: mustache.VariableReference, Escaped: true, Fallback: true, Sections: [][]string{users}, Line: 1, Column: 42},
+		{Name: "main", Key: users, Kind: mustache.InvertedSectionReference, Line: 2, Column: 30},
+		{Name: "main", Key: []string{"empty"}, Kind: mustache.VariableReference, Sections: [][]string{users}, Line: 2, Column: 40},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected references,\ngot:  %+v\nwant: %+v", got, want)
+	}
+
+	_, err = tmpl.References("missing")
+	if err == nil {
+		t.Errorf("expected error for missing template")
+	}
+}
@@ -2,72 +2,192 @@ package mustache
 
 import (
 	"fmt"
-	"html"
 	"math"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
-	"github.com/eriklott/mustache/internal/ast"
-	"github.com/eriklott/mustache/internal/parse"
+	"github.com/eriklott/mustache/ast"
+	"github.com/eriklott/mustache/parse"
 )
 
 const maxPartialDepth = 100000
 
+// maxPooledBuffer is the largest output buffer kept by a pooled renderer.
+// Larger buffers are released, so that a single large render does not pin
+// its memory for the life of the pool.
+const maxPooledBuffer = 64 << 10
+
 // renderer represents the state of the rendering of a single template.
 type renderer struct {
 	template *Template       // the template that initiated the render
 	stack    []reflect.Value // the context stack
 	depth    int             // the depth of executing partials
+	name     string          // the name of the executing template, for relative partials
 
 	// write fields
-	w          strings.Builder // the writer
-	indent     string          // the current indent string
-	indentNext bool            // when true, apply indent before next write
+	buf        []byte // the output
+	indent     []byte // the current indent string
+	indentNext bool   // when true, apply indent before next write
+
+	scratch []byte // the output buffer of Render, kept between renders
 }
 
-// newRenderer returns a newly initialized renderer.
+// rendererPool holds renderers for reuse between renders.
+var rendererPool = sync.Pool{
+	New: func() interface{} { return new(renderer) },
+}
+
+// newRenderer returns a renderer from the pool. The renderer must be
+// returned with release.
 func (t *Template) newRenderer() *renderer {
-	return &renderer{template: t}
+	r := rendererPool.Get().(*renderer)
+	r.template = t
+	return r
+}
+
+// release resets a renderer and returns it to the pool. The renderer keeps
+// its stack, indent and scratch buffer for reuse, but not references to the
+// template, the data, or the output. The slices are cleared up to their
+// capacity, as contexts popped from the stack and the output of earlier
+// renders remain in their backing arrays.
+func (r *renderer) release() {
+	stack := r.stack[:cap(r.stack)]
+	for i := range stack {
+		stack[i] = reflect.Value{}
+	}
+	clearBytes(r.indent[:cap(r.indent)])
+	clearBytes(r.scratch[:cap(r.scratch)])
+	*r = renderer{
+		stack:   r.stack[:0],
+		indent:  r.indent[:0],
+		scratch: r.scratch,
+	}
+	rendererPool.Put(r)
 }
 
-func (r *renderer) String() string {
-	return r.w.String()
+// clearBytes zeroes b.
+func clearBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
 }
 
-// renderToString sub-renders a tree into a string. If an error occurs,
-// rendering stops and the error is returned.
+// renderToString sub-renders a tree into a string, using the current
+// context stack. If an error occurs, rendering stops and the error is
+// returned.
 func (r *renderer) renderToString(tree *ast.Tree) (string, error) {
-	subRenderer := &renderer{
-		template:   r.template,
-		stack:      r.stack,
-		depth:      0,
-		w:          strings.Builder{},
-		indent:     "",
-		indentNext: false,
-	}
-	err := subRenderer.walk(tree.Name, tree)
-	s := subRenderer.String()
-
-	// the subRenderer may have pushed and popped enough contexts onto the stack
-	// to cause the slice to allocate to a new larger underlaying array. If this
-	// has happened, we want to keep the pointer to that larger array to minimize
-	// allocations.
-	r.stack = subRenderer.stack
+	// the tree is rendered at the end of the output, without indentation,
+	// then removed.
+	return r.capture(func() error {
+		return r.walk(tree.Name, tree)
+	})
+}
 
+// capture runs a render function and returns its output as a string,
+// rather than writing it to the template output.
+func (r *renderer) capture(render func() error) (string, error) {
+	// the captured output starts without indentation. The outer indent is
+	// set aside rather than truncated, so that standalone partials within
+	// the capture cannot overwrite it. The partial depth is kept, so that
+	// recursion through lambdas and helpers is limited as well.
+	start, indent, indentNext := len(r.buf), r.indent, r.indentNext
+	r.indent, r.indentNext = nil, false
+	err := render()
+	s := string(r.buf[start:])
+	r.buf = r.buf[:start]
+	r.indent, r.indentNext = indent, indentNext
 	return s, err
 }
 
+// applyHelper renders the body of a helper section, escaping values as
+// usual, and writes the result of the helper as is, as the result of a
+// lambda is.
+func (r *renderer) applyHelper(h Helper, body func() error) error {
+	s, err := r.capture(body)
+	if err != nil {
+		return err
+	}
+	r.write(h(s), true)
+	return nil
+}
+
 // write a string to the template output.
 func (r *renderer) write(s string, unescaped bool) {
 	if r.indentNext {
 		r.indentNext = false
-		r.w.WriteString(r.indent)
+		r.buf = append(r.buf, r.indent...)
+	}
+	switch {
+	case unescaped:
+		r.buf = append(r.buf, s...)
+	case r.template.Escape != nil:
+		r.buf = append(r.buf, r.template.Escape(s)...)
+	default:
+		r.buf = appendEscaped(r.buf, s)
+	}
+}
+
+// writeValue writes the string form of a value to the template output.
+// Numbers and strings are written without converting them to a string first.
+func (r *renderer) writeValue(v reflect.Value, unescaped bool) error {
+	if k := v.Kind(); k == reflect.Ptr || k == reflect.Interface {
+		v = indirect(v)
 	}
-	if !unescaped {
-		s = html.EscapeString(s)
+	kind := v.Kind()
+	if kind != reflect.String && !unescaped && r.template.Escape != nil {
+		// numbers are passed to the escape function like other values.
+		kind = reflect.Invalid
 	}
-	r.w.WriteString(s)
+	switch kind {
+	case reflect.String:
+		r.write(v.String(), unescaped)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		r.write("", true)
+		r.buf = strconv.AppendInt(r.buf, v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		r.write("", true)
+		r.buf = strconv.AppendUint(r.buf, v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		r.write("", true)
+		r.buf = strconv.AppendFloat(r.buf, v.Float(), 'f', -1, 64)
+	default:
+		s, err := r.toString(v, parse.DefaultLeftDelim, parse.DefaultRightDelim)
+		if err != nil {
+			return err
+		}
+		r.write(s, unescaped)
+	}
+	return nil
+}
+
+// appendEscaped appends s to buf, escaping the same characters as
+// html.EscapeString.
+func appendEscaped(buf []byte, s string) []byte {
+	last := 0
+	for i := 0; i < len(s); i++ {
+		var esc string
+		switch s[i] {
+		case '&':
+			esc = "&amp;"
+		case '\'':
+			esc = "&#39;"
+		case '<':
+			esc = "&lt;"
+		case '>':
+			esc = "&gt;"
+		case '"':
+			esc = "&#34;"
+		default:
+			continue
+		}
+		buf = append(buf, s[last:i]...)
+		buf = append(buf, esc...)
+		last = i + 1
+	}
+	return append(buf, s[last:]...)
 }
 
 // conceptually shifts a context onto the stack. Since the stack is actually in
@@ -100,21 +220,23 @@ func (r *renderer) walk(treeName string, node interface{}) error {
 		}
 
 	case *ast.Text:
+		if t.Text == "" {
+			return nil
+		}
 		r.write(t.Text, true)
 		if t.EndOfLine {
 			r.indentNext = true
 		}
 
 	case *ast.Variable:
-		v, err := r.lookup(treeName, t.Line, t.Column, t.Key)
+		v, err := r.lookupVariable(treeName, t)
 		if err != nil {
 			return err
 		}
-		s, err := r.toString(v, parse.DefaultLeftDelim, parse.DefaultRightDelim)
+		err = r.writeValue(v, t.Unescaped)
 		if err != nil {
 			return err
 		}
-		r.write(s, t.Unescaped)
 
 	case *ast.Section:
 		v, err := r.lookup(treeName, t.Line, t.Column, t.Key)
@@ -125,23 +247,46 @@ func (r *renderer) walk(treeName string, node interface{}) error {
 		if err != nil {
 			return err
 		}
+		nodes, inverse := t.Branches()
+		if t.Block != "" {
+			return r.block(t, v, func(r *renderer) error {
+				return r.walkNodes(treeName, nodes)
+			}, func(r *renderer) error {
+				return r.walkNodes(treeName, inverse)
+			})
+		}
 		isTruthy := v.IsValid()
 		if !t.Inverted && isTruthy {
 			switch v.Kind() {
 			case reflect.Slice, reflect.Array:
 				for i := 0; i < v.Len(); i++ {
 					r.push(v.Index(i))
-					for j := range t.Nodes {
-						err := r.walk(treeName, t.Nodes[j])
-						if err != nil {
-							return err
-						}
+					err := r.walkNodes(treeName, nodes)
+					if err != nil {
+						return err
 					}
 					r.pop()
 				}
 			case reflect.Func:
+				if l, ok := asLambda(v); ok {
+					s, err := r.callLambda(treeName, t, l)
+					if err != nil {
+						return err
+					}
+					r.write(s, true)
+					break
+				}
+				if h, ok := asHelper(v); ok {
+					err := r.applyHelper(h, func() error {
+						return r.walkNodes(treeName, nodes)
+					})
+					if err != nil {
+						return err
+					}
+					break
+				}
 				s := v.Call([]reflect.Value{reflect.ValueOf(t.Text)})[0].String()
-				tree, err := parse.Parse("lambda", s, t.LDelim, t.RDelim)
+				tree, err := parse.Parse("lambda", s, t.LDelim, t.RDelim, r.template.ParseOptions)
 				if err != nil {
 					return nil
 				}
@@ -152,25 +297,21 @@ func (r *renderer) walk(treeName string, node interface{}) error {
 
 			default:
 				r.push(v)
-				for i := range t.Nodes {
-					err := r.walk(treeName, t.Nodes[i])
-					if err != nil {
-						return err
-					}
-				}
-				r.pop()
-			}
-		} else if t.Inverted && !isTruthy {
-			for i := range t.Nodes {
-				err := r.walk(treeName, t.Nodes[i])
+				err := r.walkNodes(treeName, nodes)
 				if err != nil {
 					return err
 				}
+				r.pop()
 			}
+		} else if t.Inverted && !isTruthy {
+			return r.walkNodes(treeName, nodes)
+		} else {
+			return r.walkNodes(treeName, inverse)
 		}
 
 	case *ast.Partial:
-		tree, ok := r.template.treeMap[t.Key]
+		name := t.Resolve(r.name)
+		tree, ok := r.template.treeMap[name]
 		if !ok {
 			if r.template.ContextErrorsEnabled {
 				return fmt.Errorf("%s:%d:%d: partial not found: %s", treeName, t.Line, t.Column, t.Key)
@@ -178,8 +319,8 @@ func (r *renderer) walk(treeName string, node interface{}) error {
 			return nil
 		}
 
-		origIndent := r.indent
-		r.indent += t.Indent
+		origIndent := len(r.indent)
+		r.indent = append(r.indent, t.Indent...)
 
 		r.indentNext = true
 
@@ -188,18 +329,180 @@ func (r *renderer) walk(treeName string, node interface{}) error {
 			return fmt.Errorf("exceeded maximum partial depth: %d", maxPartialDepth)
 		}
 
+		from := r.name
+		r.name = name
 		err := r.walk(tree.Name, tree)
 		if err != nil {
 			return err
 		}
+		r.name = from
 
 		r.depth--
 
-		r.indent = origIndent
+		r.indent = r.indent[:origIndent]
+	}
+	return nil
+}
+
+// walkNodes walks a list of nodes in order.
+func (r *renderer) walkNodes(treeName string, nodes []ast.Node) error {
+	for i := range nodes {
+		if err := r.walk(treeName, nodes[i]); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// block renders a section of a Handlebars block helper, given the truthy
+// value of its key. The body renders the nodes of the section preceding its
+// else tag, and inverse the nodes following it, which are rendered when the
+// body is not: when the value is falsey for if, with and each, truthy for
+// unless, or cannot be iterated by each.
+func (r *renderer) block(sec *ast.Section, v reflect.Value, body, inverse program) error {
+	isTruthy := v.IsValid()
+	switch sec.Block {
+	case "if":
+		if isTruthy {
+			return body(r)
+		}
+	case "unless":
+		if !isTruthy {
+			return body(r)
+		}
+	case "with":
+		if isTruthy {
+			r.push(v)
+			err := body(r)
+			if err != nil {
+				return err
+			}
+			r.pop()
+			return nil
+		}
+	case "each":
+		if isTruthy {
+			ok, err := r.each(v, body)
+			if ok || err != nil {
+				return err
+			}
+		}
+	}
+	return inverse(r)
+}
+
+// each renders the body of an each block for every element of a slice or
+// array, or every value of a map, ordered by key. Each element is pushed onto
+// the context stack above a context holding the @index, @key, @first and
+// @last of the element; the @key of slice elements is their index. It reports
+// whether v could be iterated and was not empty.
+func (r *renderer) each(v reflect.Value, body program) (bool, error) {
+	var keys []reflect.Value
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+	case reflect.Map:
+		keys = v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+	default:
+		return false, nil
+	}
+	n := v.Len()
+	for i := 0; i < n; i++ {
+		data := map[string]interface{}{
+			"@index": i,
+			"@key":   i,
+			"@first": i == 0,
+			"@last":  i == n-1,
+		}
+		var elem reflect.Value
+		if keys != nil {
+			data["@key"] = keys[i].Interface()
+			elem = v.MapIndex(keys[i])
+		} else {
+			elem = v.Index(i)
+		}
+		r.push(reflect.ValueOf(data))
+		r.push(elem)
+		if err := body(r); err != nil {
+			return true, err
+		}
+		r.pop()
+		r.pop()
+	}
+	return n > 0, nil
+}
+
+// call calls the function registered for a helper call with the values of
+// its arguments, and returns the first result. Keys are looked up in the
+// context stack, as the keys of variables are.
+func (r *renderer) call(name string, t *ast.Variable) (reflect.Value, error) {
+	fname := t.Key[0]
+	fn, ok := r.template.funcs[fname]
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("%s:%d:%d: function not registered: %s", name, t.Line, t.Column, fname)
+	}
+	typ := fn.Type()
+	in := typ.NumIn()
+	switch {
+	case typ.IsVariadic() && len(t.Args) < in-1:
+		return reflect.Value{}, fmt.Errorf("%s:%d:%d: wrong number of arguments for %s: got %d, want at least %d", name, t.Line, t.Column, fname, len(t.Args), in-1)
+	case !typ.IsVariadic() && len(t.Args) != in:
+		return reflect.Value{}, fmt.Errorf("%s:%d:%d: wrong number of arguments for %s: got %d, want %d", name, t.Line, t.Column, fname, len(t.Args), in)
+	}
+	args := make([]reflect.Value, len(t.Args))
+	for i, arg := range t.Args {
+		v := reflect.ValueOf(arg.Value)
+		if arg.Key != nil {
+			var err error
+			v, err = r.lookup(name, t.Line, t.Column, arg.Key)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+		}
+		var param reflect.Type
+		if typ.IsVariadic() && i >= in-1 {
+			param = typ.In(in - 1).Elem()
+		} else {
+			param = typ.In(i)
+		}
+		if args[i], ok = convertArg(v, param); !ok {
+			return reflect.Value{}, fmt.Errorf("%s:%d:%d: wrong type for argument %d of %s: got %s, want %s", name, t.Line, t.Column, i+1, fname, args[i].Type(), param)
+		}
+	}
+	out := fn.Call(args)
+	if len(out) == 2 && !out[1].IsNil() {
+		return reflect.Value{}, fmt.Errorf("%s:%d:%d: %s: %v", name, t.Line, t.Column, fname, out[1].Interface())
+	}
+	return out[0], nil
+}
+
+// convertArg converts the value of an argument to the type of its parameter,
+// and reports whether it could. Missing and nil values are converted to the
+// zero value of the type, and numbers to other number types.
+func convertArg(v reflect.Value, typ reflect.Type) (reflect.Value, bool) {
+	if v.IsValid() && v.Type().AssignableTo(typ) {
+		return v, true
+	}
+	v = indirect(v)
+	switch {
+	case !v.IsValid():
+		return reflect.Zero(typ), true
+	case v.Type().AssignableTo(typ):
+		return v, true
+	case isNumber(v.Kind()) && isNumber(typ.Kind()),
+		v.Kind() == reflect.String && typ.Kind() == reflect.String:
+		return v.Convert(typ), true
+	}
+	return v, false
+}
+
+// isNumber reports whether a kind is an integer or floating point number.
+func isNumber(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Float64
+}
+
 // toString transforms a reflect.Value into a string.
 func (r *renderer) toString(v reflect.Value, ldelim, rdelim string) (string, error) {
 	switch v.Kind() {
@@ -230,7 +533,7 @@ func (r *renderer) toString(v reflect.Value, ldelim, rdelim string) (string, err
 		if v.Kind() != reflect.String {
 			return r.toString(v, ldelim, rdelim)
 		}
-		tree, err := parse.Parse("lambda", v.String(), ldelim, rdelim)
+		tree, err := parse.Parse("lambda", v.String(), ldelim, rdelim, r.template.ParseOptions)
 		if err != nil {
 			return "", err
 		}
@@ -302,7 +605,7 @@ func (r *renderer) toTruthyValue(v reflect.Value) (reflect.Value, error) {
 			if v.Kind() != reflect.String {
 				return r.toTruthyValue(v)
 			}
-			tree, err := parse.Parse("lambda", v.String(), parse.DefaultLeftDelim, parse.DefaultRightDelim)
+			tree, err := parse.Parse("lambda", v.String(), parse.DefaultLeftDelim, parse.DefaultRightDelim, r.template.ParseOptions)
 			if err != nil {
 				return reflect.Value{}, nil
 			}
@@ -312,6 +615,9 @@ func (r *renderer) toTruthyValue(v reflect.Value) (reflect.Value, error) {
 			}
 			return r.toTruthyValue(reflect.ValueOf(s))
 		}
+		if t == lambdaType {
+			return v, nil
+		}
 		isArity1 := t.NumIn() == 1 && t.In(0).Kind() == reflect.String && t.NumOut() == 1 && t.Out(0).Kind() == reflect.String
 		if isArity1 {
 			return v, nil
@@ -353,13 +659,123 @@ loop:
 // lookup a key in the context stack. If a value was not found, the reflect.Value zero
 // type is returned.
 func (r *renderer) lookup(name string, ln, col int, key []string) (reflect.Value, error) {
-	v := lookupKeysStack(key, r.stack)
+	v := r.find(key)
 	if !v.IsValid() && r.template.ContextErrorsEnabled {
 		return v, fmt.Errorf("%s:%d:%d: cannot find value %s in context", name, ln, col, strings.Join(key, "."))
 	}
 	return v, nil
 }
 
+// find looks up a key in the context stack, then in the helpers of the
+// template. If a value was not found, the reflect.Value zero type is
+// returned.
+func (r *renderer) find(key []string) reflect.Value {
+	v := lookupKeysStack(key, r.stack)
+	if !v.IsValid() {
+		v = r.lookupHelper(key)
+	}
+	return v
+}
+
+// lookupVariable looks up the value of a variable tag. While the value is
+// missing or falsy, the fallbacks of the variable are tried in order; a string
+// fallback is its own value. A truthy value is returned as resolved while
+// testing it, so that a lambda is not called again when it is written. With
+// context errors enabled, it is an error when neither the key nor any
+// fallback is found. The value of a helper call is the result of its
+// function.
+func (r *renderer) lookupVariable(name string, t *ast.Variable) (reflect.Value, error) {
+	if t.Args != nil {
+		return r.call(name, t)
+	}
+	if len(t.Fallbacks) == 0 {
+		return r.lookup(name, t.Line, t.Column, t.Key)
+	}
+	v := r.find(t.Key)
+	found := v.IsValid()
+	for _, fb := range t.Fallbacks {
+		truthy, err := r.toTruthyValue(v)
+		if err != nil {
+			return v, err
+		}
+		if truthy.IsValid() {
+			v = truthy
+			break
+		}
+		if fb.Key == nil {
+			return reflect.ValueOf(fb.String), nil
+		}
+		v = r.find(fb.Key)
+		found = found || v.IsValid()
+	}
+	if !found && r.template.ContextErrorsEnabled {
+		return v, fmt.Errorf("%s:%d:%d: cannot find value %s in context", name, t.Line, t.Column, fallbackKeys(t))
+	}
+	return v, nil
+}
+
+// fallbackKeys returns the keys of a variable and its fallbacks, as written
+// in a template.
+func fallbackKeys(t *ast.Variable) string {
+	keys := []string{strings.Join(t.Key, ".")}
+	for _, fb := range t.Fallbacks {
+		if fb.Key != nil {
+			keys = append(keys, strings.Join(fb.Key, "."))
+		}
+	}
+	return strings.Join(keys, " ?? ")
+}
+
+// lookupHelper returns the helper registered for a key of a single name. If
+// there is none, the reflect.Value zero type is returned.
+func (r *renderer) lookupHelper(key []string) reflect.Value {
+	if len(key) != 1 {
+		return reflect.Value{}
+	}
+	if h := r.template.helpers[key[0]]; h != nil {
+		return reflect.ValueOf(h)
+	}
+	return reflect.Value{}
+}
+
+// helperType is the type of helpers.
+var helperType = reflect.TypeOf(Helper(nil))
+
+// asHelper returns the helper held by a value of type Helper.
+func asHelper(v reflect.Value) (Helper, bool) {
+	if v.Type() != helperType || !v.CanInterface() {
+		return nil, false
+	}
+	return v.Interface().(Helper), true
+}
+
+// lambdaType is the type of lambdas.
+var lambdaType = reflect.TypeOf(Lambda(nil))
+
+// asLambda returns the lambda held by a value of type Lambda.
+func asLambda(v reflect.Value) (Lambda, bool) {
+	if v.Type() != lambdaType || !v.CanInterface() {
+		return nil, false
+	}
+	return v.Interface().(Lambda), true
+}
+
+// callLambda calls the lambda of a section, and returns its result. Errors
+// are positioned at the section.
+func (r *renderer) callLambda(name string, sec *ast.Section, l Lambda) (string, error) {
+	s, err := l(sec.Text, func(text string) (string, error) {
+		tree, err := parse.Parse("lambda", text, parse.DefaultLeftDelim, parse.DefaultRightDelim, r.template.ParseOptions)
+		if err != nil {
+			return "", err
+		}
+		return r.renderToString(tree)
+	})
+	if err != nil {
+		return "", fmt.Errorf("%s:%d:%d: %v", name, sec.Line, sec.Column, err)
+	}
+	return s, nil
+}
+
 // lookupKeysStack obtains a value for a dotted key - eg: a.b.c . If a value
 // was not found, the reflect.Value zero type is returned.
 func lookupKeysStack(key []string, contexts []reflect.Value) reflect.Value {
@@ -0,0 +1,169 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package rt provides runtime support for the Go code generated by the
+// codegen package. It is not intended to be used directly.
+package rt
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/eriklott/mustache/ast"
+	"github.com/eriklott/mustache/parse"
+)
+
+// Writer writes the output of a generated template, indenting the lines of
+// partials the same way as the interpreter. Write errors are sticky: after
+// the first error, writes are discarded and Err returns the error.
+type Writer struct {
+	w          io.Writer
+	err        error
+	indent     string // the current indent string
+	indentNext bool   // when true, apply indent before next write
+}
+
+// NewWriter returns a Writer that writes to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// WriteString writes s unescaped.
+func (w *Writer) WriteString(s string) {
+	if w.err != nil {
+		return
+	}
+	if w.indentNext {
+		w.indentNext = false
+		if _, w.err = io.WriteString(w.w, w.indent); w.err != nil {
+			return
+		}
+	}
+	_, w.err = io.WriteString(w.w, s)
+}
+
+// WriteLine writes s unescaped, and indents the next write. s is expected to
+// end with a line ending.
+func (w *Writer) WriteLine(s string) {
+	w.WriteString(s)
+	w.indentNext = true
+}
+
+// WriteEscaped writes s with HTML escaping.
+func (w *Writer) WriteEscaped(s string) {
+	w.WriteString(html.EscapeString(s))
+}
+
+// PushIndent appends s to the indent string at the start of a partial, and
+// returns the previous indent string for PopIndent.
+func (w *Writer) PushIndent(s string) string {
+	prev := w.indent
+	w.indent += s
+	w.indentNext = true
+	return prev
+}
+
+// PopIndent restores the indent string at the end of a partial.
+func (w *Writer) PopIndent(prev string) {
+	w.indent = prev
+}
+
+// Err returns the first error that occurred while writing.
+func (w *Writer) Err() error {
+	return w.err
+}
+
+// Bool formats a boolean value.
+func Bool(b bool) string {
+	return strconv.FormatBool(b)
+}
+
+// Int formats a signed integer value.
+func Int(i int64) string {
+	return strconv.FormatInt(i, 10)
+}
+
+// Uint formats an unsigned integer value.
+func Uint(u uint64) string {
+	return strconv.FormatUint(u, 10)
+}
+
+// Float formats a floating point value.
+func Float(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// Complex formats a complex value.
+func Complex(c complex128) string {
+	return fmt.Sprintf("%v", c)
+}
+
+// Any formats a value of any other type.
+func Any(v interface{}) string {
+	return fmt.Sprintf("%v", v)
+}
+
+// TruthyFloat reports whether a floating point value is truthy. Only
+// positive zero is falsy.
+func TruthyFloat(f float64) bool {
+	return math.Float64bits(f) != 0
+}
+
+// TruthyComplex reports whether a complex value is truthy. Only a value
+// with positive zero real and imaginary parts is falsy.
+func TruthyComplex(c complex128) bool {
+	return math.Float64bits(real(c)) != 0 || math.Float64bits(imag(c)) != 0
+}
+
+// ErrLambdaTags is returned when a function rendered by generated code
+// returns a string containing tags. The interpreter renders such strings as
+// templates against the context stack, which generated code cannot do.
+var ErrLambdaTags = errors.New("rt: function result contains tags, which are not supported by generated code")
+
+// Lambda renders the string returned by a function used as a variable. Parse
+// errors are returned.
+func Lambda(s string) (string, error) {
+	if !strings.Contains(s, parse.DefaultLeftDelim) {
+		return s, nil
+	}
+	tree, err := parse.Parse("lambda", s, parse.DefaultLeftDelim, parse.DefaultRightDelim, parse.Options{})
+	if err != nil {
+		return "", err
+	}
+	return lambdaText(tree)
+}
+
+// SectionLambda renders the string returned by a function used as a
+// section. A string that fails to parse is falsy, and renders as "".
+func SectionLambda(s string) (string, error) {
+	if !strings.Contains(s, parse.DefaultLeftDelim) {
+		return s, nil
+	}
+	tree, err := parse.Parse("lambda", s, parse.DefaultLeftDelim, parse.DefaultRightDelim, parse.Options{})
+	if err != nil {
+		return "", nil
+	}
+	return lambdaText(tree)
+}
+
+// lambdaText returns the text of a lambda tree, or ErrLambdaTags if the tree
+// contains tags that render context values.
+func lambdaText(tree *ast.Tree) (string, error) {
+	var b strings.Builder
+	for _, node := range tree.Nodes {
+		switch n := node.(type) {
+		case *ast.Text:
+			b.WriteString(n.Text)
+		case *ast.Comment, *ast.SetDelims:
+		default:
+			return "", ErrLambdaTags
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,201 @@
+// Copyright 2019 Erik Lott. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package mustache_test
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/eriklott/mustache"
+)
+
+var specStrict = flag.Bool("spec.strict", false, "fail the tests of optional spec modules that do not conform")
+
+// specTest is a test of the mustache spec.
+type specTest struct {
+	Name        string            `json:"name"`
+	Description string            `json:"desc"`
+	Data        interface{}       `json:"data"`
+	Template    string            `json:"template"`
+	Partials    map[string]string `json:"partials"`
+	Expected    string            `json:"expected"`
+}
+
+// specLambdas maps the tests of the lambdas spec module to Go functions
+// implementing their lambda fixtures, which the spec gives as source code in
+// other languages. Each call returns a new function, so that lambdas counting
+// their calls start afresh.
+var specLambdas = map[string]func() interface{}{
+	"Interpolation": func() interface{} {
+		return func() string { return "world" }
+	},
+	"Interpolation - Expansion": func() interface{} {
+		return func() string { return "{{planet}}" }
+	},
+	"Interpolation - Alternate Delimiters": func() interface{} {
+		return func() string { return "|planet| => {{planet}}" }
+	},
+	"Interpolation - Multiple Calls": func() interface{} {
+		calls := 0
+		return func() string {
+			calls++
+			return strconv.Itoa(calls)
+		}
+	},
+	"Escaping": func() interface{} {
+		return func() string { return ">" }
+	},
+	"Section": func() interface{} {
+		return func(text string) string {
+			if text == "{{x}}" {
+				return "yes"
+			}
+			return "no"
+		}
+	},
+	"Section - Expansion": func() interface{} {
+		return func(text string) string { return text + "{{planet}}" + text }
+	},
+	"Section - Alternate Delimiters": func() interface{} {
+		return func(text string) string { return text + "{{planet}} => |planet|" + text }
+	},
+	"Section - Multiple Calls": func() interface{} {
+		return func(text string) string { return "__" + text + "__" }
+	},
+	"Inverted Section": func() interface{} {
+		return func(text string) string { return "" }
+	},
+}
+
+// specRequired are the required modules of the mustache spec.
+var specRequired = []string{
+	"comments",
+	"delimiters",
+	"interpolation",
+	"inverted",
+	"partials",
+	"sections",
+}
+
+// TestRender_Spec runs every module of the mustache spec found in the spec
+// submodule, and logs the conformance of each module. The required modules
+// must be present, and their tests must pass. The optional modules, whose
+// names start with ~, report the tests that do not conform without failing,
+// unless the -spec.strict flag is set.
+func TestRender_Spec(t *testing.T) {
+	for _, module := range specRequired {
+		path := filepath.Join("spec", "specs", module+".json")
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("required spec module %s not found, run: git submodule update --init: %v", module, err)
+		}
+	}
+	paths, err := filepath.Glob(filepath.Join("spec", "specs", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var report []string
+	for _, path := range paths {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read spec file %s: %v", path, err)
+		}
+		var suite struct {
+			Tests []specTest `json:"tests"`
+		}
+		if err := json.Unmarshal(b, &suite); err != nil {
+			t.Fatalf("failed to unmarshal json spec %s: %v", path, err)
+		}
+
+		module := strings.TrimSuffix(filepath.Base(path), ".json")
+		optional := strings.HasPrefix(module, "~")
+		conforming := 0
+		t.Run(module, func(t *testing.T) {
+			for _, tc := range suite.Tests {
+				t.Run(tc.Name, func(t *testing.T) {
+					got, err := renderSpec(tc)
+					var problem string
+					switch {
+					case err != nil:
+						problem = err.Error()
+					case got != tc.Expected:
+						problem = fmt.Sprintf("unexpected response, got:%q, want:%q", got, tc.Expected)
+					default:
+						conforming++
+						return
+					}
+					if optional && !*specStrict {
+						t.Logf("does not conform: %s", problem)
+						return
+					}
+					t.Error(problem)
+				})
+			}
+		})
+		report = append(report, fmt.Sprintf("%s: %d/%d tests conform", module, conforming, len(suite.Tests)))
+	}
+	t.Log("spec conformance:\n" + strings.Join(report, "\n"))
+}
+
+// renderSpec renders the template of a spec test.
+func renderSpec(tc specTest) (string, error) {
+	data, err := specData(tc.Name, tc.Data)
+	if err != nil {
+		return "", err
+	}
+	tmpl := mustache.NewTemplate()
+	for name, text := range tc.Partials {
+		if err := tmpl.Parse(name, text); err != nil {
+			return "", fmt.Errorf("failed to parse partial %s: %v", name, err)
+		}
+	}
+	if err := tmpl.Parse("main", tc.Template); err != nil {
+		return "", fmt.Errorf("failed to parse template: %v", err)
+	}
+	got, err := tmpl.Render("main", data)
+	if err != nil {
+		return "", fmt.Errorf("failed to render template: %v", err)
+	}
+	// specs test against escaped char &quot; rather than go's &#34;
+	return strings.Replace(got, "&#34;", "&quot;", -1), nil
+}
+
+// specData replaces the lambda fixtures of the data of a spec test, objects
+// tagged as code, with the Go function of the test.
+func specData(name string, v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if v["__tag__"] == "code" {
+			lambda, ok := specLambdas[name]
+			if !ok {
+				return nil, fmt.Errorf("no Go function for the lambda of %q", name)
+			}
+			return lambda(), nil
+		}
+		for k, e := range v {
+			e, err := specData(name, e)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = e
+		}
+	case []interface{}:
+		for i, e := range v {
+			e, err := specData(name, e)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = e
+		}
+	}
+	return v, nil
+}